@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	app "sso/internal/app"
+	"sso/internal/config"
+	"sso/internal/services/calibration"
+	storage "sso/internal/storage/sqlite"
+	"syscall"
+)
+
+// подбирает стоимость хэширования паролей под текущее железо и сохраняет ее в БД для всех реплик;
+// серверы переходят на новые параметры при перезапуске. Алгоритм, нижняя граница стоимости и целевое время
+// берутся из секции password_hash конфига сервера
+// пример: go run ./cmd/calibrate --config=./config/local.yaml
+func main() {
+	cfg := config.MustLoad()
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	strg, err := storage.New(cfg.StoragePath)
+
+	if err != nil {
+		panic(err)
+	}
+
+	base, err := app.PasswordScheme(cfg.PasswordHash)
+
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	params, err := calibration.New(log, strg, cfg.PasswordHash.Calibration.Target).Calibrate(ctx, base)
+
+	if err != nil {
+		panic(err)
+	}
+
+	log.Info("calibration saved",
+		slog.String("algorithm", params.Algorithm),
+		slog.String("params", params.Params),
+		slog.Duration("hash_duration", params.HashDuration),
+	)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sso/internal/lib/password"
+	"sso/internal/services/importer"
+	storage "sso/internal/storage/sqlite"
+	"strings"
+	"syscall"
+)
+
+// импортирует пользователей других систем с хэшами паролей; пароли пересчитываются при первом входе
+// пример: go run ./cmd/import --storage-path=./storage/sso.db --file=./users.csv
+// пример (Keycloak): jq -c '.users[]' realm-export.json > users.jsonl && go run ./cmd/import --storage-path=./storage/sso.db --file=./users.jsonl
+func main() {
+	var storagePath, file, format string
+
+	flag.StringVar(&storagePath, "storage-path", "", "path to storage")
+	flag.StringVar(&file, "file", "", "file with users: csv with email,password_hash[,email_verified] header or jsonl")
+	flag.StringVar(&format, "format", "", "csv or jsonl (default: by file extension)")
+	flag.Parse()
+
+	if storagePath == "" || file == "" {
+		panic("storage-path and file is required")
+	}
+
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(file), ".")
+	}
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	strg, err := storage.New(storagePath)
+
+	if err != nil {
+		panic(err)
+	}
+
+	//для импорта важны только поддерживаемые форматы хэшей, новые хэши считает сервер по своему конфигу
+	hasher, err := password.NewHasher(password.DefaultArgon2id, password.LegacyVerifiers...)
+
+	if err != nil {
+		panic(err)
+	}
+
+	f, err := os.Open(file)
+
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	result, err := importer.New(log, strg, hasher).Import(ctx, f, format)
+
+	if err != nil {
+		panic(err)
+	}
+
+	for _, failure := range result.Failures {
+		log.Warn("line not imported",
+			slog.Int("line", failure.Line),
+			slog.String("email", failure.Email),
+			slog.String("reason", failure.Reason),
+		)
+	}
+}
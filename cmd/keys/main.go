@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/services/keyring"
+	storage "sso/internal/storage/sqlite"
+	"syscall"
+	"time"
+)
+
+// ротирует ключи подписи приложения: следующий ключ становится активным, активный принимается еще grace
+// пример (по запросу): go run ./cmd/keys --storage-path=./storage/sso.db --app-id=1 --alg=ES256
+// пример (по расписанию): go run ./cmd/keys --storage-path=./storage/sso.db --app-id=1 --max-age=720h --interval=1h
+// пример (шифрование токенов): go run ./cmd/keys --storage-path=./storage/sso.db --app-id=1 --encryption-key=./app.jwk.json
+func main() {
+	var storagePath, alg, encryptionKey string
+	var appID int
+	var grace, maxAge, interval time.Duration
+	var disableEncryption bool
+
+	flag.StringVar(&storagePath, "storage-path", "", "path to storage")
+	flag.IntVar(&appID, "app-id", 0, "id of app to rotate keys for")
+	flag.StringVar(&alg, "alg", "", "signing algorithm for new keys: HS256, RS256, ES256, EdDSA, v4.local or v4.public (default: app's current)")
+	flag.DurationVar(&grace, "grace", 24*time.Hour, "how long the retired key is still accepted, must exceed token ttl")
+	flag.DurationVar(&maxAge, "max-age", 0, "rotate only if the active key is older than this (0 - rotate now)")
+	flag.DurationVar(&interval, "interval", 0, "keep running and check max-age every interval (0 - run once)")
+	flag.StringVar(&encryptionKey, "encryption-key", "", "register app's public JWK file to encrypt access tokens to and exit")
+	flag.BoolVar(&disableEncryption, "disable-encryption", false, "stop encrypting app's access tokens and exit")
+	flag.Parse()
+
+	if storagePath == "" || appID == 0 {
+		panic("storage-path and app-id is required")
+	}
+
+	if interval > 0 && maxAge == 0 {
+		panic("interval requires max-age")
+	}
+
+	log := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	strg, err := storage.New(storagePath)
+
+	if err != nil {
+		panic(err)
+	}
+
+	ring := keyring.New(log, strg, strg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	if encryptionKey != "" || disableEncryption {
+		var jwk []byte
+
+		if !disableEncryption {
+			if jwk, err = os.ReadFile(encryptionKey); err != nil {
+				panic(err)
+			}
+		}
+
+		if err := ring.SetEncryptionKey(ctx, appID, jwk); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if maxAge == 0 {
+		if err := ring.Rotate(ctx, appID, alg, grace); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	for {
+		if _, err := ring.RotateIfDue(ctx, appID, alg, maxAge, grace); err != nil {
+			if interval == 0 {
+				panic(err)
+			}
+			log.Error("scheduled rotation failed", sl.Err(err))
+		}
+
+		if interval == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -29,11 +29,22 @@ func main() {
 	log.Info("starting application", slog.Any("cfg", cfg))
 
 	//инициализировать приложение
-	application := app.New(log, cfg.GRPC.Port, cfg.StoragePath, cfg.TokenTTL)
+	application := app.New(log, cfg)
 
 	//запустить gRPC-сервер приложения
 	go application.GRPCServer.MustRun()
 
+	//запустить HTTP-сервер (JWKS)
+	go application.HTTPServer.MustRun()
+
+	//запустить сервер метрик, если для него задан порт
+	if application.MetricsServer != nil {
+		go application.MetricsServer.MustRun()
+	}
+
+	//запустить фоновую чистку хранилища
+	go application.Cleanup.MustRun()
+
 	//Graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
@@ -44,6 +55,11 @@ func main() {
 	log.Info("stopping application", slog.String("signal", sign.String()))
 
 	application.GRPCServer.Stop()
+	application.HTTPServer.Stop()
+	if application.MetricsServer != nil {
+		application.MetricsServer.Stop()
+	}
+	application.Cleanup.Stop()
 
 	log.Info("Application stopped")
 
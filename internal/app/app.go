@@ -1,32 +1,242 @@
 package grpc
 
 import (
+	"context"
+	"expvar"
+	"fmt"
 	"log/slog"
+	"runtime"
+	cleanupapp "sso/internal/app/cleanup"
 	grpcapp "sso/internal/app/grpc"
+	httpapp "sso/internal/app/http"
+	"sso/internal/config"
+	"sso/internal/domain/models"
+	"sso/internal/lib/mail"
+	"sso/internal/lib/passkey"
+	"sso/internal/lib/password"
 	auth "sso/internal/services/auth"
+	"sso/internal/services/authz"
+	"sso/internal/services/calibration"
+	"sso/internal/services/importer"
 	storage "sso/internal/storage/sqlite"
-	"time"
+	"sync"
+	"sync/atomic"
 )
 
 type App struct {
 	GRPCServer *grpcapp.App
+	HTTPServer *httpapp.App
+	// MetricsServer nil, если порт метрик не задан
+	MetricsServer *httpapp.App
+	Cleanup       *cleanupapp.App
 }
 
-func New(log *slog.Logger, grpcPort int, storagePath string, tokenTtl time.Duration) *App {
+var (
+	// publishedHashPool пул последнего созданного приложения: expvar публикуется один раз на процесс,
+	// а приложений в одном процессе может быть несколько
+	publishedHashPool atomic.Pointer[password.Pool]
+	publishHashPool   sync.Once
+)
+
+func New(log *slog.Logger, cfg *config.Config) *App {
 
 	//инициализировать хранилище
-	strg, err := storage.New(storagePath)
+	strg, err := storage.New(cfg.StoragePath)
+
+	if err != nil {
+		panic(err)
+	}
+
+	relyingParty, err := passkey.New(cfg.WebAuthn.RPID, cfg.WebAuthn.DisplayName, cfg.WebAuthn.Origins, cfg.WebAuthn.Timeout)
+
+	if err != nil {
+		panic(err)
+	}
+
+	passwordPolicy, err := newPasswordPolicy(cfg.PasswordPolicy)
+
+	if err != nil {
+		panic(err)
+	}
+
+	passwordHasher, hashParams, err := newPasswordHasher(log, strg, cfg.PasswordHash)
 
 	if err != nil {
 		panic(err)
 	}
 
+	hashPool := newHashPool(passwordHasher, cfg.PasswordHash.Pool)
+
 	//инициализировать сервисный слой auth сервиса
-	authService := auth.New(log, strg, strg, strg, tokenTtl)
+	authService := auth.New(
+		log,
+		auth.Storages{
+			UserSaver:      strg,
+			UserProvider:   strg,
+			AppProvider:    strg,
+			KeyProvider:    strg,
+			TokenStorage:   strg,
+			CodeStorage:    strg,
+			AttemptStorage: strg,
+			MFAStorage:     strg,
+			PasskeyStorage: strg,
+			SessionStorage: strg,
+			RoleStorage:    strg,
+		},
+		newMailer(log, cfg.Mail),
+		relyingParty,
+		hashPool,
+		importer.New(log, strg, passwordHasher),
+		hashParams,
+		cfg.TokenTTL,
+		cfg.RefreshTokenTTL,
+		cfg.VerificationTTL,
+		cfg.ResetTTL,
+		auth.ThrottlePolicy{
+			Window:                cfg.LoginThrottle.Window,
+			BackoffAfter:          cfg.LoginThrottle.BackoffAfter,
+			BackoffBase:           cfg.LoginThrottle.BackoffBase,
+			BackoffMax:            cfg.LoginThrottle.BackoffMax,
+			EmailLockoutThreshold: cfg.LoginThrottle.EmailLockoutThreshold,
+			IPLockoutThreshold:    cfg.LoginThrottle.IPLockoutThreshold,
+			LockoutDuration:       cfg.LoginThrottle.LockoutDuration,
+		},
+		passwordPolicy,
+		cfg.Issuer,
+		cfg.LegacyClaims,
+	)
+
+	//проверки доступа на основе отношений для приложений; схема и кортежи у каждого приложения свои
+	authzService := authz.New(log, strg, strg, strg)
+
+	grpcApp := grpcapp.New(log, authService, authzService, cfg.GRPC.Port, cfg.LoginThrottle.TrustForwardedFor)
 
-	grpcApp := grpcapp.New(log, authService, grpcPort)
+	//HTTP нужен верификаторам, которые забирают JWKS без gRPC клиента
+	httpApp := httpapp.New(log, authService, cfg.HTTP.Port, cfg.HTTP.Timeout)
+
+	var metricsApp *httpapp.App
+
+	if cfg.HTTP.MetricsPort != 0 {
+		metricsApp = httpapp.NewMetrics(log, cfg.HTTP.MetricsPort, cfg.HTTP.Timeout)
+	}
+
+	//фоновая чистка denylist от истекших токенов, истекших семейств refresh токенов, забытых счетчиков
+	//попыток входа, брошенных церемоний passkey, истекших сессий и одноразовых кодов
+	cleanupApp := cleanupapp.New(log, cfg.CleanupInterval,
+		cleanupapp.Task{Name: "revoked_tokens", Run: authService.PruneRevokedTokens},
+		cleanupapp.Task{Name: "refresh_tokens", Run: authService.PruneRefreshTokens},
+		cleanupapp.Task{Name: "login_attempts", Run: authService.PruneLoginAttempts},
+		cleanupapp.Task{Name: "passkey_ceremonies", Run: authService.PrunePasskeyCeremonies},
+		cleanupapp.Task{Name: "sessions", Run: authService.PruneSessions},
+		cleanupapp.Task{Name: "one_time_codes", Run: authService.PruneCodes},
+	)
 
 	return &App{
-		GRPCServer: grpcApp,
+		GRPCServer:    grpcApp,
+		HTTPServer:    httpApp,
+		MetricsServer: metricsApp,
+		Cleanup:       cleanupApp,
+	}
+}
+
+// newMailer SMTP в проде; без хоста письма складываются в outbox, откуда их читают разработчики и тесты
+func newMailer(log *slog.Logger, cfg config.MailConfig) auth.Mailer {
+	if cfg.SMTP.Host != "" {
+		return mail.NewSMTP(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.From)
+	}
+
+	log.Info("smtp is not configured, writing mail to outbox", slog.String("dir", cfg.OutboxDir))
+
+	return mail.NewOutbox(cfg.OutboxDir, cfg.From)
+}
+
+// newPasswordPolicy политика паролей из конфига; список утекших паролей загружается в память один раз при старте
+func newPasswordPolicy(cfg config.PasswordConfig) (password.Policy, error) {
+	policy := password.Policy{
+		MinLength:      cfg.MinLength,
+		MaxLength:      cfg.MaxLength,
+		MinCharClasses: cfg.MinCharClasses,
+		RejectEmail:    cfg.RejectEmail,
+	}
+
+	if cfg.BreachedList == "" {
+		return policy, nil
 	}
+
+	blocklist, err := password.LoadBlocklist(cfg.BreachedList, cfg.FalsePositiveRate)
+
+	if err != nil {
+		return password.Policy{}, err
+	}
+
+	policy.Blocklist = blocklist
+
+	return policy, nil
+}
+
+// newPasswordHasher хэшер с алгоритмом из конфига и стоимостью, подобранной калибровкой; хэши импортированных
+// пользователей проверяются форматами других систем и пересчитываются при входе
+func newPasswordHasher(
+	log *slog.Logger,
+	paramsStorage calibration.ParamsStorage,
+	cfg config.HashConfig) (*password.Hasher, models.HashParams, error) {
+	base, err := PasswordScheme(cfg)
+
+	if err != nil {
+		return nil, models.HashParams{}, err
+	}
+
+	calibrator := calibration.New(log, paramsStorage, cfg.Calibration.Target)
+
+	scheme, params, err := calibrator.Scheme(context.Background(), base, cfg.Calibration.OnStart)
+
+	if err != nil {
+		return nil, models.HashParams{}, err
+	}
+
+	hasher, err := password.NewHasher(scheme, password.LegacyVerifiers...)
+
+	if err != nil {
+		return nil, models.HashParams{}, err
+	}
+
+	return hasher, params, nil
+}
+
+// PasswordScheme схема хэширования с алгоритмом и стоимостью из конфига, без калибровки
+func PasswordScheme(cfg config.HashConfig) (password.Scheme, error) {
+	switch cfg.Algorithm {
+	case password.AlgorithmBcrypt:
+		return password.Bcrypt{Cost: cfg.Bcrypt.Cost}, nil
+	case password.AlgorithmArgon2id:
+		return password.Argon2id{
+			Memory:      cfg.Argon2id.Memory,
+			Iterations:  cfg.Argon2id.Iterations,
+			Parallelism: cfg.Argon2id.Parallelism,
+		}, nil
+	case password.AlgorithmScrypt:
+		return password.Scrypt{LogN: cfg.Scrypt.LogN, R: cfg.Scrypt.R, P: cfg.Scrypt.P}, nil
+	}
+
+	return nil, fmt.Errorf("unknown password hash algorithm %q", cfg.Algorithm)
+}
+
+// newHashPool пул хэширования паролей для запросов клиентов; его состояние и время ожидания в очереди
+// публикуются в /debug/vars сервера метрик
+func newHashPool(hasher *password.Hasher, cfg config.HashPoolConfig) *password.Pool {
+	concurrency := cfg.Concurrency
+
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	pool := password.NewPool(hasher, concurrency, cfg.QueueDepth)
+
+	publishedHashPool.Store(pool)
+
+	publishHashPool.Do(func() {
+		expvar.Publish("password_hash_pool", expvar.Func(func() any { return publishedHashPool.Load().Stats() }))
+	})
+
+	return pool
 }
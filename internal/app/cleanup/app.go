@@ -0,0 +1,87 @@
+package cleanupapp
+
+import (
+	"context"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+// Task периодическая чистка хранилища, возвращает число удаленных записей
+type Task struct {
+	Name string
+	Run  func(ctx context.Context) (int64, error)
+}
+
+// App воркер, который раз в interval выполняет задачи чистки
+type App struct {
+	log      *slog.Logger
+	interval time.Duration
+	tasks    []Task
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func New(log *slog.Logger, interval time.Duration, tasks ...Task) *App {
+	return &App{
+		log:      log,
+		interval: interval,
+		tasks:    tasks,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (a *App) MustRun() {
+	a.Run()
+}
+
+func (a *App) Run() {
+	const op = "cleanupapp.Run"
+
+	defer close(a.done)
+
+	log := a.log.With(slog.String("op", op))
+
+	log.Info("cleanup worker is running", slog.Duration("interval", a.interval))
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.runTasks(log)
+
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *App) runTasks(log *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), a.interval)
+	defer cancel()
+
+	for _, task := range a.tasks {
+		deleted, err := task.Run(ctx)
+
+		if err != nil {
+			log.Error("cleanup task failed", slog.String("task", task.Name), sl.Err(err))
+			continue
+		}
+
+		if deleted > 0 {
+			log.Info("cleanup task done", slog.String("task", task.Name), slog.Int64("deleted", deleted))
+		}
+	}
+}
+
+func (a *App) Stop() {
+	const op = "cleanupapp.Stop"
+
+	a.log.With(slog.String("op", op)).Info("stopping cleanup worker")
+
+	close(a.stop)
+	<-a.done
+}
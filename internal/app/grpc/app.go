@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"net"
 	authgrpc "sso/internal/grpc/auth"
+	authzgrpc "sso/internal/grpc/authz"
 )
 
 type App struct {
@@ -14,9 +15,16 @@ type App struct {
 	port       int
 }
 
-func New(log *slog.Logger, authService authgrpc.Auth, port int) *App {
+func New(
+	log *slog.Logger,
+	authService authgrpc.Auth,
+	authzService authzgrpc.Authz,
+	port int,
+	trustForwardedFor bool,
+) *App {
 	gRPCServer := grpc.NewServer()
-	authgrpc.Register(gRPCServer, authService)
+	authgrpc.Register(gRPCServer, authService, trustForwardedFor)
+	authzgrpc.Register(gRPCServer, authzService)
 
 	return &App{
 		log:        log,
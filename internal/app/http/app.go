@@ -0,0 +1,81 @@
+package httpapp
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	authhttp "sso/internal/http/auth"
+	"time"
+)
+
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+}
+
+func New(log *slog.Logger, authService authhttp.Auth, port int, timeout time.Duration) *App {
+	mux := http.NewServeMux()
+	authhttp.Register(mux, authService)
+
+	return newApp(log, mux, port, timeout)
+}
+
+// NewMetrics отдельный сервер метрик процесса и пула хэширования паролей в формате expvar.
+// В expvar есть cmdline и memstats, поэтому порт не должен быть доступен снаружи
+func NewMetrics(log *slog.Logger, port int, timeout time.Duration) *App {
+	mux := http.NewServeMux()
+	mux.Handle("GET /debug/vars", expvar.Handler())
+
+	return newApp(log, mux, port, timeout)
+}
+
+func newApp(log *slog.Logger, handler http.Handler, port int, timeout time.Duration) *App {
+	return &App{
+		log: log,
+		httpServer: &http.Server{
+			Handler:      handler,
+			ReadTimeout:  timeout,
+			WriteTimeout: timeout,
+		},
+		port: port,
+	}
+}
+
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) Run() error {
+	const op = "httpapp.Run"
+
+	log := a.log.With(slog.String("op", op), slog.Int("port", a.port))
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", a.port))
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("HTTP server is running", slog.String("addr", l.Addr().String()))
+
+	if err := a.httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *App) Stop() {
+	const op = "httpapp.Stop"
+
+	a.log.With(slog.String("op", op)).Info("stopping http server", slog.Int("port", a.port))
+
+	_ = a.httpServer.Shutdown(context.Background())
+}
@@ -9,10 +9,22 @@ import (
 )
 
 type Config struct {
-	Env         string        `yaml:"env" env-default:"local"`
-	StoragePath string        `yaml:"storage_path" env-required:"true"`
-	TokenTTL    time.Duration `yaml:"token_ttl" env-required:"true"`
-	GRPC        GRPCConfig    `yaml:"grpc"`
+	Env             string         `yaml:"env" env-default:"local"`
+	StoragePath     string         `yaml:"storage_path" env-required:"true"`
+	TokenTTL        time.Duration  `yaml:"token_ttl" env-required:"true"`
+	RefreshTokenTTL time.Duration  `yaml:"refresh_token_ttl" env-default:"720h"`
+	CleanupInterval time.Duration  `yaml:"cleanup_interval" env-default:"1h"`
+	Issuer          string         `yaml:"issuer" env-default:"sso"`
+	LegacyClaims    bool           `yaml:"legacy_claims" env-default:"true"` //uid рядом с sub для старых потребителей
+	VerificationTTL time.Duration  `yaml:"verification_ttl" env-default:"24h"`
+	ResetTTL        time.Duration  `yaml:"reset_ttl" env-default:"1h"`
+	GRPC            GRPCConfig     `yaml:"grpc"`
+	HTTP            HTTPConfig     `yaml:"http"`
+	Mail            MailConfig     `yaml:"mail"`
+	LoginThrottle   ThrottleConfig `yaml:"login_throttle"`
+	WebAuthn        WebAuthnConfig `yaml:"webauthn"`
+	PasswordPolicy  PasswordConfig `yaml:"password_policy"`
+	PasswordHash    HashConfig     `yaml:"password_hash"`
 }
 
 type GRPCConfig struct {
@@ -20,6 +32,104 @@ type GRPCConfig struct {
 	Timeout time.Duration `yaml:"timeout"`
 }
 
+type HTTPConfig struct {
+	Port    int           `yaml:"port" env-default:"8080"`
+	Timeout time.Duration `yaml:"timeout" env-default:"5s"`
+	// MetricsPort отдельный порт для /debug/vars; 0 выключает. Открывать только во внутренней сети
+	MetricsPort int `yaml:"metrics_port" env-default:"0"`
+}
+
+// MailConfig отправка писем: через SMTP, если задан хост, иначе файлами в OutboxDir
+type MailConfig struct {
+	From      string     `yaml:"from" env-default:"sso@localhost"`
+	OutboxDir string     `yaml:"outbox_dir" env-default:"./storage/outbox"`
+	SMTP      SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port" env-default:"587"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD"`
+}
+
+// ThrottleConfig ограничение перебора паролей: пауза между попытками по email и временная блокировка
+// по email и по IP клиента. Нулевой порог отключает проверку
+type ThrottleConfig struct {
+	Window                time.Duration `yaml:"window" env-default:"15m"`
+	BackoffAfter          int           `yaml:"backoff_after" env-default:"3"`
+	BackoffBase           time.Duration `yaml:"backoff_base" env-default:"1s"`
+	BackoffMax            time.Duration `yaml:"backoff_max" env-default:"1m"`
+	EmailLockoutThreshold int           `yaml:"email_lockout_threshold" env-default:"10"`
+	IPLockoutThreshold    int           `yaml:"ip_lockout_threshold" env-default:"100"`
+	LockoutDuration       time.Duration `yaml:"lockout_duration" env-default:"15m"`
+	// TrustForwardedFor брать IP клиента из последнего адреса x-forwarded-for; включать только за одним
+	// доверенным прокси, который дописывает адрес клиента в конец заголовка
+	TrustForwardedFor bool `yaml:"trust_forwarded_for" env-default:"false"`
+}
+
+// WebAuthnConfig вход по passkeys: RPID домен, к которому привязываются ключи, Origins страницы,
+// с которых браузер проводит церемонии. Timeout время на ответ аутентификатора
+type WebAuthnConfig struct {
+	RPID        string        `yaml:"rp_id" env-default:"localhost"`
+	DisplayName string        `yaml:"display_name" env-default:"sso"`
+	Origins     []string      `yaml:"origins" env-default:"http://localhost"`
+	Timeout     time.Duration `yaml:"timeout" env-default:"5m"`
+}
+
+// PasswordConfig требования к новым паролям, нулевое значение отключает проверку. BreachedList файл
+// с утекшими и частыми паролями по одному на строку; пустой путь отключает проверку по списку
+type PasswordConfig struct {
+	MinLength         int     `yaml:"min_length" env-default:"8"`
+	MaxLength         int     `yaml:"max_length" env-default:"72"`
+	MinCharClasses    int     `yaml:"min_char_classes" env-default:"2"`
+	RejectEmail       bool    `yaml:"reject_email" env-default:"true"`
+	BreachedList      string  `yaml:"breached_list"`
+	FalsePositiveRate float64 `yaml:"breached_false_positive_rate" env-default:"0.001"`
+}
+
+// HashConfig алгоритм и стоимость хэширования новых паролей: bcrypt, argon2id или scrypt.
+// Хэши других алгоритмов и с другой стоимостью по-прежнему проверяются и пересчитываются при входе
+type HashConfig struct {
+	Algorithm   string            `yaml:"algorithm" env-default:"argon2id"`
+	Bcrypt      BcryptConfig      `yaml:"bcrypt"`
+	Argon2id    Argon2idConfig    `yaml:"argon2id"`
+	Scrypt      ScryptConfig      `yaml:"scrypt"`
+	Pool        HashPoolConfig    `yaml:"pool"`
+	Calibration CalibrationConfig `yaml:"calibration"`
+}
+
+// CalibrationConfig подбор стоимости хэширования, при которой хэш считается не дольше Target; параметры
+// алгоритма из HashConfig - нижняя граница. Результат хранится в БД и общий для реплик; при OnStart сервер,
+// не найдя его, калибрует сам. cmd/calibrate калибрует заново, например после смены железа или Target
+type CalibrationConfig struct {
+	OnStart bool          `yaml:"on_start" env-default:"true"`
+	Target  time.Duration `yaml:"target" env-default:"250ms"`
+}
+
+// HashPoolConfig ограничение одновременных хэширований: Concurrency хэшей выполняются сразу (0 - по числу CPU),
+// до QueueDepth ждут, остальные запросы получают ResourceExhausted
+type HashPoolConfig struct {
+	Concurrency int `yaml:"concurrency" env-default:"0"`
+	QueueDepth  int `yaml:"queue_depth" env-default:"64"`
+}
+
+type BcryptConfig struct {
+	Cost int `yaml:"cost" env-default:"10"`
+}
+
+type Argon2idConfig struct {
+	Memory      uint32 `yaml:"memory" env-default:"19456"` //KiB
+	Iterations  uint32 `yaml:"iterations" env-default:"2"`
+	Parallelism uint8  `yaml:"parallelism" env-default:"1"`
+}
+
+type ScryptConfig struct {
+	LogN uint8 `yaml:"ln" env-default:"15"` //N = 2^ln
+	R    int   `yaml:"r" env-default:"8"`
+	P    int   `yaml:"p" env-default:"1"`
+}
+
 func MustLoad() *Config {
 	_ = godotenv.Load()
 
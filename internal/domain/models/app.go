@@ -1,7 +1,34 @@
 package models
 
+import "time"
+
 type App struct {
 	ID     int
 	Name   string
 	Secret string
+	// SignAlg алгоритм, которым приложению разрешено выпускать токены: HS256 или RS256/ES256/EdDSA для JWT,
+	// v4.local или v4.public для PASETO
+	SignAlg string
+	// AccessTokenTTL и RefreshTokenTTL переопределяют TTL из конфига, ноль - значение из конфига
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// ClaimsTemplate какие claims кладутся в токен; nil - только email, как до появления шаблонов
+	ClaimsTemplate *ClaimsTemplate
+	// EncryptionKey публичный JWK приложения; если задан, access токен шифруется на него (JWE)
+	EncryptionKey []byte
+	// RequireVerifiedEmail Login отклоняется, пока пользователь не подтвердил email
+	RequireVerifiedEmail bool
+}
+
+const (
+	UserAttributeEmail   = "email"
+	UserAttributeIsAdmin = "is_admin"
+)
+
+// ClaimsTemplate шаблон claims токена приложения
+type ClaimsTemplate struct {
+	// UserAttributes атрибуты пользователя, которые попадают в токен под своими именами
+	UserAttributes []string `json:"user"`
+	// Custom статические claims приложения. Зарегистрированные claims и app_id переопределить нельзя
+	Custom map[string]any `json:"custom"`
 }
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+const (
+	// KeyStatusNext ключ уже опубликован в JWKS, но еще не подписывает токены
+	KeyStatusNext = "next"
+	// KeyStatusActive ключ, которым подписываются новые токены
+	KeyStatusActive = "active"
+	// KeyStatusRetired ключ больше не подписывает, но принимается до ExpiresAt
+	KeyStatusRetired = "retired"
+)
+
+// AppKey ключ из связки ключей приложения
+type AppKey struct {
+	ID    int64
+	AppID int
+	Kid   string
+	Alg   string
+	// PrivateKey приватный ключ в PKCS#8 PEM, для HS256 - общий секрет
+	PrivateKey []byte
+	// PublicKey публичный ключ в PKIX PEM, для HS256 пустой
+	PublicKey []byte
+	Status    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Verifies сообщает, можно ли проверять этим ключом подпись в момент now
+func (k AppKey) Verifies(now time.Time) bool {
+	switch k.Status {
+	case KeyStatusActive:
+		return true
+	case KeyStatusRetired:
+		return now.Before(k.ExpiresAt)
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+const (
+	CodePurposeEmailVerification = "email_verification"
+	CodePurposePasswordReset     = "password_reset"
+	CodePurposeMFAChallenge      = "mfa_challenge"
+)
+
+// OneTimeCode одноразовый код, отправленный пользователю; хранится только хэш кода
+type OneTimeCode struct {
+	ID      int64
+	UserID  int64
+	Purpose string
+	// AppID приложение, в которое входит пользователь; только у MFA challenge
+	AppID     int
+	CodeHash  []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    time.Time
+}
+
+// RecoveryCode одноразовый код восстановления второго фактора; хранится только хэш кода
+type RecoveryCode struct {
+	ID        int64
+	UserID    int64
+	CodeHash  []byte
+	CreatedAt time.Time
+	UsedAt    time.Time
+}
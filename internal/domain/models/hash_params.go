@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// HashParams параметры хэширования новых паролей алгоритмом Algorithm в формате PHC. Калибровка подбирает их
+// от параметров конфига BaseParams под целевое время хэша Target и сохраняет, чтобы все реплики хэшировали
+// одинаково. Нулевое CalibratedAt - параметры взяты из конфига без калибровки
+type HashParams struct {
+	Algorithm    string
+	Params       string
+	BaseParams   string
+	Target       time.Duration
+	HashDuration time.Duration
+	CalibratedAt time.Time
+}
@@ -0,0 +1,23 @@
+package models
+
+// ImportedUser пользователь другой системы с хэшем пароля в ее формате; Line номер строки во входном файле
+type ImportedUser struct {
+	Line          int
+	Email         string
+	PassHash      []byte
+	EmailVerified bool
+}
+
+// ImportFailure строка импорта, которая не была сохранена, и причина
+type ImportFailure struct {
+	Line   int
+	Email  string
+	Reason string
+}
+
+// ImportResult итог импорта: Imported добавлено, Existing пропущено, потому что email уже зарегистрирован
+type ImportResult struct {
+	Imported int
+	Existing int
+	Failures []ImportFailure
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+// Introspection результат интроспекции токена (RFC 7662). У неактивного токена заполнено только Active
+type Introspection struct {
+	Active    bool
+	TokenType string
+	UserID    int64
+	Email     string
+	AppID     int
+	ExpiresAt time.Time
+	IssuedAt  time.Time
+	Scopes    []string
+}
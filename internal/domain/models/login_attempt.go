@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+const (
+	LoginAttemptEmail = "email"
+	LoginAttemptIP    = "ip"
+)
+
+// LoginAttempt счетчик неудачных попыток входа для email или IP клиента (Kind)
+type LoginAttempt struct {
+	Kind          string
+	Subject       string
+	Failures      int
+	LastFailureAt time.Time
+	// LockedUntil до этого момента вход заблокирован; нулевое время - блокировки нет
+	LockedUntil time.Time
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+const (
+	PasskeyCeremonyRegistration = "registration"
+	PasskeyCeremonyLogin        = "login"
+)
+
+// Passkey ключ WebAuthn пользователя; приватный ключ остается в аутентификаторе
+type Passkey struct {
+	ID           int64
+	UserID       int64
+	CredentialID []byte
+	// PublicKey публичный ключ в COSE
+	PublicKey       []byte
+	AttestationType string
+	// AAGUID модель аутентификатора; у аутентификаторов без аттестации нули
+	AAGUID []byte
+	// SignCount последнее значение счетчика подписей аутентификатора
+	SignCount      uint32
+	Transports     []string
+	BackupEligible bool
+	BackupState    bool
+	CreatedAt      time.Time
+	LastUsedAt     time.Time
+}
+
+// PasskeyCeremony незавершенная регистрация или вход по passkey; хранится только хэш идентификатора
+type PasskeyCeremony struct {
+	ID          int64
+	SessionHash []byte
+	Purpose     string
+	// UserID владелец церемонии; 0 у входа без email, когда пользователь известен только из ответа аутентификатора
+	UserID int64
+	// AppID приложение, в которое входит пользователь; только у входа
+	AppID int
+	// SessionData состояние церемонии библиотеки WebAuthn: challenge, допустимые ключи, требования
+	SessionData []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// AMRHardwareKey способ аутентификации для claim amr: подпись ключом аутентификатора (RFC 8176)
+const AMRHardwareKey = "hwk"
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RelationTuple кортеж отношения object#relation@subject, например document:readme#viewer@user:42.
+// Субъект - конкретный объект (SubjectRelation пуст) или множество субъектов отношения другого объекта,
+// например group:eng#member
+type RelationTuple struct {
+	Namespace        string
+	ObjectID         string
+	Relation         string
+	SubjectNamespace string
+	SubjectID        string
+	SubjectRelation  string
+}
+
+// AuthzSchema схема отношений приложения в JSON: пространства имен, их отношения и правила вычисления
+type AuthzSchema struct {
+	AppID     int
+	Schema    []byte
+	UpdatedAt time.Time
+}
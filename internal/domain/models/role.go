@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// RoleAdmin глобальная роль администратора sso, заменившая флаг is_admin
+const RoleAdmin = "admin"
+
+// Role набор прав, который выдается пользователям. Глобальная роль (AppID 0) действует во всех приложениях,
+// роль приложения - только в его токенах
+type Role struct {
+	ID          int64
+	Name        string
+	AppID       int
+	Description string
+	Permissions []string
+	CreatedAt   time.Time
+}
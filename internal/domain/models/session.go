@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Session вход пользователя в приложение. ID совпадает с FamilyID refresh токенов сессии и передается
+// в claim sid access токенов; отзыв сессии завершает все ее токены
+type Session struct {
+	ID        string
+	UserID    int64
+	AppID     int
+	ClientIP  string
+	UserAgent string
+	CreatedAt time.Time
+	// LastUsedAt последний выпуск токенов сессии: вход или обмен refresh токена
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  time.Time
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// TokenPair результат логина: короткоживущий access токен и непрозрачный refresh токен
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	// MFAChallenge выдается вместо токенов, если у пользователя включен второй фактор;
+	// токены выпускает VerifyMFA по challenge и коду
+	MFAChallenge string
+}
+
+// RefreshToken запись о refresh токене; сам токен не хранится, только его хэш.
+// Все токены, полученные обменом друг из друга, образуют одно семейство FamilyID
+type RefreshToken struct {
+	ID        int64
+	TokenHash []byte
+	FamilyID  string
+	UserID    int64
+	AppID     int
+	// AMR способы аутентификации, которыми началась сессия; переходят в amr access токенов
+	AMR       []string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    time.Time
+	RevokedAt time.Time
+}
+
+// Способы аутентификации для claim amr (RFC 8176)
+const (
+	AMRPassword = "pwd"
+	AMROTP      = "otp"
+	AMRMFA      = "mfa"
+)
@@ -1,7 +1,20 @@
 package models
 
+import "time"
+
 type User struct {
 	ID       int64
 	Email    string
 	PassHash []byte
+	// IsAdmin у пользователя глобальная роль RoleAdmin
+	IsAdmin bool
+	// EmailVerified пользователь подтвердил владение email одноразовым кодом
+	EmailVerified bool
+	// TokensValidAfter access токены пользователя, выпущенные раньше, недействительны
+	TokensValidAfter time.Time
+	// TOTPSecret секрет второго фактора; действует только при TOTPEnabled, до этого enrollment не подтвержден
+	TOTPSecret  []byte
+	TOTPEnabled bool
+	// TOTPLastStep последний принятый шаг TOTP, коды этого и более ранних шагов не принимаются
+	TOTPLastStep int64
 }
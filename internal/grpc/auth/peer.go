@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"strings"
+)
+
+const (
+	forwardedForHeader = "x-forwarded-for"
+	userAgentHeader    = "user-agent"
+)
+
+// clientIP адрес клиента для ограничения попыток входа. За доверенным прокси это последний адрес
+// из x-forwarded-for: его дописал сам прокси, а все левее присылает клиент и может подделать.
+// Иначе адрес соединения. Пустая строка, если адрес не определить
+func clientIP(ctx context.Context, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(forwardedForHeader); len(values) > 0 {
+				hops := strings.Split(values[len(values)-1], ",")
+				if ip := net.ParseIP(strings.TrimSpace(hops[len(hops)-1])); ip != nil {
+					return ip.String()
+				}
+			}
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
+
+// userAgent клиент из метаданных запроса для списка сессий; gRPC клиенты добавляют к нему свой суффикс
+func userAgent(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(userAgentHeader); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
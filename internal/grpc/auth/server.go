@@ -3,12 +3,18 @@ package auth
 import (
 	"buf.build/go/protovalidate"
 	"context"
+	"encoding/json"
 	"errors"
 	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwks"
+	"sso/internal/lib/password"
 	"sso/internal/services/auth"
+	"time"
 )
 
 type Auth interface {
@@ -17,29 +23,111 @@ type Auth interface {
 		email,
 		password string,
 		appID int,
-	) (token string, err error)
+		clientIP string,
+		userAgent string,
+	) (tokens models.TokenPair, err error)
+
+	Refresh(ctx context.Context, refreshToken string) (tokens models.TokenPair, err error)
 
 	RegisterNewUser(ctx context.Context,
 		email,
 		password string,
 	) (userId int64, err error)
 
-	IsAdmin(ctx context.Context, userId int64) (bool, error)
+	IsAdmin(ctx context.Context, token string, userId int64) (bool, error)
+
+	JWKS(ctx context.Context) (jwks.Set, error)
+
+	Introspect(
+		ctx context.Context,
+		clientID int,
+		clientSecret string,
+		token string,
+		tokenTypeHint string,
+	) (models.Introspection, error)
+
+	Logout(ctx context.Context, token, refreshToken string) error
+
+	RevokeToken(
+		ctx context.Context,
+		clientID int,
+		clientSecret string,
+		token string,
+		tokenTypeHint string,
+	) error
+
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+
+	IsSessionRevoked(ctx context.Context, sid string) (bool, error)
+
+	SendVerification(ctx context.Context, email string) error
+
+	VerifyEmail(ctx context.Context, code string) error
+
+	RequestPasswordReset(ctx context.Context, email string) error
+
+	ResetPassword(ctx context.Context, code, newPassword string) error
+
+	ChangePassword(
+		ctx context.Context,
+		token string,
+		currentPassword string,
+		newPassword string,
+		revokeOtherSessions bool,
+	) (tokens models.TokenPair, err error)
+
+	UnlockLogin(ctx context.Context, token, email, clientIP string) error
+
+	EnrollTOTP(ctx context.Context, token string) (secret string, uri string, err error)
+
+	ConfirmTOTP(ctx context.Context, token, code string) (recoveryCodes []string, err error)
+
+	DisableTOTP(ctx context.Context, token, code string) error
+
+	VerifyMFA(ctx context.Context, challenge, code, clientIP, userAgent string) (tokens models.TokenPair, err error)
+
+	BeginPasskeyRegistration(ctx context.Context, token string) (sessionID string, options []byte, err error)
+
+	FinishPasskeyRegistration(ctx context.Context, token, sessionID string, credential []byte) error
+
+	BeginPasskeyLogin(ctx context.Context, email string, appID int) (sessionID string, options []byte, err error)
+
+	FinishPasskeyLogin(ctx context.Context, sessionID string, credential []byte, clientIP, userAgent string) (tokens models.TokenPair, err error)
+
+	ImportUsers(ctx context.Context, token, format string, data []byte) (models.ImportResult, error)
+
+	PasswordHashParams(ctx context.Context, token string) (models.HashParams, error)
+
+	ListSessions(ctx context.Context, token string) (sessions []models.Session, current string, err error)
+
+	RevokeSession(ctx context.Context, token, sessionID string) error
+
+	RevokeAllSessions(ctx context.Context, token string, keepCurrent bool) error
+
+	CreateRole(ctx context.Context, token string, role models.Role) (roleID int64, err error)
+
+	GrantRole(ctx context.Context, token string, userID, roleID int64) error
+
+	RevokeRole(ctx context.Context, token string, userID, roleID int64) error
+
+	UserRoles(ctx context.Context, token string, userID int64) ([]models.Role, error)
 }
 type serverAPI struct {
 	ssov1.UnimplementedAuthServer
 	v    protovalidate.Validator
 	auth Auth
+	//доверять x-forwarded-for при определении IP клиента
+	trustForwardedFor bool
 }
 
 // Register регистрация хендлеров и инициализация валидатора
-func Register(gRPC *grpc.Server, auth Auth) {
+func Register(gRPC *grpc.Server, auth Auth, trustForwardedFor bool) {
 	v, err := protovalidate.New()
 	if err != nil {
 		// В проде лучше вернуть ошибку наружу, а не паниковать
 		panic("protovalidate init: " + err.Error())
 	}
-	ssov1.RegisterAuthServer(gRPC, &serverAPI{v: v, auth: auth})
+	ssov1.RegisterAuthServer(gRPC, &serverAPI{v: v, auth: auth, trustForwardedFor: trustForwardedFor})
 }
 
 func (s *serverAPI) Register(ctx context.Context, req *ssov1.RegisterRequest) (*ssov1.RegisterResponse, error) {
@@ -53,6 +141,12 @@ func (s *serverAPI) Register(ctx context.Context, req *ssov1.RegisterRequest) (*
 		if errors.Is(err, auth.ErrUserExists) {
 			return nil, status.Error(codes.AlreadyExists, "User already exists")
 		}
+		if errors.Is(err, auth.ErrWeakPassword) {
+			return nil, weakPasswordError(err, "password")
+		}
+		if errors.Is(err, auth.ErrBusy) {
+			return nil, status.Error(codes.ResourceExhausted, "Server is busy, try again later")
+		}
 		return nil, status.Error(codes.Internal, "Internal server error")
 	}
 
@@ -65,31 +159,734 @@ func (s *serverAPI) Login(ctx context.Context, req *ssov1.LoginRequest) (*ssov1.
 		return nil, status.Errorf(codes.InvalidArgument, "invalid LoginRequest: %v", err)
 	}
 
-	token, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()))
+	tokens, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), int(req.GetAppId()), clientIP(ctx, s.trustForwardedFor), userAgent(ctx))
 
 	if err != nil {
 		if errors.Is(err, auth.ErrInvalidCredentials) {
 			return nil, status.Error(codes.InvalidArgument, "Invalid credentials")
 		}
+		if errors.Is(err, auth.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, "Email is not verified")
+		}
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "Too many login attempts, try again later")
+		}
+		if errors.Is(err, auth.ErrBusy) {
+			return nil, status.Error(codes.ResourceExhausted, "Server is busy, try again later")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	if tokens.MFAChallenge != "" {
+		return &ssov1.LoginResponse{MfaRequired: true, MfaChallenge: tokens.MFAChallenge}, nil
+	}
+
+	return &ssov1.LoginResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+// Refresh обмен refresh токена на новую пару токенов
+func (s *serverAPI) Refresh(ctx context.Context, req *ssov1.RefreshRequest) (*ssov1.RefreshResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid RefreshRequest: %v", err)
+	}
+
+	tokens, err := s.auth.Refresh(ctx, req.GetRefreshToken())
+
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid refresh token")
+		}
 		return nil, status.Error(codes.Internal, "Internal server error")
 	}
 
-	return &ssov1.LoginResponse{Token: token}, nil
+	return &ssov1.RefreshResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
 }
 
+// IsAdmin проверка роли администратора; других пользователей проверяет только администратор
 func (s *serverAPI) IsAdmin(ctx context.Context, req *ssov1.IsAdminRequest) (*ssov1.IsAdminResponse, error) {
 	if err := s.v.Validate(req); err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid IsAdminRequest: %v", err)
 	}
 
-	res, err := s.auth.IsAdmin(ctx, req.GetUserId())
+	res, err := s.auth.IsAdmin(ctx, req.GetToken(), req.GetUserId())
 
 	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, "Permission denied")
+		}
 		if errors.Is(err, auth.ErrUserNotFound) {
-			return nil, status.Errorf(codes.NotFound, "User not found")
+			return nil, status.Error(codes.NotFound, "User not found")
 		}
-		return nil, status.Errorf(codes.Internal, "Internal server error")
+		return nil, status.Error(codes.Internal, "Internal server error")
 	}
 
 	return &ssov1.IsAdminResponse{IsAdmin: res}, nil
 }
+
+// JWKS отдает публичные ключи приложений для проверки подписи токенов
+func (s *serverAPI) JWKS(ctx context.Context, req *ssov1.JWKSRequest) (*ssov1.JWKSResponse, error) {
+	set, err := s.auth.JWKS(ctx)
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	raw, err := json.Marshal(set)
+
+	if err != nil {
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.JWKSResponse{Jwks: string(raw)}, nil
+}
+
+// Introspect проверка токена для клиентов, которые не могут проверить JWT сами (RFC 7662)
+func (s *serverAPI) Introspect(ctx context.Context, req *ssov1.IntrospectRequest) (*ssov1.IntrospectResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid IntrospectRequest: %v", err)
+	}
+
+	res, err := s.auth.Introspect(ctx, int(req.GetAppId()), req.GetAppSecret(), req.GetToken(), req.GetTokenTypeHint())
+
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidClient) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid client credentials")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	if !res.Active {
+		return &ssov1.IntrospectResponse{Active: false}, nil
+	}
+
+	return &ssov1.IntrospectResponse{
+		Active:    true,
+		TokenType: res.TokenType,
+		UserId:    res.UserID,
+		Email:     res.Email,
+		AppId:     int32(res.AppID),
+		Exp:       unix(res.ExpiresAt),
+		Iat:       unix(res.IssuedAt),
+		Scopes:    res.Scopes,
+	}, nil
+}
+
+// Logout отзыв текущего access токена и семейства refresh токена
+func (s *serverAPI) Logout(ctx context.Context, req *ssov1.LogoutRequest) (*ssov1.LogoutResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid LogoutRequest: %v", err)
+	}
+
+	if err := s.auth.Logout(ctx, req.GetToken(), req.GetRefreshToken()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.LogoutResponse{}, nil
+}
+
+// RevokeToken отзыв токена приложением (RFC 7009)
+func (s *serverAPI) RevokeToken(ctx context.Context, req *ssov1.RevokeTokenRequest) (*ssov1.RevokeTokenResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid RevokeTokenRequest: %v", err)
+	}
+
+	err := s.auth.RevokeToken(ctx, int(req.GetAppId()), req.GetAppSecret(), req.GetToken(), req.GetTokenTypeHint())
+
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidClient) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid client credentials")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.RevokeTokenResponse{}, nil
+}
+
+// IsRevoked проверка jti по denylist и sid по сессиям; токен отозван, если отозвано хоть одно
+func (s *serverAPI) IsRevoked(ctx context.Context, req *ssov1.IsRevokedRequest) (*ssov1.IsRevokedResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid IsRevokedRequest: %v", err)
+	}
+
+	if req.GetJti() == "" && req.GetSid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "jti or sid is required")
+	}
+
+	if req.GetJti() != "" {
+		revoked, err := s.auth.IsRevoked(ctx, req.GetJti())
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, "Internal server error")
+		}
+
+		if revoked {
+			return &ssov1.IsRevokedResponse{Revoked: true}, nil
+		}
+	}
+
+	if req.GetSid() != "" {
+		revoked, err := s.auth.IsSessionRevoked(ctx, req.GetSid())
+
+		if err != nil {
+			return nil, status.Error(codes.Internal, "Internal server error")
+		}
+
+		return &ssov1.IsRevokedResponse{Revoked: revoked}, nil
+	}
+
+	return &ssov1.IsRevokedResponse{}, nil
+}
+
+// SendVerification повторная отправка кода подтверждения email. Ответ не зависит от того, есть ли такой пользователь
+func (s *serverAPI) SendVerification(ctx context.Context, req *ssov1.SendVerificationRequest) (*ssov1.SendVerificationResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid SendVerificationRequest: %v", err)
+	}
+
+	if err := s.auth.SendVerification(ctx, req.GetEmail()); err != nil {
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.SendVerificationResponse{}, nil
+}
+
+// VerifyEmail подтверждение email кодом из письма
+func (s *serverAPI) VerifyEmail(ctx context.Context, req *ssov1.VerifyEmailRequest) (*ssov1.VerifyEmailResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid VerifyEmailRequest: %v", err)
+	}
+
+	if err := s.auth.VerifyEmail(ctx, req.GetCode()); err != nil {
+		if errors.Is(err, auth.ErrInvalidCode) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid or expired code")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.VerifyEmailResponse{}, nil
+}
+
+// RequestPasswordReset отправка кода сброса пароля. Ответ не зависит от того, есть ли такой пользователь
+func (s *serverAPI) RequestPasswordReset(ctx context.Context, req *ssov1.RequestPasswordResetRequest) (*ssov1.RequestPasswordResetResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid RequestPasswordResetRequest: %v", err)
+	}
+
+	if err := s.auth.RequestPasswordReset(ctx, req.GetEmail()); err != nil {
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.RequestPasswordResetResponse{}, nil
+}
+
+// ResetPassword новый пароль по коду из письма; завершает все сессии пользователя
+func (s *serverAPI) ResetPassword(ctx context.Context, req *ssov1.ResetPasswordRequest) (*ssov1.ResetPasswordResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ResetPasswordRequest: %v", err)
+	}
+
+	if err := s.auth.ResetPassword(ctx, req.GetCode(), req.GetNewPassword()); err != nil {
+		if errors.Is(err, auth.ErrInvalidCode) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid or expired code")
+		}
+		if errors.Is(err, auth.ErrWeakPassword) {
+			return nil, weakPasswordError(err, "new_password")
+		}
+		if errors.Is(err, auth.ErrBusy) {
+			return nil, status.Error(codes.ResourceExhausted, "Server is busy, try again later")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.ResetPasswordResponse{}, nil
+}
+
+// ChangePassword смена пароля по access токену и текущему паролю. При revoke_other_sessions
+// возвращает новую пару токенов, потому что старые токены текущей сессии тоже отозваны
+func (s *serverAPI) ChangePassword(ctx context.Context, req *ssov1.ChangePasswordRequest) (*ssov1.ChangePasswordResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ChangePasswordRequest: %v", err)
+	}
+
+	tokens, err := s.auth.ChangePassword(ctx, req.GetToken(), req.GetCurrentPassword(), req.GetNewPassword(), req.GetRevokeOtherSessions())
+
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid credentials")
+		}
+		if errors.Is(err, auth.ErrWeakPassword) {
+			return nil, weakPasswordError(err, "new_password")
+		}
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "Too many login attempts, try again later")
+		}
+		if errors.Is(err, auth.ErrBusy) {
+			return nil, status.Error(codes.ResourceExhausted, "Server is busy, try again later")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.ChangePasswordResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+// UnlockLogin снятие блокировки входа администратором
+func (s *serverAPI) UnlockLogin(ctx context.Context, req *ssov1.UnlockLoginRequest) (*ssov1.UnlockLoginResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid UnlockLoginRequest: %v", err)
+	}
+
+	if req.GetEmail() == "" && req.GetIp() == "" {
+		return nil, status.Error(codes.InvalidArgument, "email or ip is required")
+	}
+
+	if err := s.auth.UnlockLogin(ctx, req.GetToken(), req.GetEmail(), req.GetIp()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, "Permission denied")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.UnlockLoginResponse{}, nil
+}
+
+// EnrollTOTP начало подключения второго фактора
+func (s *serverAPI) EnrollTOTP(ctx context.Context, req *ssov1.EnrollTOTPRequest) (*ssov1.EnrollTOTPResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid EnrollTOTPRequest: %v", err)
+	}
+
+	secret, uri, err := s.auth.EnrollTOTP(ctx, req.GetToken())
+
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &ssov1.EnrollTOTPResponse{Secret: secret, OtpauthUri: uri}, nil
+}
+
+// ConfirmTOTP включение второго фактора первым кодом; возвращает коды восстановления
+func (s *serverAPI) ConfirmTOTP(ctx context.Context, req *ssov1.ConfirmTOTPRequest) (*ssov1.ConfirmTOTPResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ConfirmTOTPRequest: %v", err)
+	}
+
+	recoveryCodes, err := s.auth.ConfirmTOTP(ctx, req.GetToken(), req.GetCode())
+
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &ssov1.ConfirmTOTPResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+// DisableTOTP выключение второго фактора кодом TOTP или кодом восстановления
+func (s *serverAPI) DisableTOTP(ctx context.Context, req *ssov1.DisableTOTPRequest) (*ssov1.DisableTOTPResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid DisableTOTPRequest: %v", err)
+	}
+
+	if err := s.auth.DisableTOTP(ctx, req.GetToken(), req.GetCode()); err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &ssov1.DisableTOTPResponse{}, nil
+}
+
+// VerifyMFA второй шаг входа: challenge из Login и код второго фактора
+func (s *serverAPI) VerifyMFA(ctx context.Context, req *ssov1.VerifyMFARequest) (*ssov1.VerifyMFAResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid VerifyMFARequest: %v", err)
+	}
+
+	tokens, err := s.auth.VerifyMFA(ctx, req.GetMfaChallenge(), req.GetCode(), clientIP(ctx, s.trustForwardedFor), userAgent(ctx))
+
+	if err != nil {
+		return nil, mfaError(err)
+	}
+
+	return &ssov1.VerifyMFAResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+// BeginPasskeyRegistration параметры для navigator.credentials.create()
+func (s *serverAPI) BeginPasskeyRegistration(ctx context.Context, req *ssov1.BeginPasskeyRegistrationRequest) (*ssov1.BeginPasskeyRegistrationResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid BeginPasskeyRegistrationRequest: %v", err)
+	}
+
+	sessionID, options, err := s.auth.BeginPasskeyRegistration(ctx, req.GetToken())
+
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.BeginPasskeyRegistrationResponse{SessionId: sessionID, Options: string(options)}, nil
+}
+
+// FinishPasskeyRegistration сохранение ключа по ответу navigator.credentials.create()
+func (s *serverAPI) FinishPasskeyRegistration(ctx context.Context, req *ssov1.FinishPasskeyRegistrationRequest) (*ssov1.FinishPasskeyRegistrationResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid FinishPasskeyRegistrationRequest: %v", err)
+	}
+
+	err := s.auth.FinishPasskeyRegistration(ctx, req.GetToken(), req.GetSessionId(), []byte(req.GetCredential()))
+
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		case errors.Is(err, auth.ErrInvalidCode):
+			return nil, status.Error(codes.InvalidArgument, "Invalid or expired passkey session")
+		case errors.Is(err, auth.ErrInvalidPasskey):
+			return nil, status.Error(codes.InvalidArgument, "Invalid passkey")
+		case errors.Is(err, auth.ErrPasskeyExists):
+			return nil, status.Error(codes.AlreadyExists, "Passkey already registered")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.FinishPasskeyRegistrationResponse{}, nil
+}
+
+// BeginPasskeyLogin параметры для navigator.credentials.get(). Ответ не зависит от того, есть ли такой пользователь
+func (s *serverAPI) BeginPasskeyLogin(ctx context.Context, req *ssov1.BeginPasskeyLoginRequest) (*ssov1.BeginPasskeyLoginResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid BeginPasskeyLoginRequest: %v", err)
+	}
+
+	sessionID, options, err := s.auth.BeginPasskeyLogin(ctx, req.GetEmail(), int(req.GetAppId()))
+
+	if err != nil {
+		if errors.Is(err, auth.ErrAppNotFound) {
+			return nil, status.Error(codes.InvalidArgument, "Unknown app")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.BeginPasskeyLoginResponse{SessionId: sessionID, Options: string(options)}, nil
+}
+
+// FinishPasskeyLogin вход по ответу navigator.credentials.get(); токены те же, что у Login
+func (s *serverAPI) FinishPasskeyLogin(ctx context.Context, req *ssov1.FinishPasskeyLoginRequest) (*ssov1.FinishPasskeyLoginResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid FinishPasskeyLoginRequest: %v", err)
+	}
+
+	tokens, err := s.auth.FinishPasskeyLogin(ctx, req.GetSessionId(), []byte(req.GetCredential()), clientIP(ctx, s.trustForwardedFor), userAgent(ctx))
+
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid credentials")
+		}
+		if errors.Is(err, auth.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, "Email is not verified")
+		}
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "Too many login attempts, try again later")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.FinishPasskeyLoginResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+// ImportUsers импорт пользователей других систем администратором; строки, которые не удалось импортировать,
+// возвращаются в failures, остальные сохраняются
+func (s *serverAPI) ImportUsers(ctx context.Context, req *ssov1.ImportUsersRequest) (*ssov1.ImportUsersResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ImportUsersRequest: %v", err)
+	}
+
+	result, err := s.auth.ImportUsers(ctx, req.GetToken(), req.GetFormat(), req.GetData())
+
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, "Permission denied")
+		}
+		if errors.Is(err, auth.ErrInvalidImport) {
+			return nil, status.Error(codes.InvalidArgument, "Invalid import data")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	resp := &ssov1.ImportUsersResponse{Imported: int32(result.Imported), Existing: int32(result.Existing)}
+
+	for _, f := range result.Failures {
+		resp.Failures = append(resp.Failures, &ssov1.ImportFailure{Line: int32(f.Line), Email: f.Email, Reason: f.Reason})
+	}
+
+	return resp, nil
+}
+
+// PasswordHashParams текущие параметры хэширования паролей и результат калибровки, только для администратора
+func (s *serverAPI) PasswordHashParams(ctx context.Context, req *ssov1.PasswordHashParamsRequest) (*ssov1.PasswordHashParamsResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid PasswordHashParamsRequest: %v", err)
+	}
+
+	params, err := s.auth.PasswordHashParams(ctx, req.GetToken())
+
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, "Permission denied")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	resp := &ssov1.PasswordHashParamsResponse{
+		Algorithm:      params.Algorithm,
+		Params:         params.Params,
+		BaseParams:     params.BaseParams,
+		Calibrated:     !params.CalibratedAt.IsZero(),
+		TargetMs:       params.Target.Milliseconds(),
+		HashDurationMs: params.HashDuration.Milliseconds(),
+	}
+
+	if !params.CalibratedAt.IsZero() {
+		resp.CalibratedAt = params.CalibratedAt.Unix()
+	}
+
+	return resp, nil
+}
+
+// ListSessions активные сессии пользователя; current - сессия, в которой выпущен токен запроса
+func (s *serverAPI) ListSessions(ctx context.Context, req *ssov1.ListSessionsRequest) (*ssov1.ListSessionsResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ListSessionsRequest: %v", err)
+	}
+
+	sessions, current, err := s.auth.ListSessions(ctx, req.GetToken())
+
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	resp := &ssov1.ListSessionsResponse{Sessions: make([]*ssov1.Session, 0, len(sessions))}
+
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, &ssov1.Session{
+			Id:         session.ID,
+			AppId:      int32(session.AppID),
+			ClientIp:   session.ClientIP,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt.Unix(),
+			LastUsedAt: session.LastUsedAt.Unix(),
+			ExpiresAt:  session.ExpiresAt.Unix(),
+			Current:    session.ID == current,
+		})
+	}
+
+	return resp, nil
+}
+
+// RevokeSession завершение одной сессии пользователя
+func (s *serverAPI) RevokeSession(ctx context.Context, req *ssov1.RevokeSessionRequest) (*ssov1.RevokeSessionResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid RevokeSessionRequest: %v", err)
+	}
+
+	if err := s.auth.RevokeSession(ctx, req.GetToken(), req.GetSessionId()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			return nil, status.Error(codes.NotFound, "Session not found")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.RevokeSessionResponse{}, nil
+}
+
+// RevokeAllSessions завершение всех сессий пользователя, с keep_current кроме текущей
+func (s *serverAPI) RevokeAllSessions(ctx context.Context, req *ssov1.RevokeAllSessionsRequest) (*ssov1.RevokeAllSessionsResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid RevokeAllSessionsRequest: %v", err)
+	}
+
+	if err := s.auth.RevokeAllSessions(ctx, req.GetToken(), req.GetKeepCurrent()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	return &ssov1.RevokeAllSessionsResponse{}, nil
+}
+
+// CreateRole создание глобальной роли или роли приложения, только для администратора
+func (s *serverAPI) CreateRole(ctx context.Context, req *ssov1.CreateRoleRequest) (*ssov1.CreateRoleResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid CreateRoleRequest: %v", err)
+	}
+
+	roleID, err := s.auth.CreateRole(ctx, req.GetToken(), models.Role{
+		Name:        req.GetName(),
+		AppID:       int(req.GetAppId()),
+		Description: req.GetDescription(),
+		Permissions: req.GetPermissions(),
+	})
+
+	if err != nil {
+		if errors.Is(err, auth.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, "App not found")
+		}
+		if errors.Is(err, auth.ErrRoleExists) {
+			return nil, status.Error(codes.AlreadyExists, "Role already exists")
+		}
+		return nil, roleError(err)
+	}
+
+	return &ssov1.CreateRoleResponse{RoleId: roleID}, nil
+}
+
+// GrantRole выдача роли пользователю, только для администратора
+func (s *serverAPI) GrantRole(ctx context.Context, req *ssov1.GrantRoleRequest) (*ssov1.GrantRoleResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid GrantRoleRequest: %v", err)
+	}
+
+	if err := s.auth.GrantRole(ctx, req.GetToken(), req.GetUserId(), req.GetRoleId()); err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "User not found")
+		}
+		if errors.Is(err, auth.ErrRoleNotFound) {
+			return nil, status.Error(codes.NotFound, "Role not found")
+		}
+		return nil, roleError(err)
+	}
+
+	return &ssov1.GrantRoleResponse{}, nil
+}
+
+// RevokeRole отзыв роли у пользователя, только для администратора
+func (s *serverAPI) RevokeRole(ctx context.Context, req *ssov1.RevokeRoleRequest) (*ssov1.RevokeRoleResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid RevokeRoleRequest: %v", err)
+	}
+
+	if err := s.auth.RevokeRole(ctx, req.GetToken(), req.GetUserId(), req.GetRoleId()); err != nil {
+		if errors.Is(err, auth.ErrRoleNotGranted) {
+			return nil, status.Error(codes.NotFound, "Role is not granted")
+		}
+		return nil, roleError(err)
+	}
+
+	return &ssov1.RevokeRoleResponse{}, nil
+}
+
+// ListUserRoles роли пользователя во всех приложениях; свои роли видит любой пользователь, чужие - администратор
+func (s *serverAPI) ListUserRoles(ctx context.Context, req *ssov1.ListUserRolesRequest) (*ssov1.ListUserRolesResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ListUserRolesRequest: %v", err)
+	}
+
+	roles, err := s.auth.UserRoles(ctx, req.GetToken(), req.GetUserId())
+
+	if err != nil {
+		return nil, roleError(err)
+	}
+
+	resp := &ssov1.ListUserRolesResponse{Roles: make([]*ssov1.Role, 0, len(roles))}
+
+	for _, role := range roles {
+		resp.Roles = append(resp.Roles, &ssov1.Role{
+			Id:          role.ID,
+			Name:        role.Name,
+			AppId:       int32(role.AppID),
+			Description: role.Description,
+			Permissions: role.Permissions,
+		})
+	}
+
+	return resp, nil
+}
+
+// roleError общий маппинг ошибок авторизации операций с ролями
+func roleError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "Invalid token")
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "Permission denied")
+	default:
+		return status.Error(codes.Internal, "Internal server error")
+	}
+}
+
+// mfaError общий маппинг ошибок второго фактора
+func mfaError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "Invalid token")
+	case errors.Is(err, auth.ErrInvalidCode):
+		return status.Error(codes.InvalidArgument, "Invalid or expired code")
+	case errors.Is(err, auth.ErrMFAEnabled):
+		return status.Error(codes.FailedPrecondition, "2FA is already enabled")
+	case errors.Is(err, auth.ErrMFANotEnabled):
+		return status.Error(codes.FailedPrecondition, "2FA is not enabled")
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		return status.Error(codes.ResourceExhausted, "Too many login attempts, try again later")
+	}
+	return status.Error(codes.Internal, "Internal server error")
+}
+
+// weakPasswordError InvalidArgument с нарушениями политики паролей для поля field в деталях google.rpc.BadRequest
+func weakPasswordError(err error, field string) error {
+	st := status.New(codes.InvalidArgument, "Password does not meet policy")
+
+	var policyErr *password.PolicyError
+
+	if !errors.As(err, &policyErr) {
+		return st.Err()
+	}
+
+	badRequest := &errdetails.BadRequest{}
+
+	for _, v := range policyErr.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Reason:      v.Reason,
+			Description: v.Description,
+		})
+	}
+
+	detailed, err := st.WithDetails(badRequest)
+
+	if err != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// unix время в секундах; нулевое время означает, что claim в токене нет
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,167 @@
+package authz
+
+import (
+	"buf.build/go/protovalidate"
+	"context"
+	"errors"
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/internal/domain/models"
+	"sso/internal/lib/authz"
+	authzservice "sso/internal/services/authz"
+)
+
+type Authz interface {
+	WriteSchema(ctx context.Context, clientID int, clientSecret string, schema []byte) error
+
+	ReadSchema(ctx context.Context, clientID int, clientSecret string) (models.AuthzSchema, error)
+
+	WriteTuples(ctx context.Context, clientID int, clientSecret string, writes, deletes []string) error
+
+	Check(ctx context.Context, clientID int, clientSecret, object, relation, subject string) (bool, error)
+
+	ListObjects(ctx context.Context, clientID int, clientSecret, namespace, relation, subject, pageToken string, pageSize int) ([]string, string, error)
+
+	Expand(ctx context.Context, clientID int, clientSecret, object, relation string) (*authz.Tree, error)
+}
+
+type serverAPI struct {
+	ssov1.UnimplementedAuthzServer
+	v     protovalidate.Validator
+	authz Authz
+}
+
+// Register регистрация хендлеров и инициализация валидатора
+func Register(gRPC *grpc.Server, authz Authz) {
+	v, err := protovalidate.New()
+	if err != nil {
+		panic("protovalidate init: " + err.Error())
+	}
+	ssov1.RegisterAuthzServer(gRPC, &serverAPI{v: v, authz: authz})
+}
+
+func (s *serverAPI) WriteSchema(ctx context.Context, req *ssov1.WriteSchemaRequest) (*ssov1.WriteSchemaResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid WriteSchemaRequest: %v", err)
+	}
+
+	if err := s.authz.WriteSchema(ctx, int(req.GetAppId()), req.GetAppSecret(), []byte(req.GetSchema())); err != nil {
+		return nil, authzError(err)
+	}
+
+	return &ssov1.WriteSchemaResponse{}, nil
+}
+
+func (s *serverAPI) ReadSchema(ctx context.Context, req *ssov1.ReadSchemaRequest) (*ssov1.ReadSchemaResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ReadSchemaRequest: %v", err)
+	}
+
+	schema, err := s.authz.ReadSchema(ctx, int(req.GetAppId()), req.GetAppSecret())
+
+	if err != nil {
+		if errors.Is(err, authzservice.ErrSchemaNotFound) {
+			return nil, status.Error(codes.NotFound, "Authz schema not found")
+		}
+		return nil, authzError(err)
+	}
+
+	return &ssov1.ReadSchemaResponse{Schema: string(schema.Schema), UpdatedAt: schema.UpdatedAt.Unix()}, nil
+}
+
+func (s *serverAPI) WriteTuples(ctx context.Context, req *ssov1.WriteTuplesRequest) (*ssov1.WriteTuplesResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid WriteTuplesRequest: %v", err)
+	}
+
+	err := s.authz.WriteTuples(ctx, int(req.GetAppId()), req.GetAppSecret(), req.GetWrites(), req.GetDeletes())
+
+	if err != nil {
+		return nil, authzError(err)
+	}
+
+	return &ssov1.WriteTuplesResponse{}, nil
+}
+
+func (s *serverAPI) Check(ctx context.Context, req *ssov1.CheckRequest) (*ssov1.CheckResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid CheckRequest: %v", err)
+	}
+
+	allowed, err := s.authz.Check(ctx, int(req.GetAppId()), req.GetAppSecret(), req.GetObject(), req.GetRelation(), req.GetSubject())
+
+	if err != nil {
+		return nil, authzError(err)
+	}
+
+	return &ssov1.CheckResponse{Allowed: allowed}, nil
+}
+
+func (s *serverAPI) ListObjects(ctx context.Context, req *ssov1.ListObjectsRequest) (*ssov1.ListObjectsResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ListObjectsRequest: %v", err)
+	}
+
+	objects, next, err := s.authz.ListObjects(ctx, int(req.GetAppId()), req.GetAppSecret(),
+		req.GetNamespace(), req.GetRelation(), req.GetSubject(), req.GetPageToken(), int(req.GetPageSize()))
+
+	if err != nil {
+		return nil, authzError(err)
+	}
+
+	return &ssov1.ListObjectsResponse{Objects: objects, NextPageToken: next}, nil
+}
+
+func (s *serverAPI) Expand(ctx context.Context, req *ssov1.ExpandRequest) (*ssov1.ExpandResponse, error) {
+	if err := s.v.Validate(req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid ExpandRequest: %v", err)
+	}
+
+	tree, err := s.authz.Expand(ctx, int(req.GetAppId()), req.GetAppSecret(), req.GetObject(), req.GetRelation())
+
+	if err != nil {
+		return nil, authzError(err)
+	}
+
+	return &ssov1.ExpandResponse{Tree: usersetTree(tree)}, nil
+}
+
+func authzError(err error) error {
+	switch {
+	case errors.Is(err, authzservice.ErrInvalidClient):
+		return status.Error(codes.Unauthenticated, "Invalid client credentials")
+	case errors.Is(err, authzservice.ErrSchemaNotFound):
+		return status.Error(codes.FailedPrecondition, "Authz schema is not configured")
+	case errors.Is(err, authzservice.ErrInvalidSchema):
+		return status.Error(codes.InvalidArgument, "Invalid authz schema")
+	case errors.Is(err, authzservice.ErrInvalidTuple):
+		return status.Error(codes.InvalidArgument, "Invalid relation tuple")
+	case errors.Is(err, authzservice.ErrDepthExceeded):
+		return status.Error(codes.ResourceExhausted, "Relation graph is too deep")
+	case errors.Is(err, authzservice.ErrBudgetExceeded):
+		return status.Error(codes.ResourceExhausted, "Relation check is too expensive")
+	case errors.Is(err, authzservice.ErrCyclicExclusion):
+		return status.Error(codes.FailedPrecondition, "Relation graph has a cycle through an exclusion")
+	}
+	return status.Error(codes.Internal, "Internal server error")
+}
+
+func usersetTree(tree *authz.Tree) *ssov1.UsersetTree {
+	res := &ssov1.UsersetTree{
+		Operation: tree.Operation,
+		Object:    tree.Object.String(),
+		Relation:  tree.Relation,
+	}
+
+	for _, subject := range tree.Subjects {
+		res.Subjects = append(res.Subjects, subject.String())
+	}
+
+	for _, child := range tree.Children {
+		res.Children = append(res.Children, usersetTree(child))
+	}
+
+	return res
+}
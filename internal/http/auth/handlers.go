@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwks"
+	"sso/internal/services/auth"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Auth interface {
+	JWKS(ctx context.Context) (jwks.Set, error)
+
+	Introspect(
+		ctx context.Context,
+		clientID int,
+		clientSecret string,
+		token string,
+		tokenTypeHint string,
+	) (models.Introspection, error)
+}
+
+type handlers struct {
+	auth Auth
+}
+
+// Register регистрация HTTP хендлеров auth сервиса
+func Register(mux *http.ServeMux, auth Auth) {
+	h := &handlers{auth: auth}
+
+	mux.HandleFunc("GET /.well-known/jwks.json", h.jwks)
+	mux.HandleFunc("POST /introspect", h.introspect)
+}
+
+func (h *handlers) jwks(w http.ResponseWriter, r *http.Request) {
+	set, err := h.auth.JWKS(r.Context())
+
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	_ = json.NewEncoder(w).Encode(set)
+}
+
+// introspectResponse тело ответа по RFC 7662, раздел 2.2
+type introspectResponse struct {
+	Active    bool   `json:"active"`
+	TokenType string `json:"token_type,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	UserID    int64  `json:"uid,omitempty"`
+	Email     string `json:"email,omitempty"`
+	AppID     int    `json:"app_id,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+}
+
+// introspect POST /introspect с token в application/x-www-form-urlencoded,
+// клиент аутентифицируется через HTTP Basic: app_id и secret приложения
+func (h *handlers) introspect(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	appID, err := strconv.Atoi(clientID)
+
+	if !ok || err != nil {
+		unauthorizedClient(w)
+		return
+	}
+
+	token := r.PostFormValue("token")
+
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	res, err := h.auth.Introspect(r.Context(), appID, clientSecret, token, r.PostFormValue("token_type_hint"))
+
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidClient) {
+			unauthorizedClient(w)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if !res.Active {
+		writeJSON(w, http.StatusOK, introspectResponse{Active: false})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, introspectResponse{
+		Active:    true,
+		TokenType: res.TokenType,
+		Sub:       strconv.FormatInt(res.UserID, 10),
+		UserID:    res.UserID,
+		Email:     res.Email,
+		AppID:     res.AppID,
+		ClientID:  strconv.Itoa(res.AppID),
+		Exp:       unix(res.ExpiresAt),
+		Iat:       unix(res.IssuedAt),
+		Scope:     strings.Join(res.Scopes, " "),
+	})
+}
+
+func unauthorizedClient(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="introspect"`)
+	writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// unix время в секундах; нулевое время означает, что claim в токене нет
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,415 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+)
+
+var (
+	ErrUnknownRelation = errors.New("unknown relation")
+	ErrDepthExceeded   = errors.New("relation graph is too deep")
+	ErrCyclicExclusion = errors.New("relation depends on itself through an exclusion")
+	ErrBudgetExceeded  = errors.New("relation check is too expensive")
+)
+
+// MaxDepth предел вложенности при вычислении отношений: переходов по кортежам с userset субъектами,
+// computed_userset и tuple_to_userset
+const MaxDepth = 25
+
+// MaxChecks предел числа отношений, вычисляемых одной проверкой. Повторные отношения берутся
+// из памяти проверки и в предел не входят
+const MaxChecks = 1000
+
+// MaxExpandNodes предел числа узлов дерева Expand. Памяти у Expand нет: общее поддерево раскрывается
+// в каждой ветке заново, и без предела дерево растет экспоненциально от числа кортежей
+const MaxExpandNodes = 1000
+
+// MaxPageSize предел и размер по умолчанию страницы ListObjects
+const MaxPageSize = 100
+
+// Операции узлов дерева Expand
+const (
+	OpLeaf         = "leaf"
+	OpUnion        = "union"
+	OpIntersection = "intersection"
+	OpExclusion    = "exclusion"
+)
+
+// TupleReader кортежи одного приложения
+type TupleReader interface {
+	RelationTuples(ctx context.Context, namespace, objectID, relation string) ([]models.RelationTuple, error)
+	// ObjectIDs до limit id объектов пространства имен, у которых есть кортежи, по возрастанию и после after
+	ObjectIDs(ctx context.Context, namespace, after string, limit int) ([]string, error)
+}
+
+// Tree дерево субъектов отношения. В листьях - субъекты кортежей; userset субъекты вроде group:eng#member
+// не раскрываются, для них нужен отдельный Expand
+type Tree struct {
+	Operation string
+	Object    Object
+	Relation  string
+	Subjects  []Subject
+	Children  []*Tree
+}
+
+// Check входит ли subject в отношение relation объекта
+func Check(
+	ctx context.Context,
+	schema *Schema,
+	tuples TupleReader,
+	object Object,
+	relation string,
+	subject Subject,
+) (bool, error) {
+	if _, ok := schema.relation(object.Namespace, relation); !ok {
+		return false, fmt.Errorf("%w %s#%s", ErrUnknownRelation, object.Namespace, relation)
+	}
+
+	c := newChecker(schema, tuples, subject)
+
+	return c.check(ctx, object, relation, 0)
+}
+
+// ListObjects страница id объектов пространства имен, в отношение relation которых входит subject, по
+// возрастанию после after. Кандидаты - объекты с кортежами: у объекта без кортежей нет и вычисляемых
+// отношений. Все кандидаты страницы делят память и бюджет одной проверки: когда бюджет кончается,
+// возвращается уже найденное. next - курсор следующей страницы, пустой, если кандидатов больше нет
+func ListObjects(
+	ctx context.Context,
+	schema *Schema,
+	tuples TupleReader,
+	namespace, relation string,
+	subject Subject,
+	after string,
+	limit int,
+) (objects []string, next string, err error) {
+	if _, ok := schema.relation(namespace, relation); !ok {
+		return nil, "", fmt.Errorf("%w %s#%s", ErrUnknownRelation, namespace, relation)
+	}
+
+	if limit <= 0 || limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	c := newChecker(schema, tuples, subject)
+	scanned := after
+
+	for {
+		ids, err := tuples.ObjectIDs(ctx, namespace, scanned, limit)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		for _, id := range ids {
+			ok, err := c.check(ctx, Object{Namespace: namespace, ID: id}, relation, 0)
+
+			//бюджет кончился на этом кандидате: следующая страница начнется с него
+			if errors.Is(err, ErrBudgetExceeded) && scanned != after {
+				return objects, scanned, nil
+			}
+
+			if err != nil {
+				return nil, "", err
+			}
+
+			scanned = id
+
+			if ok {
+				objects = append(objects, id)
+			}
+
+			if len(objects) == limit {
+				return objects, scanned, nil
+			}
+		}
+
+		if len(ids) < limit {
+			return objects, "", nil
+		}
+	}
+}
+
+// Expand дерево субъектов отношения relation объекта
+func Expand(ctx context.Context, schema *Schema, tuples TupleReader, object Object, relation string) (*Tree, error) {
+	if _, ok := schema.relation(object.Namespace, relation); !ok {
+		return nil, fmt.Errorf("%w %s#%s", ErrUnknownRelation, object.Namespace, relation)
+	}
+
+	e := expander{schema: schema, tuples: tuples, path: map[string]bool{}}
+
+	return e.expand(ctx, object, relation, 0)
+}
+
+type checker struct {
+	schema  *Schema
+	tuples  TupleReader
+	subject Subject
+	//отношения на текущем пути вычисления и число исключений (subtract) над ними. Повторный вход
+	//в то же отношение ничего не добавляет, пока цикл не проходит через исключение: тогда отброшенный
+	//цикл превратил бы "неизвестно" в "не исключен", и проверка ошибочно разрешила бы доступ
+	path map[string]int
+	//число subtract веток, внутри которых идет вычисление
+	negations int
+	//memo вычисленные отношения object#relation: субъект у проверки один, поэтому ответ зависит только от ключа
+	memo map[string]bool
+	//checks число вычисленных отношений, pruned - отброшенных циклов
+	checks int
+	pruned int
+}
+
+func newChecker(schema *Schema, tuples TupleReader, subject Subject) *checker {
+	return &checker{
+		schema:  schema,
+		tuples:  tuples,
+		subject: subject,
+		path:    map[string]int{},
+		memo:    map[string]bool{},
+	}
+}
+
+func (c *checker) check(ctx context.Context, object Object, relation string, depth int) (bool, error) {
+	if depth > MaxDepth {
+		return false, ErrDepthExceeded
+	}
+
+	//userset субъект входит в самого себя
+	if c.subject.Relation == relation && c.subject.Namespace == object.Namespace && c.subject.ID == object.ID {
+		return true, nil
+	}
+
+	//отношение, которого нет в схеме (например, удаленное из нее), пусто
+	rewrite, ok := c.schema.relation(object.Namespace, relation)
+
+	if !ok {
+		return false, nil
+	}
+
+	key := object.String() + "#" + relation
+
+	if ok, found := c.memo[key]; found {
+		return ok, nil
+	}
+
+	if negations, ok := c.path[key]; ok {
+		if negations != c.negations {
+			return false, fmt.Errorf("%w: %s", ErrCyclicExclusion, key)
+		}
+		c.pruned++
+		return false, nil
+	}
+
+	c.checks++
+
+	if c.checks > MaxChecks {
+		return false, ErrBudgetExceeded
+	}
+
+	c.path[key] = c.negations
+	defer delete(c.path, key)
+
+	pruned := c.pruned
+
+	ok, err := c.rewrite(ctx, object, relation, rewrite, depth)
+
+	//ответ, при вычислении которого отброшен цикл, зависит от пути к отношению, и его не запоминаем
+	if err == nil && c.pruned == pruned {
+		c.memo[key] = ok
+	}
+
+	return ok, err
+}
+
+func (c *checker) rewrite(ctx context.Context, object Object, relation string, r *Rewrite, depth int) (bool, error) {
+	switch {
+	case r.This != nil:
+		tuples, err := c.tuples.RelationTuples(ctx, object.Namespace, object.ID, relation)
+
+		if err != nil {
+			return false, err
+		}
+
+		for _, t := range tuples {
+			if SubjectOf(t) == c.subject {
+				return true, nil
+			}
+		}
+
+		for _, t := range tuples {
+			if t.SubjectRelation == "" {
+				continue
+			}
+
+			ok, err := c.check(ctx, Object{Namespace: t.SubjectNamespace, ID: t.SubjectID}, t.SubjectRelation, depth+1)
+
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+
+		return false, nil
+
+	case r.ComputedUserset != nil:
+		return c.check(ctx, object, r.ComputedUserset.Relation, depth+1)
+
+	case r.TupleToUserset != nil:
+		tuples, err := c.tuples.RelationTuples(ctx, object.Namespace, object.ID, r.TupleToUserset.Tupleset)
+
+		if err != nil {
+			return false, err
+		}
+
+		for _, t := range tuples {
+			ok, err := c.check(ctx, Object{Namespace: t.SubjectNamespace, ID: t.SubjectID}, r.TupleToUserset.ComputedUserset, depth+1)
+
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+
+		return false, nil
+
+	case r.Union != nil:
+		for _, child := range r.Union {
+			ok, err := c.rewrite(ctx, object, relation, child, depth)
+
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+
+		return false, nil
+
+	case r.Intersection != nil:
+		for _, child := range r.Intersection {
+			ok, err := c.rewrite(ctx, object, relation, child, depth)
+
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+
+		return true, nil
+
+	case r.Exclusion != nil:
+		ok, err := c.rewrite(ctx, object, relation, r.Exclusion.Base, depth)
+
+		if err != nil || !ok {
+			return false, err
+		}
+
+		c.negations++
+		excluded, err := c.rewrite(ctx, object, relation, r.Exclusion.Subtract, depth)
+		c.negations--
+
+		if err != nil {
+			return false, err
+		}
+
+		return !excluded, nil
+	}
+
+	return false, nil
+}
+
+type expander struct {
+	schema *Schema
+	tuples TupleReader
+	path   map[string]bool
+	//nodes число построенных узлов дерева
+	nodes int
+}
+
+func (e *expander) expand(ctx context.Context, object Object, relation string, depth int) (*Tree, error) {
+	if depth > MaxDepth {
+		return nil, ErrDepthExceeded
+	}
+
+	e.nodes++
+
+	if e.nodes > MaxExpandNodes {
+		return nil, ErrBudgetExceeded
+	}
+
+	rewrite, ok := e.schema.relation(object.Namespace, relation)
+	key := object.String() + "#" + relation
+
+	//пустое или уже раскрываемое выше по дереву отношение - пустой лист
+	if !ok || e.path[key] {
+		return &Tree{Operation: OpLeaf, Object: object, Relation: relation}, nil
+	}
+
+	e.path[key] = true
+	defer delete(e.path, key)
+
+	return e.rewrite(ctx, object, relation, rewrite, depth)
+}
+
+func (e *expander) rewrite(ctx context.Context, object Object, relation string, r *Rewrite, depth int) (*Tree, error) {
+	switch {
+	case r.This != nil:
+		tuples, err := e.tuples.RelationTuples(ctx, object.Namespace, object.ID, relation)
+
+		if err != nil {
+			return nil, err
+		}
+
+		leaf := &Tree{Operation: OpLeaf, Object: object, Relation: relation}
+
+		for _, t := range tuples {
+			leaf.Subjects = append(leaf.Subjects, SubjectOf(t))
+		}
+
+		return leaf, nil
+
+	case r.ComputedUserset != nil:
+		return e.expand(ctx, object, r.ComputedUserset.Relation, depth+1)
+
+	case r.TupleToUserset != nil:
+		tuples, err := e.tuples.RelationTuples(ctx, object.Namespace, object.ID, r.TupleToUserset.Tupleset)
+
+		if err != nil {
+			return nil, err
+		}
+
+		node := &Tree{Operation: OpUnion, Object: object, Relation: relation}
+
+		for _, t := range tuples {
+			child, err := e.expand(ctx, Object{Namespace: t.SubjectNamespace, ID: t.SubjectID}, r.TupleToUserset.ComputedUserset, depth+1)
+
+			if err != nil {
+				return nil, err
+			}
+
+			node.Children = append(node.Children, child)
+		}
+
+		return node, nil
+	}
+
+	node := &Tree{Object: object, Relation: relation}
+	var rewrites []*Rewrite
+
+	switch {
+	case r.Union != nil:
+		node.Operation, rewrites = OpUnion, r.Union
+	case r.Intersection != nil:
+		node.Operation, rewrites = OpIntersection, r.Intersection
+	case r.Exclusion != nil:
+		node.Operation, rewrites = OpExclusion, []*Rewrite{r.Exclusion.Base, r.Exclusion.Subtract}
+	}
+
+	for _, child := range rewrites {
+		tree, err := e.rewrite(ctx, object, relation, child, depth)
+
+		if err != nil {
+			return nil, err
+		}
+
+		node.Children = append(node.Children, tree)
+	}
+
+	return node, nil
+}
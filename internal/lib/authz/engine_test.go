@@ -0,0 +1,392 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sso/internal/domain/models"
+	"strconv"
+	"testing"
+)
+
+const testSchema = `{"namespaces": {
+	"user": {},
+	"group": {"relations": {"member": {}}},
+	"folder": {"relations": {
+		"owner": {},
+		"viewer": {"union": [{"this": {}}, {"computed_userset": {"relation": "owner"}}]}
+	}},
+	"document": {"relations": {
+		"parent": {},
+		"owner": {},
+		"banned": {},
+		"editor": {"union": [{}, {"computed_userset": {"relation": "owner"}}]},
+		"viewer": {"union": [
+			{"this": {}},
+			{"computed_userset": {"relation": "editor"}},
+			{"tuple_to_userset": {"tupleset": "parent", "computed_userset": "viewer"}}
+		]},
+		"reader": {"exclusion": {"base": {"computed_userset": {"relation": "viewer"}}, "subtract": {"computed_userset": {"relation": "banned"}}}},
+		"auditor": {"intersection": [{"computed_userset": {"relation": "viewer"}}, {"this": {}}]}
+	}}
+}}`
+
+// memoryTuples кортежи в памяти
+type memoryTuples []models.RelationTuple
+
+func (m memoryTuples) RelationTuples(_ context.Context, namespace, objectID, relation string) ([]models.RelationTuple, error) {
+	var res []models.RelationTuple
+
+	for _, t := range m {
+		if t.Namespace == namespace && t.ObjectID == objectID && t.Relation == relation {
+			res = append(res, t)
+		}
+	}
+
+	return res, nil
+}
+
+func (m memoryTuples) ObjectIDs(_ context.Context, namespace, after string, limit int) ([]string, error) {
+	seen := map[string]bool{}
+	var ids []string
+
+	for _, t := range m {
+		if t.Namespace == namespace && t.ObjectID > after && !seen[t.ObjectID] {
+			seen[t.ObjectID] = true
+			ids = append(ids, t.ObjectID)
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids[:min(limit, len(ids))], nil
+}
+
+func mustTuples(t *testing.T, tuples ...string) memoryTuples {
+	t.Helper()
+
+	var res memoryTuples
+
+	for _, s := range tuples {
+		tuple, err := ParseTuple(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, tuple)
+	}
+
+	return res
+}
+
+func mustSchema(t *testing.T) *Schema {
+	t.Helper()
+
+	schema, err := ParseSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return schema
+}
+
+func TestCheck(t *testing.T) {
+	schema := mustSchema(t)
+	tuples := mustTuples(t,
+		"group:eng#member@user:alice",
+		"group:eng#member@group:leads#member",
+		"group:leads#member@user:carol",
+		"folder:root#owner@user:dave",
+		"folder:root#viewer@group:eng#member",
+		"document:readme#parent@folder:root",
+		"document:readme#owner@user:bob",
+		"document:readme#banned@user:alice",
+		"document:readme#auditor@user:bob",
+		"document:readme#auditor@user:eve",
+	)
+
+	tests := []struct {
+		object, relation, subject string
+		want                      bool
+	}{
+		{"document:readme", "owner", "user:bob", true},
+		{"document:readme", "editor", "user:bob", true},
+		{"document:readme", "viewer", "user:bob", true},
+		{"document:readme", "viewer", "user:alice", true},
+		{"document:readme", "viewer", "user:carol", true},
+		{"document:readme", "viewer", "user:dave", true},
+		{"document:readme", "viewer", "user:eve", false},
+		{"document:readme", "editor", "user:alice", false},
+		{"document:readme", "viewer", "group:eng#member", true},
+		{"document:readme", "reader", "user:carol", true},
+		{"document:readme", "reader", "user:alice", false},
+		{"document:readme", "auditor", "user:bob", true},
+		{"document:readme", "auditor", "user:eve", false},
+		{"document:other", "viewer", "user:bob", false},
+	}
+
+	for _, tt := range tests {
+		object, err := ParseObject(tt.object)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		subject, err := ParseSubject(tt.subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := Check(context.Background(), schema, tuples, object, tt.relation, subject)
+		if err != nil {
+			t.Fatalf("Check(%s#%s@%s): %v", tt.object, tt.relation, tt.subject, err)
+		}
+
+		if got != tt.want {
+			t.Errorf("Check(%s#%s@%s) = %v, want %v", tt.object, tt.relation, tt.subject, got, tt.want)
+		}
+	}
+
+	_, err := Check(context.Background(), schema, tuples, Object{"document", "readme"}, "unknown", Subject{Namespace: "user", ID: "bob"})
+	if !errors.Is(err, ErrUnknownRelation) {
+		t.Errorf("expected ErrUnknownRelation, got %v", err)
+	}
+}
+
+func TestCheck_Cycles(t *testing.T) {
+	schema := mustSchema(t)
+
+	//группы, вложенные друг в друга, не зацикливают проверку
+	tuples := mustTuples(t,
+		"group:a#member@group:b#member",
+		"group:b#member@group:a#member",
+		"group:b#member@user:alice",
+	)
+
+	ok, err := Check(context.Background(), schema, tuples, Object{"group", "a"}, "member", Subject{Namespace: "user", ID: "alice"})
+	if err != nil || !ok {
+		t.Errorf("expected member through cycle, got %v %v", ok, err)
+	}
+
+	ok, err = Check(context.Background(), schema, tuples, Object{"group", "a"}, "member", Subject{Namespace: "user", ID: "bob"})
+	if err != nil || ok {
+		t.Errorf("expected non-member, got %v %v", ok, err)
+	}
+}
+
+func TestCheck_CyclicExclusion(t *testing.T) {
+	schema := mustSchema(t)
+
+	//reader = viewer - banned, а banned ссылается на reader: без ошибки цикл считался бы "не забанен"
+	tuples := mustTuples(t,
+		"document:x#viewer@user:alice",
+		"document:x#banned@document:x#reader",
+	)
+
+	_, err := Check(context.Background(), schema, tuples, Object{"document", "x"}, "reader", Subject{Namespace: "user", ID: "alice"})
+	if !errors.Is(err, ErrCyclicExclusion) {
+		t.Errorf("expected ErrCyclicExclusion, got %v", err)
+	}
+}
+
+func TestCheck_DepthExceeded(t *testing.T) {
+	schema := mustSchema(t)
+
+	var chain []string
+
+	for i := 0; i <= MaxDepth+1; i++ {
+		chain = append(chain, "group:g"+string(rune('a'+i))+"#member@group:g"+string(rune('a'+i+1))+"#member")
+	}
+
+	_, err := Check(context.Background(), schema, mustTuples(t, chain...), Object{"group", "ga"}, "member", Subject{Namespace: "user", ID: "alice"})
+	if !errors.Is(err, ErrDepthExceeded) {
+		t.Errorf("expected ErrDepthExceeded, got %v", err)
+	}
+}
+
+// countingTuples считает запросы кортежей
+type countingTuples struct {
+	memoryTuples
+	queries int
+}
+
+func (c *countingTuples) RelationTuples(ctx context.Context, namespace, objectID, relation string) ([]models.RelationTuple, error) {
+	c.queries++
+	return c.memoryTuples.RelationTuples(ctx, namespace, objectID, relation)
+}
+
+func TestCheck_Memo(t *testing.T) {
+	schema := mustSchema(t)
+
+	//десять групп включают одну общую: общая группа вычисляется один раз
+	var raw []string
+
+	for i := range 10 {
+		g := "group:g" + string(rune('a'+i))
+		raw = append(raw, "group:top#member@"+g+"#member", g+"#member@group:shared#member")
+	}
+
+	tuples := &countingTuples{memoryTuples: mustTuples(t, raw...)}
+
+	ok, err := Check(context.Background(), schema, tuples, Object{"group", "top"}, "member", Subject{Namespace: "user", ID: "bob"})
+	if err != nil || ok {
+		t.Fatalf("expected non-member, got %v %v", ok, err)
+	}
+
+	if want := 1 + 10 + 1; tuples.queries != want {
+		t.Errorf("expected %d tuple queries, got %d", want, tuples.queries)
+	}
+}
+
+func TestCheck_BudgetExceeded(t *testing.T) {
+	schema := mustSchema(t)
+
+	var raw []string
+
+	for i := range MaxChecks {
+		raw = append(raw, "group:top#member@group:g"+strconv.Itoa(i)+"#member")
+	}
+
+	_, err := Check(context.Background(), schema, mustTuples(t, raw...), Object{"group", "top"}, "member", Subject{Namespace: "user", ID: "bob"})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected ErrBudgetExceeded, got %v", err)
+	}
+}
+
+func TestListObjects(t *testing.T) {
+	schema := mustSchema(t)
+	tuples := mustTuples(t,
+		"folder:root#viewer@user:alice",
+		"document:a#parent@folder:root",
+		"document:b#owner@user:alice",
+		"document:c#owner@user:bob",
+	)
+
+	got, next, err := ListObjects(context.Background(), schema, tuples, "document", "viewer", Subject{Namespace: "user", ID: "alice"}, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) || next != "" {
+		t.Errorf("ListObjects = %v %q, want %v", got, next, want)
+	}
+}
+
+func TestListObjects_Pages(t *testing.T) {
+	schema := mustSchema(t)
+	tuples := mustTuples(t,
+		"document:a#owner@user:alice",
+		"document:b#owner@user:bob",
+		"document:c#owner@user:alice",
+		"document:d#owner@user:bob",
+		"document:e#owner@user:alice",
+	)
+	alice := Subject{Namespace: "user", ID: "alice"}
+
+	var pages [][]string
+	after := ""
+
+	for {
+		got, next, err := ListObjects(context.Background(), schema, tuples, "document", "viewer", alice, after, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pages = append(pages, got)
+
+		if next == "" {
+			break
+		}
+		after = next
+	}
+
+	if want := [][]string{{"a", "c"}, {"e"}}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+}
+
+func TestListObjects_BudgetEndsPage(t *testing.T) {
+	schema := mustSchema(t)
+
+	//до owner проверка каждого документа обходит 20 групп: бюджет кончается раньше, чем страница
+	var raw []string
+
+	for i := range MaxPageSize {
+		raw = append(raw, fmt.Sprintf("document:d%03d#owner@user:alice", i))
+
+		for j := range 20 {
+			raw = append(raw, fmt.Sprintf("document:d%03d#viewer@group:g%03d%02d#member", i, i, j))
+		}
+	}
+
+	got, next, err := ListObjects(context.Background(), schema, mustTuples(t, raw...), "document", "viewer", Subject{Namespace: "user", ID: "alice"}, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) == 0 || len(got) == MaxPageSize || next != fmt.Sprintf("d%03d", len(got)-1) {
+		t.Errorf("expected partial page, got %d objects, next %q", len(got), next)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	schema := mustSchema(t)
+	tuples := mustTuples(t,
+		"folder:root#viewer@group:eng#member",
+		"document:readme#parent@folder:root",
+		"document:readme#owner@user:bob",
+		"document:readme#viewer@user:alice",
+	)
+
+	tree, err := Expand(context.Background(), schema, tuples, Object{"document", "readme"}, "viewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tree.Operation != OpUnion || len(tree.Children) != 3 {
+		t.Fatalf("expected union of 3, got %s of %d", tree.Operation, len(tree.Children))
+	}
+
+	direct := tree.Children[0]
+	if direct.Operation != OpLeaf || !reflect.DeepEqual(direct.Subjects, []Subject{{Namespace: "user", ID: "alice"}}) {
+		t.Errorf("unexpected direct viewers %+v", direct)
+	}
+
+	//editor = this ∪ owner
+	editor := tree.Children[1]
+	if editor.Relation != "editor" || len(editor.Children) != 2 ||
+		!reflect.DeepEqual(editor.Children[1].Subjects, []Subject{{Namespace: "user", ID: "bob"}}) {
+		t.Errorf("unexpected editors %+v", editor)
+	}
+
+	//viewer папки: кортеж с userset субъектом не раскрывается
+	parent := tree.Children[2]
+	if len(parent.Children) != 1 || parent.Children[0].Object != (Object{"folder", "root"}) {
+		t.Fatalf("unexpected parent viewers %+v", parent)
+	}
+
+	folderDirect := parent.Children[0].Children[0]
+	if !reflect.DeepEqual(folderDirect.Subjects, []Subject{{Namespace: "group", ID: "eng", Relation: "member"}}) {
+		t.Errorf("unexpected folder viewers %+v", folderDirect)
+	}
+}
+
+func TestExpand_BudgetExceeded(t *testing.T) {
+	schema := mustSchema(t)
+
+	//у каждого документа уровня два родителя следующего уровня: без предела дерево удваивается на уровень
+	var raw []string
+
+	for level := range 12 {
+		for _, i := range []string{"a", "b"} {
+			for _, j := range []string{"a", "b"} {
+				raw = append(raw, fmt.Sprintf("document:d%d%s#parent@document:d%d%s", level, i, level+1, j))
+			}
+		}
+	}
+
+	_, err := Expand(context.Background(), schema, mustTuples(t, raw...), Object{"document", "d0a"}, "viewer")
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected ErrBudgetExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package authz
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+)
+
+var ErrInvalidSchema = errors.New("invalid authz schema")
+
+// Schema пространства имен объектов приложения. Пример:
+//
+//	{"namespaces": {
+//	  "user": {},
+//	  "group": {"relations": {"member": {}}},
+//	  "document": {"relations": {
+//	    "parent": {},
+//	    "owner": {},
+//	    "viewer": {"union": [
+//	      {"this": {}},
+//	      {"computed_userset": {"relation": "owner"}},
+//	      {"tuple_to_userset": {"tupleset": "parent", "computed_userset": "viewer"}}
+//	    ]}
+//	  }}
+//	}}
+type Schema struct {
+	Namespaces map[string]Namespace `json:"namespaces"`
+}
+
+type Namespace struct {
+	Relations map[string]*Rewrite `json:"relations,omitempty"`
+}
+
+// Rewrite правило вычисления отношения; задается ровно одно поле. Пустое правило - this:
+// субъекты кортежей самого отношения
+type Rewrite struct {
+	This            *struct{}        `json:"this,omitempty"`
+	ComputedUserset *ComputedUserset `json:"computed_userset,omitempty"`
+	TupleToUserset  *TupleToUserset  `json:"tuple_to_userset,omitempty"`
+	Union           []*Rewrite       `json:"union,omitempty"`
+	Intersection    []*Rewrite       `json:"intersection,omitempty"`
+	Exclusion       *Exclusion       `json:"exclusion,omitempty"`
+}
+
+// ComputedUserset субъекты другого отношения того же объекта
+type ComputedUserset struct {
+	Relation string `json:"relation"`
+}
+
+// TupleToUserset субъекты отношения ComputedUserset объектов, на которые указывают кортежи Tupleset,
+// например viewer папки документа
+type TupleToUserset struct {
+	Tupleset        string `json:"tupleset"`
+	ComputedUserset string `json:"computed_userset"`
+}
+
+// Exclusion субъекты Base, кроме субъектов Subtract
+type Exclusion struct {
+	Base     *Rewrite `json:"base"`
+	Subtract *Rewrite `json:"subtract"`
+}
+
+// ParseSchema разбирает и проверяет схему; пустые правила заменяются на this
+func ParseSchema(data []byte) (*Schema, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var schema Schema
+
+	if err := dec.Decode(&schema); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+
+	if dec.More() {
+		return nil, fmt.Errorf("%w: unexpected data after schema", ErrInvalidSchema)
+	}
+
+	if len(schema.Namespaces) == 0 {
+		return nil, fmt.Errorf("%w: no namespaces", ErrInvalidSchema)
+	}
+
+	for name, ns := range schema.Namespaces {
+		if len(name) > maxNameLength || !nameRe.MatchString(name) {
+			return nil, fmt.Errorf("%w: namespace %q must match %s", ErrInvalidSchema, name, nameRe)
+		}
+
+		for relation, rewrite := range ns.Relations {
+			if len(relation) > maxNameLength || !nameRe.MatchString(relation) {
+				return nil, fmt.Errorf("%w: relation %s#%q must match %s", ErrInvalidSchema, name, relation, nameRe)
+			}
+
+			rewrite, err := normalize(rewrite)
+
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s#%s: %v", ErrInvalidSchema, name, relation, err)
+			}
+
+			ns.Relations[relation] = rewrite
+		}
+	}
+
+	//ссылки проверяются после нормализации всех отношений
+	for name, ns := range schema.Namespaces {
+		for relation, rewrite := range ns.Relations {
+			if err := schema.validateRefs(ns, rewrite); err != nil {
+				return nil, fmt.Errorf("%w: %s#%s: %v", ErrInvalidSchema, name, relation, err)
+			}
+		}
+	}
+
+	return &schema, nil
+}
+
+// AllowsDirect отношение принимает кортежи: в его правиле есть this
+func (s *Schema) AllowsDirect(namespace, relation string) bool {
+	rewrite, ok := s.relation(namespace, relation)
+	return ok && hasThis(rewrite)
+}
+
+// ValidateTuple кортеж соответствует схеме: отношение объекта принимает кортежи, а субъект ссылается
+// на объявленные пространство имен и отношение
+func (s *Schema) ValidateTuple(t models.RelationTuple) error {
+	if _, ok := s.relation(t.Namespace, t.Relation); !ok {
+		return fmt.Errorf("%w: unknown relation %s#%s", ErrInvalidTuple, t.Namespace, t.Relation)
+	}
+
+	if !s.AllowsDirect(t.Namespace, t.Relation) {
+		return fmt.Errorf("%w: relation %s#%s is computed and does not accept tuples", ErrInvalidTuple, t.Namespace, t.Relation)
+	}
+
+	return s.ValidateSubject(SubjectOf(t))
+}
+
+// ValidateSubject пространство имен и отношение субъекта объявлены в схеме
+func (s *Schema) ValidateSubject(subject Subject) error {
+	if _, ok := s.Namespaces[subject.Namespace]; !ok {
+		return fmt.Errorf("%w: unknown namespace %q", ErrInvalidTuple, subject.Namespace)
+	}
+
+	if subject.Relation == "" {
+		return nil
+	}
+
+	if _, ok := s.relation(subject.Namespace, subject.Relation); !ok {
+		return fmt.Errorf("%w: unknown relation %s#%s", ErrInvalidTuple, subject.Namespace, subject.Relation)
+	}
+
+	return nil
+}
+
+func (s *Schema) relation(namespace, relation string) (*Rewrite, bool) {
+	rewrite, ok := s.Namespaces[namespace].Relations[relation]
+	return rewrite, ok
+}
+
+// normalize проверяет, что в каждом узле задано одно правило, и заменяет пустые узлы на this
+func normalize(r *Rewrite) (*Rewrite, error) {
+	if r == nil {
+		return &Rewrite{This: &struct{}{}}, nil
+	}
+
+	kinds := 0
+
+	for _, set := range []bool{
+		r.This != nil,
+		r.ComputedUserset != nil,
+		r.TupleToUserset != nil,
+		r.Union != nil,
+		r.Intersection != nil,
+		r.Exclusion != nil,
+	} {
+		if set {
+			kinds++
+		}
+	}
+
+	switch {
+	case kinds == 0:
+		r.This = &struct{}{}
+	case kinds > 1:
+		return nil, errors.New("rewrite must set exactly one of this, computed_userset, tuple_to_userset, union, intersection, exclusion")
+	}
+
+	var err error
+
+	switch {
+	case r.Union != nil:
+		r.Union, err = normalizeAll("union", r.Union)
+	case r.Intersection != nil:
+		r.Intersection, err = normalizeAll("intersection", r.Intersection)
+	case r.Exclusion != nil:
+		if r.Exclusion.Base == nil || r.Exclusion.Subtract == nil {
+			return nil, errors.New("exclusion requires base and subtract")
+		}
+		if r.Exclusion.Base, err = normalize(r.Exclusion.Base); err != nil {
+			return nil, err
+		}
+		r.Exclusion.Subtract, err = normalize(r.Exclusion.Subtract)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func normalizeAll(kind string, rewrites []*Rewrite) ([]*Rewrite, error) {
+	if len(rewrites) == 0 {
+		return nil, fmt.Errorf("%s requires at least one rewrite", kind)
+	}
+
+	for i, r := range rewrites {
+		r, err := normalize(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rewrites[i] = r
+	}
+
+	return rewrites, nil
+}
+
+// validateRefs отношения, на которые ссылается правило, объявлены в пространстве имен. Отношение
+// tuple_to_userset проверяется при вычислении: объекты tupleset могут быть из разных пространств имен
+func (s *Schema) validateRefs(ns Namespace, r *Rewrite) error {
+	switch {
+	case r.ComputedUserset != nil:
+		if _, ok := ns.Relations[r.ComputedUserset.Relation]; !ok {
+			return fmt.Errorf("computed_userset refers to unknown relation %q", r.ComputedUserset.Relation)
+		}
+	case r.TupleToUserset != nil:
+		tupleset, ok := ns.Relations[r.TupleToUserset.Tupleset]
+
+		if !ok {
+			return fmt.Errorf("tuple_to_userset refers to unknown tupleset %q", r.TupleToUserset.Tupleset)
+		}
+
+		if !hasThis(tupleset) {
+			return fmt.Errorf("tupleset %q does not accept tuples", r.TupleToUserset.Tupleset)
+		}
+
+		if !nameRe.MatchString(r.TupleToUserset.ComputedUserset) {
+			return fmt.Errorf("tuple_to_userset computed_userset %q must match %s", r.TupleToUserset.ComputedUserset, nameRe)
+		}
+	}
+
+	for _, child := range children(r) {
+		if err := s.validateRefs(ns, child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hasThis(r *Rewrite) bool {
+	if r.This != nil {
+		return true
+	}
+
+	for _, child := range children(r) {
+		if hasThis(child) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func children(r *Rewrite) []*Rewrite {
+	switch {
+	case r.Union != nil:
+		return r.Union
+	case r.Intersection != nil:
+		return r.Intersection
+	case r.Exclusion != nil:
+		return []*Rewrite{r.Exclusion.Base, r.Exclusion.Subtract}
+	}
+	return nil
+}
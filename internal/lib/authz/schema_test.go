@@ -0,0 +1,128 @@
+package authz
+
+import (
+	"errors"
+	"sso/internal/domain/models"
+	"testing"
+)
+
+func TestParseTuple(t *testing.T) {
+	tuple, err := ParseTuple("group:eng#member@group:leads#member")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := models.RelationTuple{
+		Namespace:        "group",
+		ObjectID:         "eng",
+		Relation:         "member",
+		SubjectNamespace: "group",
+		SubjectID:        "leads",
+		SubjectRelation:  "member",
+	}
+
+	if tuple != want {
+		t.Errorf("ParseTuple = %+v, want %+v", tuple, want)
+	}
+
+	//id может содержать двоеточие
+	for _, s := range []string{"group:eng#member@group:leads#member", "document:a:b#viewer@user:42"} {
+		tuple, err := ParseTuple(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := FormatTuple(tuple); got != s {
+			t.Errorf("FormatTuple(ParseTuple(%q)) = %q", s, got)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"document:readme#viewer",
+		"document:readme@user:42",
+		"document#viewer@user:42",
+		"document:#viewer@user:42",
+		"Document:readme#viewer@user:42",
+		"document:read me#viewer@user:42",
+		"document:readme#viewer@user",
+		"document:readme#viewer@user:42#",
+	} {
+		if _, err := ParseTuple(s); !errors.Is(err, ErrInvalidTuple) {
+			t.Errorf("ParseTuple(%q): expected ErrInvalidTuple, got %v", s, err)
+		}
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	schema := mustSchema(t)
+
+	if !schema.AllowsDirect("document", "editor") {
+		t.Error("expected empty rewrite in union to accept tuples")
+	}
+
+	if schema.AllowsDirect("document", "reader") {
+		t.Error("expected computed relation to reject tuples")
+	}
+
+	invalid := map[string]string{
+		"not json":           `{`,
+		"no namespaces":      `{"namespaces": {}}`,
+		"unknown field":      `{"namespaces": {"user": {"relation": {}}}}`,
+		"bad namespace":      `{"namespaces": {"User": {}}}`,
+		"bad relation":       `{"namespaces": {"doc": {"relations": {"view-er": {}}}}}`,
+		"two kinds":          `{"namespaces": {"doc": {"relations": {"a": {}, "b": {"this": {}, "computed_userset": {"relation": "a"}}}}}}`,
+		"unknown computed":   `{"namespaces": {"doc": {"relations": {"a": {"computed_userset": {"relation": "b"}}}}}}`,
+		"unknown tupleset":   `{"namespaces": {"doc": {"relations": {"a": {"tuple_to_userset": {"tupleset": "b", "computed_userset": "a"}}}}}}`,
+		"computed tupleset":  `{"namespaces": {"doc": {"relations": {"a": {}, "b": {"computed_userset": {"relation": "a"}}, "c": {"tuple_to_userset": {"tupleset": "b", "computed_userset": "a"}}}}}}`,
+		"empty union":        `{"namespaces": {"doc": {"relations": {"a": {"union": []}}}}}`,
+		"exclusion w/o base": `{"namespaces": {"doc": {"relations": {"a": {"exclusion": {"subtract": {}}}}}}}`,
+		"trailing data":      `{"namespaces": {"user": {}}} {}`,
+	}
+
+	for name, data := range invalid {
+		if _, err := ParseSchema([]byte(data)); !errors.Is(err, ErrInvalidSchema) {
+			t.Errorf("%s: expected ErrInvalidSchema, got %v", name, err)
+		}
+	}
+}
+
+func TestValidateTuple(t *testing.T) {
+	schema := mustSchema(t)
+
+	valid := []string{
+		"document:readme#viewer@user:42",
+		"document:readme#parent@folder:root",
+		"folder:root#viewer@group:eng#member",
+	}
+
+	for _, s := range valid {
+		tuple, err := ParseTuple(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := schema.ValidateTuple(tuple); err != nil {
+			t.Errorf("ValidateTuple(%q): %v", s, err)
+		}
+	}
+
+	invalid := []string{
+		"document:readme#unknown@user:42",
+		"unknown:readme#viewer@user:42",
+		"document:readme#reader@user:42",
+		"document:readme#viewer@robot:42",
+		"document:readme#viewer@group:eng#admin",
+	}
+
+	for _, s := range invalid {
+		tuple, err := ParseTuple(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := schema.ValidateTuple(tuple); !errors.Is(err, ErrInvalidTuple) {
+			t.Errorf("ValidateTuple(%q): expected ErrInvalidTuple, got %v", s, err)
+		}
+	}
+}
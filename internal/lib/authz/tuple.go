@@ -0,0 +1,155 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sso/internal/domain/models"
+	"strings"
+	"unicode"
+)
+
+var ErrInvalidTuple = errors.New("invalid relation tuple")
+
+const (
+	maxNameLength = 64
+	maxIDLength   = 256
+)
+
+var nameRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Object объект namespace:id
+type Object struct {
+	Namespace string
+	ID        string
+}
+
+func (o Object) String() string {
+	return o.Namespace + ":" + o.ID
+}
+
+// Subject субъект кортежа: объект namespace:id или, с Relation, множество субъектов его отношения
+// namespace:id#relation
+type Subject struct {
+	Namespace string
+	ID        string
+	Relation  string
+}
+
+func (s Subject) String() string {
+	if s.Relation == "" {
+		return s.Namespace + ":" + s.ID
+	}
+	return s.Namespace + ":" + s.ID + "#" + s.Relation
+}
+
+// ParseObject разбирает namespace:id
+func ParseObject(s string) (Object, error) {
+	namespace, id, ok := strings.Cut(s, ":")
+
+	if !ok {
+		return Object{}, fmt.Errorf("%w: object %q must be namespace:id", ErrInvalidTuple, s)
+	}
+
+	if err := validateName("namespace", namespace); err != nil {
+		return Object{}, err
+	}
+
+	if err := validateID(id); err != nil {
+		return Object{}, err
+	}
+
+	return Object{Namespace: namespace, ID: id}, nil
+}
+
+// ParseSubject разбирает namespace:id или namespace:id#relation
+func ParseSubject(s string) (Subject, error) {
+	object, relation, hasRelation := strings.Cut(s, "#")
+
+	o, err := ParseObject(object)
+
+	if err != nil {
+		return Subject{}, err
+	}
+
+	if hasRelation {
+		if err := validateName("relation", relation); err != nil {
+			return Subject{}, err
+		}
+	}
+
+	return Subject{Namespace: o.Namespace, ID: o.ID, Relation: relation}, nil
+}
+
+// ParseTuple разбирает кортеж в записи namespace:id#relation@subject
+func ParseTuple(s string) (models.RelationTuple, error) {
+	object, subject, ok := strings.Cut(s, "@")
+
+	if !ok {
+		return models.RelationTuple{}, fmt.Errorf("%w: %q must be namespace:id#relation@subject", ErrInvalidTuple, s)
+	}
+
+	object, relation, ok := strings.Cut(object, "#")
+
+	if !ok {
+		return models.RelationTuple{}, fmt.Errorf("%w: %q must be namespace:id#relation@subject", ErrInvalidTuple, s)
+	}
+
+	o, err := ParseObject(object)
+
+	if err != nil {
+		return models.RelationTuple{}, err
+	}
+
+	if err := validateName("relation", relation); err != nil {
+		return models.RelationTuple{}, err
+	}
+
+	sub, err := ParseSubject(subject)
+
+	if err != nil {
+		return models.RelationTuple{}, err
+	}
+
+	return models.RelationTuple{
+		Namespace:        o.Namespace,
+		ObjectID:         o.ID,
+		Relation:         relation,
+		SubjectNamespace: sub.Namespace,
+		SubjectID:        sub.ID,
+		SubjectRelation:  sub.Relation,
+	}, nil
+}
+
+// FormatTuple запись кортежа, обратная ParseTuple
+func FormatTuple(t models.RelationTuple) string {
+	return ObjectOf(t).String() + "#" + t.Relation + "@" + SubjectOf(t).String()
+}
+
+func ObjectOf(t models.RelationTuple) Object {
+	return Object{Namespace: t.Namespace, ID: t.ObjectID}
+}
+
+func SubjectOf(t models.RelationTuple) Subject {
+	return Subject{Namespace: t.SubjectNamespace, ID: t.SubjectID, Relation: t.SubjectRelation}
+}
+
+func validateName(kind, name string) error {
+	if len(name) > maxNameLength || !nameRe.MatchString(name) {
+		return fmt.Errorf("%w: %s %q must match %s", ErrInvalidTuple, kind, name, nameRe)
+	}
+	return nil
+}
+
+// validateID id объекта непустой и без разделителей записи кортежа
+func validateID(id string) error {
+	if id == "" || len(id) > maxIDLength {
+		return fmt.Errorf("%w: object id must be 1 to %d bytes", ErrInvalidTuple, maxIDLength)
+	}
+
+	if strings.ContainsAny(id, "#@") || strings.IndexFunc(id, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("%w: object id %q must not contain '#', '@' or spaces", ErrInvalidTuple, id)
+	}
+
+	return nil
+}
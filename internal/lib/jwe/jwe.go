@@ -0,0 +1,128 @@
+// Package jwe вкладывает подписанный JWT в JWE (RFC 7516), зашифрованный на публичный ключ приложения.
+// Такой токен могут прочитать только владелец приватного ключа приложения, а не браузер и не логи
+package jwe
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-jose/go-jose/v4"
+	"sso/internal/lib/jwks"
+	"strings"
+)
+
+const (
+	// KeyAlgRSA и KeyAlgEC алгоритмы шифрования ключа содержимого для RSA и EC ключей приложения
+	KeyAlgRSA = jose.RSA_OAEP_256
+	KeyAlgEC  = jose.ECDH_ES_A256KW
+	// ContentEnc алгоритм шифрования вложенного JWT
+	ContentEnc = jose.A256GCM
+
+	minRSAKeyBits = 2048
+)
+
+var ErrUnsupportedKey = errors.New("unsupported encryption key")
+
+// IsToken сообщает, что токен в compact сериализации JWE: у JWE пять частей, у JWS - три
+func IsToken(token string) bool {
+	return strings.Count(token, ".") == 4
+}
+
+// ParseKey разбирает и проверяет публичный JWK, который регистрирует приложение.
+// Поддерживаются RSA не короче 2048 бит и EC P-256/P-384/P-521; приватные ключи отклоняются
+func ParseKey(raw []byte) (jwks.JWK, error) {
+	var fields map[string]any
+
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return jwks.JWK{}, fmt.Errorf("%w: %w", ErrUnsupportedKey, err)
+	}
+
+	if _, ok := fields["d"]; ok {
+		return jwks.JWK{}, fmt.Errorf("%w: private key must not be registered", ErrUnsupportedKey)
+	}
+
+	var jwk jwks.JWK
+
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return jwks.JWK{}, fmt.Errorf("%w: %w", ErrUnsupportedKey, err)
+	}
+
+	if jwk.Use != "" && jwk.Use != "enc" {
+		return jwks.JWK{}, fmt.Errorf("%w: key use is %s", ErrUnsupportedKey, jwk.Use)
+	}
+
+	if _, err := recipient(jwk); err != nil {
+		return jwks.JWK{}, err
+	}
+
+	return jwk, nil
+}
+
+// Encrypt вкладывает подписанный JWT в JWE на ключ приложения. cty JWT означает вложенный токен (RFC 7519, 5.2)
+func Encrypt(token string, raw []byte) (string, error) {
+	jwk, err := ParseKey(raw)
+	if err != nil {
+		return "", err
+	}
+
+	rcpt, err := recipient(jwk)
+	if err != nil {
+		return "", err
+	}
+
+	encrypter, err := jose.NewEncrypter(ContentEnc, rcpt, (&jose.EncrypterOptions{}).WithContentType("JWT"))
+	if err != nil {
+		return "", err
+	}
+
+	obj, err := encrypter.Encrypt([]byte(token))
+	if err != nil {
+		return "", err
+	}
+
+	return obj.CompactSerialize()
+}
+
+// Decrypt достает вложенный JWT приватным ключом приложения: *rsa.PrivateKey или *ecdsa.PrivateKey
+func Decrypt(token string, key any) (string, error) {
+	obj, err := jose.ParseEncryptedCompact(token, []jose.KeyAlgorithm{KeyAlgRSA, KeyAlgEC}, []jose.ContentEncryption{ContentEnc})
+	if err != nil {
+		return "", err
+	}
+
+	if cty, _ := obj.Header.ExtraHeaders[jose.HeaderContentType].(string); cty != "JWT" {
+		return "", fmt.Errorf("unexpected content type %q", cty)
+	}
+
+	payload, err := obj.Decrypt(key)
+	if err != nil {
+		return "", err
+	}
+
+	return string(payload), nil
+}
+
+func recipient(jwk jwks.JWK) (jose.Recipient, error) {
+	pub, err := jwk.PublicKey()
+	if err != nil {
+		return jose.Recipient{}, fmt.Errorf("%w: %w", ErrUnsupportedKey, err)
+	}
+
+	rcpt := jose.Recipient{Key: pub, KeyID: jwk.Kid}
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return jose.Recipient{}, fmt.Errorf("%w: rsa key is shorter than %d bits", ErrUnsupportedKey, minRSAKeyBits)
+		}
+		rcpt.Algorithm = KeyAlgRSA
+	case *ecdsa.PublicKey:
+		rcpt.Algorithm = KeyAlgEC
+	default:
+		return jose.Recipient{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, pub)
+	}
+
+	return rcpt, nil
+}
@@ -0,0 +1,125 @@
+package jwe
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"sso/internal/lib/jwks"
+	"testing"
+)
+
+func publicJWK(t *testing.T, pub crypto.PublicKey) []byte {
+	t.Helper()
+
+	jwk, err := jwks.FromPublicKey(pub, "")
+	if err != nil {
+		t.Fatalf("FromPublicKey: %v", err)
+	}
+	jwk.Use = "enc"
+
+	raw, err := json.Marshal(jwk)
+	if err != nil {
+		t.Fatalf("marshal jwk: %v", err)
+	}
+
+	return raw
+}
+
+func TestEncrypt_RoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+
+	const inner = "header.payload.signature"
+
+	tests := []struct {
+		name string
+		pub  crypto.PublicKey
+		priv any
+	}{
+		{name: "rsa", pub: &rsaKey.PublicKey, priv: rsaKey},
+		{name: "ec", pub: &ecKey.PublicKey, priv: ecKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := Encrypt(inner, publicJWK(t, tt.pub))
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+
+			if !IsToken(token) {
+				t.Fatalf("expected compact JWE, got %s", token)
+			}
+
+			decrypted, err := Decrypt(token, tt.priv)
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if decrypted != inner {
+				t.Fatalf("expected %q, got %q", inner, decrypted)
+			}
+
+			if _, err := Decrypt(token, otherKey); err == nil {
+				t.Fatal("expected other key to fail")
+			}
+		})
+	}
+}
+
+func TestParseKey_Rejects(t *testing.T) {
+	smallRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+
+	var withPrivate map[string]any
+	if err := json.Unmarshal(publicJWK(t, &ecKey.PublicKey), &withPrivate); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	withPrivate["d"] = "secret"
+	private, _ := json.Marshal(withPrivate)
+
+	sig := withPrivate
+	delete(sig, "d")
+	sig["use"] = "sig"
+	signing, _ := json.Marshal(sig)
+
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{name: "short rsa", raw: publicJWK(t, &smallRSA.PublicKey)},
+		{name: "private key", raw: private},
+		{name: "signing key", raw: signing},
+		{name: "not json", raw: []byte("nope")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseKey(tt.raw); !errors.Is(err, ErrUnsupportedKey) {
+				t.Fatalf("expected ErrUnsupportedKey, got %v", err)
+			}
+		})
+	}
+}
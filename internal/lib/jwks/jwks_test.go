@@ -0,0 +1,65 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestThumbprint_RFC7638Example(t *testing.T) {
+	// пример из RFC 7638, раздел 3.1
+	jwk := JWK{
+		Kty: "RSA",
+		E:   "AQAB",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMs" +
+			"tn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajr" +
+			"n1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	}
+
+	kid, err := Thumbprint(jwk)
+	if err != nil {
+		t.Fatalf("Thumbprint: %v", err)
+	}
+
+	if kid != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Fatalf("unexpected thumbprint %s", kid)
+	}
+}
+
+func TestFromPublicKey_RoundTrip(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	keys := map[string]any{
+		"RS256": &rsaKey.PublicKey,
+		"ES256": &ecKey.PublicKey,
+		"EdDSA": edPub,
+	}
+
+	for alg, pub := range keys {
+		t.Run(alg, func(t *testing.T) {
+			jwk, err := FromPublicKey(pub, alg)
+			if err != nil {
+				t.Fatalf("FromPublicKey: %v", err)
+			}
+			if jwk.Kid == "" || jwk.Alg != alg || jwk.Use != "sig" {
+				t.Fatalf("unexpected jwk %+v", jwk)
+			}
+
+			restored, err := jwk.PublicKey()
+			if err != nil {
+				t.Fatalf("PublicKey: %v", err)
+			}
+
+			type equaler interface{ Equal(x crypto.PublicKey) bool }
+			if !restored.(equaler).Equal(pub) {
+				t.Fatal("restored key does not match original")
+			}
+		})
+	}
+}
@@ -1,20 +1,62 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"sso/internal/domain/models"
+	"sso/internal/lib/tokens"
 	"time"
 )
 
-func NewToken(user models.User, app models.App, duration time.Duration) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	claims["uid"] = user.ID
-	claims["email"] = user.Email
-	claims["exp"] = time.Now().Add(duration).Unix()
-	claims["app_id"] = app.ID
+var ErrUnknownKey = errors.New("unknown signing key")
 
-	tokenString, err := token.SignedString([]byte(app.Secret))
+// Format access токены в формате JWT
+type Format struct{}
+
+func (Format) NewToken(claims tokens.Claims, app models.App, key models.AppKey) (string, error) {
+	return NewToken(claims, app, key)
+}
+
+func (Format) Parse(token string, app models.App, keys []models.AppKey, now time.Time) (tokens.Claims, error) {
+	return Parse(token, app, keys, now)
+}
+
+func (Format) UnverifiedAppID(token string) (int, error) {
+	return UnverifiedAppID(token)
+}
+
+// NewToken подписывает claims ключом key из связки ключей приложения.
+// Пустой key означает подпись общим секретом приложения без kid, как до появления связки ключей
+func NewToken(claims tokens.Claims, app models.App, key models.AppKey) (string, error) {
+	alg := key.Alg
+	if alg == "" {
+		alg = AlgHS256
+	}
+
+	method, err := SigningMethod(alg)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, toMapClaims(claims))
+
+	var signingKey any = []byte(app.Secret)
+
+	if key.Alg != "" {
+		kid, err := KeyID(key)
+		if err != nil {
+			return "", err
+		}
+		token.Header["kid"] = kid
+
+		signingKey, err = signingKeyOf(key)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	tokenString, err := token.SignedString(signingKey)
 
 	if err != nil {
 		return "", err
@@ -22,3 +64,159 @@ func NewToken(user models.User, app models.App, duration time.Duration) (string,
 
 	return tokenString, nil
 }
+
+// Parse проверяет подпись токена ключами приложения. Токены без kid проверяются общим секретом, но только
+// у HS256 приложений: секрет знает само приложение, и иначе оно могло бы выпускать токены от имени сервера.
+// Токены с kid - ключом из связки, если он активен или выведен из оборота, но еще в пределах grace периода
+func Parse(tokenString string, app models.App, keys []models.AppKey, now time.Time) (tokens.Claims, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		if kid == "" {
+			if app.SignAlg != AlgHS256 {
+				return nil, fmt.Errorf("%w: app %d signs with %s, token has no kid", ErrUnknownKey, app.ID, app.SignAlg)
+			}
+			if token.Method.Alg() != AlgHS256 {
+				return nil, fmt.Errorf("%w: token without kid must be %s", ErrUnknownKey, AlgHS256)
+			}
+			return []byte(app.Secret), nil
+		}
+
+		for _, key := range keys {
+			keyID, err := KeyID(key)
+			if err != nil || keyID != kid {
+				continue
+			}
+
+			if !key.Verifies(now) {
+				return nil, fmt.Errorf("%w: key %s is expired", ErrUnknownKey, kid)
+			}
+
+			if token.Method.Alg() != key.Alg {
+				return nil, fmt.Errorf("%w: key %s is %s, token is %s", ErrUnknownKey, kid, key.Alg, token.Method.Alg())
+			}
+
+			return verificationKeyOf(key)
+		}
+
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}, jwt.WithTimeFunc(func() time.Time { return now }))
+
+	if err != nil {
+		return tokens.Claims{}, err
+	}
+
+	return fromMapClaims(claims), nil
+}
+
+// UnverifiedAppID достает app_id из токена без проверки подписи, чтобы найти ключи приложения
+func UnverifiedAppID(tokenString string) (int, error) {
+	claims := jwt.MapClaims{}
+
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return 0, err
+	}
+
+	appID, ok := claims["app_id"].(float64)
+	if !ok {
+		return 0, errors.New("token has no app_id")
+	}
+
+	return int(appID), nil
+}
+
+func toMapClaims(claims tokens.Claims) jwt.MapClaims {
+	res := jwt.MapClaims{}
+
+	for name, value := range claims.Extra {
+		res[name] = value
+	}
+
+	res["jti"] = claims.ID
+	res["sub"] = claims.Subject
+	res["aud"] = claims.Audience
+	res["iat"] = claims.IssuedAt.Unix()
+	res["nbf"] = claims.NotBefore.Unix()
+	res["exp"] = claims.ExpiresAt.Unix()
+	res["app_id"] = claims.AppID
+
+	if len(claims.AMR) > 0 {
+		res["amr"] = claims.AMR
+	}
+
+	if claims.SessionID != "" {
+		res["sid"] = claims.SessionID
+	}
+
+	if len(claims.Roles) > 0 {
+		res["roles"] = claims.Roles
+	}
+
+	if len(claims.Permissions) > 0 {
+		res["permissions"] = claims.Permissions
+	}
+
+	if claims.Issuer != "" {
+		res["iss"] = claims.Issuer
+	}
+
+	return res
+}
+
+func fromMapClaims(claims jwt.MapClaims) tokens.Claims {
+	res := tokens.Claims{Extra: map[string]any{}}
+
+	res.ID, _ = claims["jti"].(string)
+	res.Subject, _ = claims["sub"].(string)
+	res.Issuer, _ = claims["iss"].(string)
+
+	if aud, err := claims.GetAudience(); err == nil && len(aud) > 0 {
+		res.Audience = aud[0]
+	}
+
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		res.IssuedAt = iat.Time
+	}
+
+	if nbf, err := claims.GetNotBefore(); err == nil && nbf != nil {
+		res.NotBefore = nbf.Time
+	}
+
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		res.ExpiresAt = exp.Time
+	}
+
+	if appID, ok := claims["app_id"].(float64); ok {
+		res.AppID = int(appID)
+	}
+
+	res.AMR = tokens.ParseStrings(claims["amr"])
+	res.Roles = tokens.ParseStrings(claims["roles"])
+	res.Permissions = tokens.ParseStrings(claims["permissions"])
+	res.SessionID, _ = claims["sid"].(string)
+
+	for name, value := range claims {
+		//uid токенов старого формата остается в Extra, см. tokens.Claims.UserID
+		if !tokens.IsReserved(name) || name == "uid" {
+			res.Extra[name] = value
+		}
+	}
+
+	return res
+}
+
+func signingKeyOf(key models.AppKey) (any, error) {
+	if !IsAsymmetric(key.Alg) {
+		return key.PrivateKey, nil
+	}
+	return ParsePrivateKey(key.PrivateKey)
+}
+
+func verificationKeyOf(key models.AppKey) (any, error) {
+	if !IsAsymmetric(key.Alg) {
+		return key.PrivateKey, nil
+	}
+	return ParsePublicKey(key.PublicKey)
+}
@@ -1,7 +1,10 @@
 package jwt
 
 import (
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
 	"sso/internal/domain/models"
+	"sso/internal/lib/tokens"
 	"testing"
 	"time"
 )
@@ -21,7 +24,7 @@ func TestNewToken_ValidJWTWithCorrectClaims(t *testing.T) {
 
 	ttl := time.Hour
 
-	tokenString, err := NewToken(user, app, ttl)
+	tokenString, err := issue(user, app, models.AppKey{}, tokens.Params{TTL: ttl})
 	if err != nil {
 		t.Fatalf("expected no error from NewToken, got: %v", err)
 	}
@@ -29,3 +32,209 @@ func TestNewToken_ValidJWTWithCorrectClaims(t *testing.T) {
 		t.Fatal("expected non-empty token string")
 	}
 }
+
+func TestNewToken_AsymmetricVerifiesWithPublicKey(t *testing.T) {
+	user := models.User{ID: 42, Email: "user@test.com"}
+	app := models.App{ID: 7, Name: "TestApp"}
+
+	for _, alg := range []string{AlgRS256, AlgES256, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			key, err := NewKey(app.ID, alg, models.KeyStatusActive)
+			if err != nil {
+				t.Fatalf("NewKey: %v", err)
+			}
+
+			tokenString, err := issue(user, app, key, tokens.Params{TTL: time.Hour, LegacyClaims: true})
+			if err != nil {
+				t.Fatalf("NewToken: %v", err)
+			}
+
+			jwk, err := PublicJWK(key)
+			if err != nil {
+				t.Fatalf("PublicJWK: %v", err)
+			}
+
+			parsed, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+				if token.Header["kid"] != jwk.Kid {
+					t.Errorf("expected kid %q, got %v", jwk.Kid, token.Header["kid"])
+				}
+				return jwk.PublicKey()
+			}, jwt.WithValidMethods([]string{alg}))
+			if err != nil {
+				t.Fatalf("expected token to verify with public key, got: %v", err)
+			}
+
+			claims := parsed.Claims.(jwt.MapClaims)
+			if int64(claims["uid"].(float64)) != user.ID {
+				t.Errorf("expected uid %d, got %v", user.ID, claims["uid"])
+			}
+		})
+	}
+}
+
+func TestParse_KeyRing(t *testing.T) {
+	user := models.User{ID: 42, Email: "user@test.com"}
+	app := models.App{ID: 7, Name: "TestApp", Secret: "super-secret", SignAlg: AlgES256}
+	legacyApp := models.App{ID: 7, Name: "TestApp", Secret: "super-secret", SignAlg: AlgHS256}
+	now := time.Now()
+
+	active, err := NewKey(app.ID, AlgES256, models.KeyStatusActive)
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+
+	retired, err := NewKey(app.ID, AlgHS256, models.KeyStatusRetired)
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+
+	next, err := NewKey(app.ID, AlgES256, models.KeyStatusNext)
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+
+	sign := func(key models.AppKey) string {
+		t.Helper()
+		token, err := issue(user, app, key, tokens.Params{TTL: time.Hour, LegacyClaims: true})
+		if err != nil {
+			t.Fatalf("NewToken: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		keys    []models.AppKey
+		app     *models.App
+		wantErr bool
+	}{
+		{
+			name:  "active key",
+			token: sign(active),
+			keys:  []models.AppKey{active},
+		},
+		{
+			name:  "legacy token without kid",
+			token: sign(models.AppKey{}),
+			keys:  []models.AppKey{active},
+			app:   &legacyApp,
+		},
+		{
+			//общий секрет знает приложение: без kid оно подписало бы токен само
+			name:    "token without kid for asymmetric app",
+			token:   sign(models.AppKey{}),
+			keys:    []models.AppKey{active},
+			wantErr: true,
+		},
+		{
+			name:  "retired key within grace",
+			token: sign(retired),
+			keys:  []models.AppKey{active, withExpiry(retired, now.Add(time.Minute))},
+		},
+		{
+			name:    "retired key after grace",
+			token:   sign(retired),
+			keys:    []models.AppKey{active, withExpiry(retired, now.Add(-time.Minute))},
+			wantErr: true,
+		},
+		{
+			name:    "next key is not accepted yet",
+			token:   sign(next),
+			keys:    []models.AppKey{active, next},
+			wantErr: true,
+		},
+		{
+			name:    "unknown kid",
+			token:   sign(active),
+			keys:    []models.AppKey{next},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseApp := app
+			if tt.app != nil {
+				parseApp = *tt.app
+			}
+
+			claims, err := Parse(tt.token, parseApp, tt.keys, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownKey) {
+					t.Fatalf("expected ErrUnknownKey, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected token to verify, got: %v", err)
+			}
+			if claims.Extra["email"] != user.Email {
+				t.Errorf("expected email %s, got %v", user.Email, claims.Extra["email"])
+			}
+		})
+	}
+}
+
+// issue собирает claims и подписывает их так же, как сервис auth
+func issue(user models.User, app models.App, key models.AppKey, params tokens.Params) (string, error) {
+	claims, err := tokens.NewClaims(user, app, params, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return NewToken(claims, app, key)
+}
+
+func withExpiry(key models.AppKey, expiresAt time.Time) models.AppKey {
+	key.ExpiresAt = expiresAt
+	return key
+}
+
+func TestNewToken_RegisteredClaims(t *testing.T) {
+	user := models.User{ID: 42, Email: "user@test.com"}
+	app := models.App{ID: 7, Name: "TestApp", Secret: "super-secret"}
+
+	tests := []struct {
+		name         string
+		legacyClaims bool
+	}{
+		{name: "legacy claims", legacyClaims: true},
+		{name: "registered claims only", legacyClaims: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenString, err := issue(user, app, models.AppKey{}, tokens.Params{
+				Issuer:       "https://sso.test",
+				TTL:          time.Hour,
+				LegacyClaims: tt.legacyClaims,
+			})
+			if err != nil {
+				t.Fatalf("NewToken: %v", err)
+			}
+
+			claims := jwt.MapClaims{}
+			_, err = jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (any, error) {
+				return []byte(app.Secret), nil
+			}, jwt.WithIssuer("https://sso.test"), jwt.WithAudience(app.Name), jwt.WithIssuedAt())
+			if err != nil {
+				t.Fatalf("expected standard validation to pass, got: %v", err)
+			}
+
+			if claims["sub"] != "42" {
+				t.Errorf("expected sub 42, got %v", claims["sub"])
+			}
+			if claims["jti"] == "" || claims["nbf"] == nil {
+				t.Errorf("expected jti and nbf, got %v", claims)
+			}
+			if _, ok := claims["uid"]; ok != tt.legacyClaims {
+				t.Errorf("expected uid present=%v, got %v", tt.legacyClaims, claims["uid"])
+			}
+
+			userID, ok := fromMapClaims(claims).UserID()
+			if !ok || userID != user.ID {
+				t.Errorf("expected UserID %d, got %d", user.ID, userID)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwks"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+
+	rsaKeyBits    = 2048
+	hmacKeyLength = 32
+)
+
+var (
+	ErrUnsupportedAlg = errors.New("unsupported signing algorithm")
+	ErrNoKey          = errors.New("app has no signing key")
+)
+
+// IsAsymmetric сообщает, подписывается ли токен парой ключей, а не общим секретом приложения
+func IsAsymmetric(alg string) bool {
+	switch alg {
+	case AlgRS256, AlgES256, AlgEdDSA:
+		return true
+	}
+	return false
+}
+
+// SigningMethod сопоставляет название алгоритма из таблицы apps с методом подписи
+func SigningMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case "", AlgHS256:
+		return jwt.SigningMethodHS256, nil
+	case AlgRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgES256:
+		return jwt.SigningMethodES256, nil
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+}
+
+// NewKey генерирует ключ для связки ключей приложения. kid асимметричного ключа - его thumbprint
+func NewKey(appID int, alg string, status string) (models.AppKey, error) {
+	privateKey, publicKey, err := GenerateKeyPair(alg)
+	if err != nil {
+		return models.AppKey{}, err
+	}
+
+	key := models.AppKey{
+		AppID:      appID,
+		Alg:        alg,
+		PrivateKey: privateKey,
+		PublicKey:  publicKey,
+		Status:     status,
+		CreatedAt:  time.Now(),
+	}
+
+	if IsAsymmetric(alg) {
+		jwk, err := PublicJWK(key)
+		if err != nil {
+			return models.AppKey{}, err
+		}
+		key.Kid = jwk.Kid
+	} else {
+		kid := make([]byte, 16)
+		if _, err := rand.Read(kid); err != nil {
+			return models.AppKey{}, err
+		}
+		key.Kid = base64.RawURLEncoding.EncodeToString(kid)
+	}
+
+	return key, nil
+}
+
+// KeyID kid ключа; у ключей, перенесенных из apps без kid, он вычисляется как thumbprint
+func KeyID(key models.AppKey) (string, error) {
+	if key.Kid != "" {
+		return key.Kid, nil
+	}
+
+	jwk, err := PublicJWK(key)
+	if err != nil {
+		return "", err
+	}
+
+	return jwk.Kid, nil
+}
+
+// PublicJWK публичный ключ в виде JWK; у HS256 ключей публичной части нет
+func PublicJWK(key models.AppKey) (jwks.JWK, error) {
+	if !IsAsymmetric(key.Alg) || len(key.PublicKey) == 0 {
+		return jwks.JWK{}, fmt.Errorf("%w: key %d has no public part", ErrNoKey, key.ID)
+	}
+
+	pub, err := ParsePublicKey(key.PublicKey)
+	if err != nil {
+		return jwks.JWK{}, err
+	}
+
+	return jwks.FromPublicKey(pub, key.Alg)
+}
+
+// GenerateKeyPair генерирует пару ключей для алгоритма: приватный в PKCS#8 PEM, публичный в PKIX PEM.
+// Для HS256 возвращается только случайный секрет
+func GenerateKeyPair(alg string) (privatePEM, publicPEM []byte, err error) {
+	var priv crypto.Signer
+
+	switch alg {
+	case AlgHS256:
+		secret := make([]byte, hmacKeyLength)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, nil, err
+		}
+		return secret, nil, nil
+	case AlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgES256:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pubDER, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privatePEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+	publicPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+
+	return privatePEM, publicPEM, nil
+}
+
+// ParsePrivateKey разбирает приватный ключ в PKCS#8 PEM
+func ParsePrivateKey(privatePEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(privatePEM)
+	if block == nil {
+		return nil, errors.New("invalid private key pem")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return signer, nil
+}
+
+// ParsePublicKey разбирает публичный ключ в PKIX PEM
+func ParsePublicKey(publicPEM []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(publicPEM)
+	if block == nil {
+		return nil, errors.New("invalid public key pem")
+	}
+
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
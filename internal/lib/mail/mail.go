@@ -0,0 +1,32 @@
+// Package mail доставляет письма пользователям: через SMTP или в локальный outbox для разработки и тестов
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"time"
+)
+
+// Message текстовое письмо одному получателю
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// bytes письмо в формате RFC 5322
+func (m Message) bytes(from string, now time.Time) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(m.Body)
+
+	return buf.Bytes()
+}
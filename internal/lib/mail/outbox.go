@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Outbox складывает письма файлами .eml в каталог вместо отправки: для разработки и тестов.
+// Имя файла начинается с адреса получателя, чтобы письма пользователя находились по маске
+type Outbox struct {
+	dir  string
+	from string
+}
+
+func NewOutbox(dir, from string) *Outbox {
+	return &Outbox{dir: dir, from: from}
+}
+
+func (o *Outbox) Send(_ context.Context, msg Message) error {
+	if err := os.MkdirAll(o.dir, 0o755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%d.eml", FileName(msg.To), now.UnixNano())
+
+	return os.WriteFile(filepath.Join(o.dir, name), msg.bytes(o.from, now), 0o644)
+}
+
+// FileName адрес в виде, пригодном для имени файла outbox
+func FileName(address string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune("@.+-_", r):
+			return r
+		}
+		return '_'
+	}, address)
+}
@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutbox_Send(t *testing.T) {
+	dir := t.TempDir()
+	outbox := NewOutbox(dir, "sso@test.com")
+
+	msg := Message{To: "user/../evil@test.com", Subject: "Подтверждение email", Body: "code: 123"}
+
+	if err := outbox.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, FileName(msg.To)+"-*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one message in outbox, got %v (%v)", files, err)
+	}
+
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	for _, want := range []string{"From: sso@test.com\r\n", "To: " + msg.To + "\r\n", "Subject: =?utf-8?q?", "\r\n\r\ncode: 123"} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("expected message to contain %q, got:\n%s", want, raw)
+		}
+	}
+}
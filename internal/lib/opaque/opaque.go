@@ -0,0 +1,40 @@
+package opaque
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const tokenLength = 32
+
+// New генерирует непрозрачный токен для клиента и его хэш для хранилища. Сам токен не сохраняется
+func New() (token string, hash []byte, err error) {
+	raw := make([]byte, tokenLength)
+
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(raw)
+
+	return token, Hash(token), nil
+}
+
+// Hash хэш токена, по которому он ищется в хранилище. У токена 256 бит энтропии, поэтому соль не нужна
+func Hash(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// NewID случайный идентификатор, например семейства refresh токенов
+func NewID() (string, error) {
+	raw := make([]byte, 16)
+
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(raw), nil
+}
@@ -0,0 +1,429 @@
+// Package paseto выпускает и проверяет access токены PASETO v4: v4.public подписывается Ed25519,
+// v4.local шифруется симметричным ключом приложения
+package paseto
+
+import (
+	"aidanwoods.dev/go-paseto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwks"
+	"sso/internal/lib/tokens"
+	"strings"
+	"time"
+)
+
+const (
+	AlgV4Local  = "v4.local"
+	AlgV4Public = "v4.public"
+
+	localKeyLength = 32
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown paseto key")
+	ErrMalformed  = errors.New("malformed paseto token")
+)
+
+// Footer незашифрованная, но аутентифицированная часть токена: по ней находятся приложение и ключ
+type Footer struct {
+	Kid   string `json:"kid"`
+	AppID int    `json:"app_id"`
+}
+
+// Format access токены в формате PASETO v4
+type Format struct{}
+
+func (Format) NewToken(claims tokens.Claims, app models.App, key models.AppKey) (string, error) {
+	return NewToken(claims, app, key)
+}
+
+func (Format) Parse(token string, app models.App, keys []models.AppKey, now time.Time) (tokens.Claims, error) {
+	return Parse(token, app, keys, now)
+}
+
+func (Format) UnverifiedAppID(token string) (int, error) {
+	footer, err := UnverifiedFooter(token)
+	if err != nil {
+		return 0, err
+	}
+	return footer.AppID, nil
+}
+
+// Supports сообщает, что алгоритм ключа приложения выпускает PASETO
+func Supports(alg string) bool {
+	return alg == AlgV4Local || alg == AlgV4Public
+}
+
+// IsToken сообщает, что строка похожа на PASETO v4, а не на JWT
+func IsToken(token string) bool {
+	return strings.HasPrefix(token, AlgV4Local+".") || strings.HasPrefix(token, AlgV4Public+".")
+}
+
+// NewKey генерирует ключ для связки ключей приложения. Ключ v4.public хранится как Ed25519 пара в PEM,
+// kid - thumbprint публичного ключа; ключ v4.local - 32 случайных байта со случайным kid
+func NewKey(appID int, alg string, status string) (models.AppKey, error) {
+	key := models.AppKey{
+		AppID:     appID,
+		Alg:       alg,
+		Status:    status,
+		CreatedAt: time.Now(),
+	}
+
+	switch alg {
+	case AlgV4Local:
+		key.PrivateKey = make([]byte, localKeyLength)
+		if _, err := rand.Read(key.PrivateKey); err != nil {
+			return models.AppKey{}, err
+		}
+
+		kid := make([]byte, 16)
+		if _, err := rand.Read(kid); err != nil {
+			return models.AppKey{}, err
+		}
+		key.Kid = base64.RawURLEncoding.EncodeToString(kid)
+	case AlgV4Public:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return models.AppKey{}, err
+		}
+
+		privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			return models.AppKey{}, err
+		}
+
+		pubDER, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return models.AppKey{}, err
+		}
+
+		key.PrivateKey = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+		key.PublicKey = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+
+		jwk, err := jwks.FromPublicKey(pub, alg)
+		if err != nil {
+			return models.AppKey{}, err
+		}
+		key.Kid = jwk.Kid
+	default:
+		return models.AppKey{}, fmt.Errorf("%w: %s", ErrUnknownKey, alg)
+	}
+
+	return key, nil
+}
+
+// PublicJWK публичный ключ v4.public в виде JWK с alg v4.public, чтобы его нельзя было применить к JWT
+func PublicJWK(key models.AppKey) (jwks.JWK, error) {
+	if key.Alg != AlgV4Public {
+		return jwks.JWK{}, fmt.Errorf("%w: key %d has no public part", ErrUnknownKey, key.ID)
+	}
+
+	pub, err := publicKeyOf(key)
+	if err != nil {
+		return jwks.JWK{}, err
+	}
+
+	return jwks.FromPublicKey(pub, key.Alg)
+}
+
+// NewToken подписывает (v4.public) или шифрует (v4.local) claims ключом из связки ключей приложения.
+// Время в claims кодируется в RFC 3339, как требует спецификация PASETO
+func NewToken(claims tokens.Claims, app models.App, key models.AppKey) (string, error) {
+	token := paseto.NewToken()
+
+	for name, value := range claims.Extra {
+		if err := token.Set(name, value); err != nil {
+			return "", err
+		}
+	}
+
+	token.SetJti(claims.ID)
+	token.SetSubject(claims.Subject)
+	token.SetAudience(claims.Audience)
+	token.SetIssuedAt(claims.IssuedAt)
+	token.SetNotBefore(claims.NotBefore)
+	token.SetExpiration(claims.ExpiresAt)
+
+	if err := token.Set("app_id", claims.AppID); err != nil {
+		return "", err
+	}
+
+	if len(claims.AMR) > 0 {
+		if err := token.Set("amr", claims.AMR); err != nil {
+			return "", err
+		}
+	}
+
+	if claims.SessionID != "" {
+		if err := token.Set("sid", claims.SessionID); err != nil {
+			return "", err
+		}
+	}
+
+	for name, values := range map[string][]string{"roles": claims.Roles, "permissions": claims.Permissions} {
+		if len(values) == 0 {
+			continue
+		}
+		if err := token.Set(name, values); err != nil {
+			return "", err
+		}
+	}
+
+	if claims.Issuer != "" {
+		token.SetIssuer(claims.Issuer)
+	}
+
+	footer, err := json.Marshal(Footer{Kid: key.Kid, AppID: app.ID})
+	if err != nil {
+		return "", err
+	}
+	token.SetFooter(footer)
+
+	switch key.Alg {
+	case AlgV4Local:
+		symmetric, err := paseto.V4SymmetricKeyFromBytes(key.PrivateKey)
+		if err != nil {
+			return "", err
+		}
+		return token.V4Encrypt(symmetric, nil), nil
+	case AlgV4Public:
+		priv, err := privateKeyOf(key)
+		if err != nil {
+			return "", err
+		}
+		secret, err := paseto.NewV4AsymmetricSecretKeyFromEd25519(priv)
+		if err != nil {
+			return "", err
+		}
+		return token.V4Sign(secret, nil), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnknownKey, key.Alg)
+}
+
+// Parse проверяет токен ключом из связки приложения по kid из footer. Как и у JWT, принимаются активный ключ
+// и ключ, выведенный из оборота, в пределах grace периода
+func Parse(token string, app models.App, keys []models.AppKey, now time.Time) (tokens.Claims, error) {
+	footer, err := UnverifiedFooter(token)
+	if err != nil {
+		return tokens.Claims{}, err
+	}
+
+	if footer.AppID != app.ID {
+		return tokens.Claims{}, fmt.Errorf("%w: token issued for app %d", ErrUnknownKey, footer.AppID)
+	}
+
+	alg := Protocol(token)
+
+	for _, key := range keys {
+		if key.Kid != footer.Kid {
+			continue
+		}
+
+		if !key.Verifies(now) {
+			return tokens.Claims{}, fmt.Errorf("%w: key %s is expired", ErrUnknownKey, key.Kid)
+		}
+
+		if key.Alg != alg {
+			return tokens.Claims{}, fmt.Errorf("%w: key %s is %s, token is %s", ErrUnknownKey, key.Kid, key.Alg, alg)
+		}
+
+		var verificationKey any = key.PrivateKey
+
+		if alg == AlgV4Public {
+			verificationKey, err = publicKeyOf(key)
+			if err != nil {
+				return tokens.Claims{}, err
+			}
+		}
+
+		claims, err := Decode(token, verificationKey)
+		if err != nil {
+			return tokens.Claims{}, err
+		}
+
+		if err := claims.Validate(now, 0); err != nil {
+			return tokens.Claims{}, err
+		}
+
+		return claims, nil
+	}
+
+	return tokens.Claims{}, fmt.Errorf("%w: %s", ErrUnknownKey, footer.Kid)
+}
+
+// Decode проверяет подпись или расшифровывает токен без проверки сроков действия.
+// key - ed25519.PublicKey для v4.public и 32 байта для v4.local
+func Decode(token string, key any) (tokens.Claims, error) {
+	parser := paseto.MakeParser(nil)
+
+	var (
+		parsed *paseto.Token
+		err    error
+	)
+
+	switch alg := Protocol(token); alg {
+	case AlgV4Local:
+		parsed, err = decrypt(parser, token, key)
+	case AlgV4Public:
+		parsed, err = verify(parser, token, key)
+	default:
+		return tokens.Claims{}, ErrMalformed
+	}
+
+	if err != nil {
+		return tokens.Claims{}, err
+	}
+
+	return claimsOf(parsed)
+}
+
+func decrypt(parser paseto.Parser, token string, key any) (*paseto.Token, error) {
+	raw, ok := key.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T can't decrypt %s", ErrUnknownKey, key, AlgV4Local)
+	}
+
+	symmetric, err := paseto.V4SymmetricKeyFromBytes(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.ParseV4Local(symmetric, token, nil)
+}
+
+func verify(parser paseto.Parser, token string, key any) (*paseto.Token, error) {
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T can't verify %s", ErrUnknownKey, key, AlgV4Public)
+	}
+
+	public, err := paseto.NewV4AsymmetricPublicKeyFromEd25519(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.ParseV4Public(public, token, nil)
+}
+
+// UnverifiedFooter footer токена без проверки подписи, чтобы найти приложение и ключ
+func UnverifiedFooter(token string) (Footer, error) {
+	protocol := paseto.V4Public
+	if Protocol(token) == AlgV4Local {
+		protocol = paseto.V4Local
+	}
+
+	raw, err := paseto.NewParser().UnsafeParseFooter(protocol, token)
+	if err != nil {
+		return Footer{}, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	var footer Footer
+
+	if err := json.Unmarshal(raw, &footer); err != nil {
+		return Footer{}, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	return footer, nil
+}
+
+// Protocol алгоритм токена по его заголовку: v4.local, v4.public или пустая строка
+func Protocol(token string) string {
+	switch {
+	case strings.HasPrefix(token, AlgV4Local+"."):
+		return AlgV4Local
+	case strings.HasPrefix(token, AlgV4Public+"."):
+		return AlgV4Public
+	}
+	return ""
+}
+
+func claimsOf(token *paseto.Token) (tokens.Claims, error) {
+	res := tokens.Claims{Extra: map[string]any{}}
+
+	res.ID, _ = token.GetJti()
+	res.Subject, _ = token.GetSubject()
+	res.Issuer, _ = token.GetIssuer()
+	res.Audience, _ = token.GetAudience()
+
+	claims := token.Claims()
+
+	var err error
+
+	for name, dst := range map[string]*time.Time{"iat": &res.IssuedAt, "nbf": &res.NotBefore, "exp": &res.ExpiresAt} {
+		if _, ok := claims[name]; !ok {
+			continue
+		}
+		if *dst, err = token.GetTime(name); err != nil {
+			return tokens.Claims{}, fmt.Errorf("%w: bad %s: %w", ErrMalformed, name, err)
+		}
+	}
+
+	for name, value := range claims {
+		switch {
+		case name == "app_id":
+			appID, ok := value.(float64)
+			if !ok {
+				return tokens.Claims{}, fmt.Errorf("%w: bad app_id", ErrMalformed)
+			}
+			res.AppID = int(appID)
+		case name == "amr":
+			res.AMR = tokens.ParseStrings(value)
+		case name == "roles":
+			res.Roles = tokens.ParseStrings(value)
+		case name == "permissions":
+			res.Permissions = tokens.ParseStrings(value)
+		case name == "sid":
+			res.SessionID, _ = value.(string)
+		case !tokens.IsReserved(name) || name == "uid":
+			res.Extra[name] = value
+		}
+	}
+
+	return res, nil
+}
+
+func privateKeyOf(key models.AppKey) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(key.PrivateKey)
+	if block == nil {
+		return nil, errors.New("invalid private key pem")
+	}
+
+	priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	ed, ok := priv.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not ed25519", ErrUnknownKey, priv)
+	}
+
+	return ed, nil
+}
+
+func publicKeyOf(key models.AppKey) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(key.PublicKey)
+	if block == nil {
+		return nil, errors.New("invalid public key pem")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	ed, ok := pub.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not ed25519", ErrUnknownKey, pub)
+	}
+
+	return ed, nil
+}
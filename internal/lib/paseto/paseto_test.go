@@ -0,0 +1,174 @@
+package paseto
+
+import (
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/tokens"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testUser = models.User{ID: 42, Email: "user@test.com"}
+	testApp  = models.App{ID: 7, Name: "TestApp"}
+)
+
+func newToken(t *testing.T, key models.AppKey, ttl time.Duration) string {
+	t.Helper()
+
+	claims, err := tokens.NewClaims(testUser, testApp, tokens.Params{Issuer: "https://sso.test", TTL: ttl}, time.Now())
+	if err != nil {
+		t.Fatalf("NewClaims: %v", err)
+	}
+
+	token, err := NewToken(claims, testApp, key)
+	if err != nil {
+		t.Fatalf("NewToken: %v", err)
+	}
+
+	return token
+}
+
+func TestNewToken_RoundTrip(t *testing.T) {
+	for _, alg := range []string{AlgV4Local, AlgV4Public} {
+		t.Run(alg, func(t *testing.T) {
+			key, err := NewKey(testApp.ID, alg, models.KeyStatusActive)
+			if err != nil {
+				t.Fatalf("NewKey: %v", err)
+			}
+
+			token := newToken(t, key, time.Hour)
+
+			if !strings.HasPrefix(token, alg+".") || !IsToken(token) {
+				t.Fatalf("expected %s token, got %s", alg, token)
+			}
+
+			footer, err := UnverifiedFooter(token)
+			if err != nil {
+				t.Fatalf("UnverifiedFooter: %v", err)
+			}
+			if footer.Kid != key.Kid || footer.AppID != testApp.ID {
+				t.Errorf("unexpected footer %+v", footer)
+			}
+
+			claims, err := Parse(token, testApp, []models.AppKey{key}, time.Now())
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			userID, ok := claims.UserID()
+			if !ok || userID != testUser.ID {
+				t.Errorf("expected user %d, got %d", testUser.ID, userID)
+			}
+			if claims.AppID != testApp.ID || claims.Audience != testApp.Name || claims.Issuer != "https://sso.test" {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+			if claims.Extra["email"] != testUser.Email {
+				t.Errorf("expected email %s, got %v", testUser.Email, claims.Extra["email"])
+			}
+		})
+	}
+}
+
+func TestParse_FailCases(t *testing.T) {
+	now := time.Now()
+
+	active, err := NewKey(testApp.ID, AlgV4Public, models.KeyStatusActive)
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+
+	other, err := NewKey(testApp.ID, AlgV4Public, models.KeyStatusActive)
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+
+	//ключ другого приложения с тем же kid не должен проверять токен
+	forged := other
+	forged.Kid = active.Kid
+
+	retired := active
+	retired.Status = models.KeyStatusRetired
+	retired.ExpiresAt = now.Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		token   string
+		app     models.App
+		keys    []models.AppKey
+		wantErr error
+	}{
+		{
+			name:    "expired",
+			token:   newToken(t, active, -time.Minute),
+			app:     testApp,
+			keys:    []models.AppKey{active},
+			wantErr: tokens.ErrExpired,
+		},
+		{
+			name:    "unknown kid",
+			token:   newToken(t, active, time.Hour),
+			app:     testApp,
+			keys:    []models.AppKey{other},
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name:    "retired key after grace",
+			token:   newToken(t, active, time.Hour),
+			app:     testApp,
+			keys:    []models.AppKey{retired},
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name:    "other app",
+			token:   newToken(t, active, time.Hour),
+			app:     models.App{ID: testApp.ID + 1},
+			keys:    []models.AppKey{active},
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name:  "wrong key",
+			token: newToken(t, active, time.Hour),
+			app:   testApp,
+			keys:  []models.AppKey{forged},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.token, tt.app, tt.keys, now)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPublicJWK(t *testing.T) {
+	key, err := NewKey(testApp.ID, AlgV4Public, models.KeyStatusActive)
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+
+	jwk, err := PublicJWK(key)
+	if err != nil {
+		t.Fatalf("PublicJWK: %v", err)
+	}
+
+	if jwk.Kid != key.Kid || jwk.Alg != AlgV4Public || jwk.Kty != "OKP" {
+		t.Fatalf("unexpected jwk %+v", jwk)
+	}
+
+	pub, err := jwk.PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey: %v", err)
+	}
+
+	if _, err := Decode(newToken(t, key, time.Hour), pub); err != nil {
+		t.Fatalf("expected token to verify with published key, got: %v", err)
+	}
+}
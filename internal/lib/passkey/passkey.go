@@ -0,0 +1,263 @@
+// Package passkey церемонии WebAuthn (passkeys) поверх go-webauthn: регистрация ключа и вход подписью.
+// Каждая церемония в два шага: Begin выдает параметры для navigator.credentials и состояние, которое
+// sso хранит до второго шага; Finish проверяет ответ аутентификатора по этому состоянию
+package passkey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"sso/internal/domain/models"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrInvalidResponse ответ аутентификатора не прошел проверку: подпись, challenge, origin, флаги
+	ErrInvalidResponse = errors.New("invalid webauthn response")
+	// ErrCloned счетчик подписей не вырос: ключ, вероятно, скопирован с аутентификатора
+	ErrCloned = errors.New("authenticator sign count did not increase")
+)
+
+// RelyingParty проверяющая сторона WebAuthn: sso с доменом rpID, в который входят со страниц origins
+type RelyingParty struct {
+	webauthn *webauthn.WebAuthn
+}
+
+// User владелец ключей. WebAuthn user handle - десятичный id пользователя, а не email,
+// чтобы аутентификатор не хранил персональные данные
+type User struct {
+	ID       int64
+	Email    string
+	Passkeys []models.Passkey
+}
+
+// Ceremony первый шаг церемонии. Options отдаются браузеру как есть, Session хранится у sso до Finish
+type Ceremony struct {
+	Options   []byte
+	Session   []byte
+	ExpiresAt time.Time
+}
+
+// New проверяющая сторона; timeout - время на ответ аутентификатора, после него Finish отклоняет ответ.
+// Проверка пользователя (PIN, биометрия) обязательна: вход по passkey заменяет и пароль, и второй фактор
+func New(rpID, displayName string, origins []string, timeout time.Duration) (*RelyingParty, error) {
+	timeouts := webauthn.TimeoutConfig{Enforce: true, Timeout: timeout, TimeoutUVD: timeout}
+
+	wa, err := webauthn.New(&webauthn.Config{
+		RPID:                  rpID,
+		RPDisplayName:         displayName,
+		RPOrigins:             origins,
+		AttestationPreference: protocol.PreferNoAttestation,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{Login: timeouts, Registration: timeouts},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &RelyingParty{webauthn: wa}, nil
+}
+
+// BeginRegistration параметры создания ключа. Уже зарегистрированные ключи пользователя исключаются,
+// чтобы аутентификатор не создал второй ключ для того же аккаунта
+func (rp *RelyingParty) BeginRegistration(user User) (Ceremony, error) {
+	u := webauthnUser(user)
+
+	creation, session, err := rp.webauthn.BeginRegistration(u,
+		webauthn.WithExclusions(webauthn.Credentials(u.credentials).CredentialDescriptors()))
+
+	if err != nil {
+		return Ceremony{}, err
+	}
+
+	return ceremony(creation, session)
+}
+
+// FinishRegistration проверяет ответ navigator.credentials.create() и возвращает новый ключ пользователя
+func (rp *RelyingParty) FinishRegistration(user User, session, response []byte) (models.Passkey, error) {
+	var data webauthn.SessionData
+
+	if err := json.Unmarshal(session, &data); err != nil {
+		return models.Passkey{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+
+	if err != nil {
+		return models.Passkey{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	credential, err := rp.webauthn.CreateCredential(webauthnUser(user), data, parsed)
+
+	if err != nil {
+		return models.Passkey{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	return models.Passkey{
+		UserID:          user.ID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      transports,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}, nil
+}
+
+// BeginLogin параметры входа. Для известного пользователя подходят только его ключи; с nil
+// аутентификатор сам предлагает пользователю ключи для этого домена (discoverable credentials)
+func (rp *RelyingParty) BeginLogin(user *User) (Ceremony, error) {
+	var (
+		assertion *protocol.CredentialAssertion
+		session   *webauthn.SessionData
+		err       error
+	)
+
+	if user != nil {
+		assertion, session, err = rp.webauthn.BeginLogin(webauthnUser(*user))
+	} else {
+		assertion, session, err = rp.webauthn.BeginDiscoverableLogin()
+	}
+
+	if err != nil {
+		return Ceremony{}, err
+	}
+
+	return ceremony(assertion, session)
+}
+
+// FinishLogin проверяет ответ navigator.credentials.get(). Пользователь определяется по сессии, а при входе
+// без email - по user handle из ответа; lookup загружает его ключи. Возвращает использованный ключ
+// с новым счетчиком подписей. Ошибки lookup возвращаются как есть
+func (rp *RelyingParty) FinishLogin(session, response []byte, lookup func(userID int64) (User, error)) (models.Passkey, error) {
+	var data webauthn.SessionData
+
+	if err := json.Unmarshal(session, &data); err != nil {
+		return models.Passkey{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+
+	if err != nil {
+		return models.Passkey{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	handle := data.UserID
+	if len(handle) == 0 {
+		handle = parsed.Response.UserHandle
+	}
+
+	userID, err := strconv.ParseInt(string(handle), 10, 64)
+
+	if err != nil {
+		return models.Passkey{}, fmt.Errorf("%w: bad user handle", ErrInvalidResponse)
+	}
+
+	user, err := lookup(userID)
+
+	if err != nil {
+		return models.Passkey{}, err
+	}
+
+	u := webauthnUser(user)
+
+	var credential *webauthn.Credential
+
+	if len(data.UserID) > 0 {
+		credential, err = rp.webauthn.ValidateLogin(u, data, parsed)
+	} else {
+		credential, err = rp.webauthn.ValidateDiscoverableLogin(func(_, _ []byte) (webauthn.User, error) {
+			return u, nil
+		}, data, parsed)
+	}
+
+	if err != nil {
+		return models.Passkey{}, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+
+	if credential.Authenticator.CloneWarning {
+		return models.Passkey{}, ErrCloned
+	}
+
+	for _, key := range user.Passkeys {
+		if string(key.CredentialID) == string(credential.ID) {
+			key.SignCount = credential.Authenticator.SignCount
+			key.BackupState = credential.Flags.BackupState
+			return key, nil
+		}
+	}
+
+	return models.Passkey{}, fmt.Errorf("%w: unknown credential", ErrInvalidResponse)
+}
+
+func ceremony(options any, session *webauthn.SessionData) (Ceremony, error) {
+	rawOptions, err := json.Marshal(options)
+
+	if err != nil {
+		return Ceremony{}, err
+	}
+
+	rawSession, err := json.Marshal(session)
+
+	if err != nil {
+		return Ceremony{}, err
+	}
+
+	return Ceremony{Options: rawOptions, Session: rawSession, ExpiresAt: session.Expires}, nil
+}
+
+// account адаптер пользователя к webauthn.User
+type account struct {
+	id          []byte
+	email       string
+	credentials []webauthn.Credential
+}
+
+func webauthnUser(u User) *account {
+	res := &account{id: []byte(strconv.FormatInt(u.ID, 10)), email: u.Email}
+
+	for _, key := range u.Passkeys {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(key.Transports))
+
+		for _, transport := range key.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+
+		res.credentials = append(res.credentials, webauthn.Credential{
+			ID:              key.CredentialID,
+			PublicKey:       key.PublicKey,
+			AttestationType: key.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: key.BackupEligible,
+				BackupState:    key.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    key.AAGUID,
+				SignCount: key.SignCount,
+			},
+		})
+	}
+
+	return res
+}
+
+func (u *account) WebAuthnID() []byte                         { return u.id }
+func (u *account) WebAuthnName() string                       { return u.email }
+func (u *account) WebAuthnDisplayName() string                { return u.email }
+func (u *account) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
@@ -0,0 +1,190 @@
+package passkey
+
+import (
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/passkey/passkeytest"
+	"testing"
+	"time"
+)
+
+const origin = "http://localhost"
+
+func newRelyingParty(t *testing.T, origin string) *RelyingParty {
+	t.Helper()
+
+	rp, err := New("localhost", "sso", []string{origin}, time.Minute)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return rp
+}
+
+// register регистрирует ключ аутентификатора и возвращает пользователя с этим ключом
+func register(t *testing.T, rp *RelyingParty, authenticator *passkeytest.Authenticator, u User) User {
+	t.Helper()
+
+	ceremony, err := rp.BeginRegistration(u)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+
+	response, err := authenticator.Create(ceremony.Options)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	key, err := rp.FinishRegistration(u, ceremony.Session, response)
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+
+	u.Passkeys = append(u.Passkeys, key)
+
+	return u
+}
+
+func login(rp *RelyingParty, authenticator *passkeytest.Authenticator, u User, discoverable bool) (models.Passkey, error) {
+	begin := &u
+	if discoverable {
+		begin = nil
+	}
+
+	ceremony, err := rp.BeginLogin(begin)
+	if err != nil {
+		return models.Passkey{}, err
+	}
+
+	response, err := authenticator.Get(ceremony.Options)
+	if err != nil {
+		return models.Passkey{}, err
+	}
+
+	return rp.FinishLogin(ceremony.Session, response, func(userID int64) (User, error) {
+		if userID != u.ID {
+			return User{}, errors.New("unknown user")
+		}
+		return u, nil
+	})
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	rp := newRelyingParty(t, origin)
+	authenticator := passkeytest.New(origin)
+
+	u := register(t, rp, authenticator, User{ID: 42, Email: "user@example.com"})
+	registered := u.Passkeys[0]
+
+	if registered.UserID != 42 || registered.AttestationType != "none" || registered.SignCount != 0 {
+		t.Fatalf("registered passkey = %+v", registered)
+	}
+
+	if string(registered.AAGUID) != string(authenticator.AAGUID()) {
+		t.Errorf("aaguid = %x, want %x", registered.AAGUID, authenticator.AAGUID())
+	}
+
+	for _, discoverable := range []bool{false, true} {
+		key, err := login(rp, authenticator, u, discoverable)
+		if err != nil {
+			t.Fatalf("login (discoverable %v): %v", discoverable, err)
+		}
+
+		if key.SignCount != u.Passkeys[0].SignCount+1 {
+			t.Errorf("sign count = %d, want %d", key.SignCount, u.Passkeys[0].SignCount+1)
+		}
+
+		u.Passkeys[0] = key
+	}
+}
+
+func TestBeginRegistration_ExcludesRegisteredKeys(t *testing.T) {
+	rp := newRelyingParty(t, origin)
+	authenticator := passkeytest.New(origin)
+
+	u := register(t, rp, authenticator, User{ID: 7, Email: "user@example.com"})
+
+	ceremony, err := rp.BeginRegistration(u)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+
+	if _, err := authenticator.Create(ceremony.Options); err == nil {
+		t.Fatal("authenticator created a second key for the same account")
+	}
+}
+
+func TestFinishLogin_ClonedAuthenticator(t *testing.T) {
+	rp := newRelyingParty(t, origin)
+	authenticator := passkeytest.New(origin)
+
+	u := register(t, rp, authenticator, User{ID: 1, Email: "user@example.com"})
+	clone := authenticator.Clone()
+
+	key, err := login(rp, authenticator, u, false)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	u.Passkeys[0] = key
+
+	//у копии тот же счетчик, что уже принят от оригинала
+	if _, err := login(rp, clone, u, false); !errors.Is(err, ErrCloned) {
+		t.Fatalf("login with clone: err = %v, want ErrCloned", err)
+	}
+}
+
+func TestFinish_RejectsInvalidResponses(t *testing.T) {
+	rp := newRelyingParty(t, origin)
+	authenticator := passkeytest.New(origin)
+	u := register(t, rp, authenticator, User{ID: 3, Email: "user@example.com"})
+
+	t.Run("foreign origin", func(t *testing.T) {
+		phishing := passkeytest.New("http://localhost.evil")
+		u := register(t, newRelyingParty(t, "http://localhost.evil"), phishing, User{ID: 3})
+
+		if _, err := login(rp, phishing, u, false); !errors.Is(err, ErrInvalidResponse) {
+			t.Fatalf("err = %v, want ErrInvalidResponse", err)
+		}
+	})
+
+	t.Run("user not verified", func(t *testing.T) {
+		unverified := passkeytest.New(origin)
+		unverified.UserVerified = false
+
+		ceremony, err := rp.BeginRegistration(u)
+		if err != nil {
+			t.Fatalf("BeginRegistration: %v", err)
+		}
+
+		response, err := unverified.Create(ceremony.Options)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		if _, err := rp.FinishRegistration(u, ceremony.Session, response); !errors.Is(err, ErrInvalidResponse) {
+			t.Fatalf("err = %v, want ErrInvalidResponse", err)
+		}
+	})
+
+	t.Run("challenge of another ceremony", func(t *testing.T) {
+		signed, err := rp.BeginLogin(&u)
+		if err != nil {
+			t.Fatalf("BeginLogin: %v", err)
+		}
+
+		other, err := rp.BeginLogin(&u)
+		if err != nil {
+			t.Fatalf("BeginLogin: %v", err)
+		}
+
+		response, err := authenticator.Get(signed.Options)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+
+		_, err = rp.FinishLogin(other.Session, response, func(int64) (User, error) { return u, nil })
+		if !errors.Is(err, ErrInvalidResponse) {
+			t.Fatalf("err = %v, want ErrInvalidResponse", err)
+		}
+	})
+}
@@ -0,0 +1,274 @@
+// Package passkeytest программный аутентификатор WebAuthn для тестов: ключи ECDSA P-256 в памяти,
+// аттестация none, флаги присутствия и проверки пользователя. Заменяет браузер и аппаратный ключ
+package passkeytest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+
+	// COSE (RFC 9053): EC2, ES256, P-256
+	coseKeyTypeEC2 = 2
+	coseAlgES256   = -7
+	coseCurveP256  = 1
+)
+
+var (
+	encoding = base64.RawURLEncoding
+
+	ErrNoCredential = errors.New("no matching credential")
+)
+
+// Authenticator программный аутентификатор, который работает со страницы origin
+type Authenticator struct {
+	origin string
+	aaguid []byte
+	keys   []*key
+	// UserVerified ставить флаг UV, как после PIN или биометрии; false имитирует ключ только с касанием
+	UserVerified bool
+}
+
+type key struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	private    *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+func New(origin string) *Authenticator {
+	aaguid := make([]byte, 16)
+	_, _ = rand.Read(aaguid)
+
+	return &Authenticator{origin: origin, aaguid: aaguid, UserVerified: true}
+}
+
+// AAGUID модель аутентификатора, которую он сообщает при регистрации
+func (a *Authenticator) AAGUID() []byte {
+	return a.aaguid
+}
+
+// Clone копия аутентификатора с теми же ключами и счетчиками, как при извлечении ключей из устройства
+func (a *Authenticator) Clone() *Authenticator {
+	res := &Authenticator{origin: a.origin, aaguid: a.aaguid, UserVerified: a.UserVerified}
+
+	for _, k := range a.keys {
+		copied := *k
+		res.keys = append(res.keys, &copied)
+	}
+
+	return res
+}
+
+// creationOptions часть PublicKeyCredentialCreationOptions, которая нужна аутентификатору
+type creationOptions struct {
+	PublicKey struct {
+		Challenge string `json:"challenge"`
+		RP        struct {
+			ID string `json:"id"`
+		} `json:"rp"`
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+		ExcludeCredentials []credentialDescriptor `json:"excludeCredentials"`
+	} `json:"publicKey"`
+}
+
+type requestOptions struct {
+	PublicKey struct {
+		Challenge        string                 `json:"challenge"`
+		RPID             string                 `json:"rpId"`
+		AllowCredentials []credentialDescriptor `json:"allowCredentials"`
+	} `json:"publicKey"`
+}
+
+type credentialDescriptor struct {
+	ID string `json:"id"`
+}
+
+// Create navigator.credentials.create(): создает ключ по параметрам регистрации и возвращает ответ в JSON
+func (a *Authenticator) Create(options []byte) ([]byte, error) {
+	var opts creationOptions
+
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, err
+	}
+
+	rpID := opts.PublicKey.RP.ID
+
+	for _, excluded := range opts.PublicKey.ExcludeCredentials {
+		if a.find(rpID, excluded.ID) != nil {
+			return nil, fmt.Errorf("credential %s is already registered", excluded.ID)
+		}
+	}
+
+	userHandle, err := encoding.DecodeString(opts.PublicKey.User.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("user id: %w", err)
+	}
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	k := &key{id: make([]byte, 32), rpID: rpID, userHandle: userHandle, private: private}
+
+	if _, err := rand.Read(k.id); err != nil {
+		return nil, err
+	}
+
+	publicKey, err := webauthncbor.Marshal(map[int]any{
+		1:  coseKeyTypeEC2,
+		3:  coseAlgES256,
+		-1: coseCurveP256,
+		-2: private.X.FillBytes(make([]byte, 32)),
+		-3: private.Y.FillBytes(make([]byte, 32)),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authenticatorData(rpID, flagAttestedData, k.signCount)
+	authData = append(authData, a.aaguid...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(k.id)))
+	authData = append(authData, k.id...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	clientData, err := a.clientData("webauthn.create", opts.PublicKey.Challenge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	a.keys = append(a.keys, k)
+
+	return json.Marshal(map[string]any{
+		"id":    encoding.EncodeToString(k.id),
+		"rawId": encoding.EncodeToString(k.id),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encoding.EncodeToString(clientData),
+			"attestationObject": encoding.EncodeToString(attestation),
+			"transports":        []string{"internal"},
+		},
+	})
+}
+
+// Get navigator.credentials.get(): подписывает challenge первым подходящим ключом. Без allowCredentials
+// подходит любой ключ домена, как при входе без email
+func (a *Authenticator) Get(options []byte) ([]byte, error) {
+	var opts requestOptions
+
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, err
+	}
+
+	rpID := opts.PublicKey.RPID
+
+	var k *key
+
+	if len(opts.PublicKey.AllowCredentials) == 0 {
+		k = a.find(rpID, "")
+	}
+
+	for _, allowed := range opts.PublicKey.AllowCredentials {
+		if k = a.find(rpID, allowed.ID); k != nil {
+			break
+		}
+	}
+
+	if k == nil {
+		return nil, ErrNoCredential
+	}
+
+	k.signCount++
+
+	authData := a.authenticatorData(rpID, 0, k.signCount)
+
+	clientData, err := a.clientData("webauthn.get", opts.PublicKey.Challenge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, k.private, digest[:])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]any{
+		"id":    encoding.EncodeToString(k.id),
+		"rawId": encoding.EncodeToString(k.id),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encoding.EncodeToString(clientData),
+			"authenticatorData": encoding.EncodeToString(authData),
+			"signature":         encoding.EncodeToString(signature),
+			"userHandle":        encoding.EncodeToString(k.userHandle),
+		},
+	})
+}
+
+// find ключ домена rpID с credential id id в base64url; пустой id - любой ключ домена
+func (a *Authenticator) find(rpID, id string) *key {
+	for _, k := range a.keys {
+		if k.rpID == rpID && (id == "" || encoding.EncodeToString(k.id) == id) {
+			return k
+		}
+	}
+	return nil
+}
+
+// authenticatorData хэш rpID, флаги и счетчик подписей (WebAuthn, 6.1)
+func (a *Authenticator) authenticatorData(rpID string, flags byte, signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+
+	flags |= flagUserPresent
+	if a.UserVerified {
+		flags |= flagUserVerified
+	}
+
+	data := append(rpIDHash[:], flags)
+
+	return binary.BigEndian.AppendUint32(data, signCount)
+}
+
+func (a *Authenticator) clientData(typ, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        typ,
+		"challenge":   challenge,
+		"origin":      a.origin,
+		"crossOrigin": false,
+	})
+}
@@ -0,0 +1,95 @@
+package password
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+)
+
+// DefaultArgon2id минимальные параметры из рекомендаций OWASP: 19 MiB, 2 прохода, 1 поток
+var DefaultArgon2id = Argon2id{Memory: 19 * 1024, Iterations: 2, Parallelism: 1}
+
+// Argon2id (RFC 9106) в формате $argon2id$v=19$m=память,t=проходы,p=потоки$соль$ключ
+type Argon2id struct {
+	// Memory память в KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+func (a Argon2id) Algorithm() string {
+	return AlgorithmArgon2id
+}
+
+func (a Argon2id) Hash(password string) ([]byte, error) {
+	if a.Iterations < 1 || a.Parallelism < 1 || a.Memory < 8*uint32(a.Parallelism) {
+		return nil, errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := newSalt()
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, keyLength)
+
+	return []byte(a.encode(salt, key)), nil
+}
+
+func (a Argon2id) Verify(password string, hash []byte) (bool, error) {
+	parts, err := phcParts(hash, AlgorithmArgon2id, 4)
+
+	if err != nil {
+		return false, err
+	}
+
+	var version int
+
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, fmt.Errorf("%w: argon2id version %q", ErrUnknownHash, parts[0])
+	}
+
+	stored, err := parseArgon2id(parts[1])
+
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrUnknownHash, err)
+	}
+
+	salt, key, err := phcSaltKey(parts[2], parts[3])
+
+	if err != nil {
+		return false, err
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, stored.Iterations, stored.Memory, stored.Parallelism, uint32(len(key)))
+
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return false, ErrMismatch
+	}
+
+	return stored != a || len(salt) != saltLength || len(key) != keyLength, nil
+}
+
+// Params параметры в формате PHC: m=память,t=проходы,p=потоки
+func (a Argon2id) Params() string {
+	return fmt.Sprintf("m=%d,t=%d,p=%d", a.Memory, a.Iterations, a.Parallelism)
+}
+
+func (a Argon2id) encode(salt, key []byte) string {
+	return fmt.Sprintf("$%s$v=%d$%s$%s$%s",
+		AlgorithmArgon2id, argon2.Version, a.Params(), b64.EncodeToString(salt), b64.EncodeToString(key))
+}
+
+func parseArgon2id(params string) (Argon2id, error) {
+	var a Argon2id
+
+	_, err := fmt.Sscanf(params, "m=%d,t=%d,p=%d", &a.Memory, &a.Iterations, &a.Parallelism)
+
+	if err != nil || a.Iterations < 1 || a.Parallelism < 1 {
+		return Argon2id{}, fmt.Errorf("argon2id parameters %q", params)
+	}
+
+	return a, nil
+}
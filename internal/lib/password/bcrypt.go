@@ -0,0 +1,58 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultBcrypt стоимость, с которой хэшировались пароли до появления выбора алгоритма
+var DefaultBcrypt = Bcrypt{Cost: bcrypt.DefaultCost}
+
+// Bcrypt хранится в собственном формате $2a$cost$..., который PHC принимает как есть.
+// Учитывает только первые 72 байта пароля, более длинные пароли отклоняет
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Algorithm() string {
+	return AlgorithmBcrypt
+}
+
+func (b Bcrypt) Hash(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+}
+
+func (b Bcrypt) Verify(password string, hash []byte) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, ErrMismatch
+		}
+		return false, err
+	}
+
+	cost, err := bcrypt.Cost(hash)
+
+	if err != nil {
+		return false, err
+	}
+
+	return cost != b.Cost, nil
+}
+
+// Params стоимость в виде cost=N; в самом хэше bcrypt она записана как $2a$N$
+func (b Bcrypt) Params() string {
+	return fmt.Sprintf("cost=%d", b.Cost)
+}
+
+func parseBcrypt(params string) (Bcrypt, error) {
+	var b Bcrypt
+
+	if _, err := fmt.Sscanf(params, "cost=%d", &b.Cost); err != nil {
+		return Bcrypt{}, fmt.Errorf("bcrypt parameters %q", params)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,108 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Blocklist фильтр Блума по списку утекших и частых паролей. Занимает около 1.8 байта на пароль
+// при доле ложных срабатываний 0.1%: список на миллионы паролей помещается в память целиком.
+// Ложное срабатывание отклоняет хороший пароль, пропустить пароль из списка фильтр не может.
+// Регистр не учитывается: "Password" считается таким же частым, как "password"
+type Blocklist struct {
+	bits   []uint64
+	m      uint64
+	hashes uint64
+}
+
+// NewBlocklist пустой фильтр на n паролей с долей ложных срабатываний falsePositiveRate
+func NewBlocklist(n int, falsePositiveRate float64) *Blocklist {
+	if n < 1 {
+		n = 1
+	}
+
+	//m = -n ln p / (ln 2)^2, k = m/n ln 2
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	m = max(m, 64)
+
+	hashes := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	hashes = max(hashes, 1)
+
+	return &Blocklist{bits: make([]uint64, (m+63)/64), m: m, hashes: hashes}
+}
+
+// LoadBlocklist фильтр по файлу с паролями по одному на строку. Пустые строки пропускаются
+func LoadBlocklist(path string, falsePositiveRate float64) (*Blocklist, error) {
+	const op = "password.LoadBlocklist"
+
+	//первый проход считает пароли, чтобы подобрать размер фильтра
+	n := 0
+
+	err := eachLine(path, func(string) { n++ })
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	b := NewBlocklist(n, falsePositiveRate)
+
+	if err := eachLine(path, b.Add); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return b, nil
+}
+
+func (b *Blocklist) Add(password string) {
+	h1, h2 := b.hash(password)
+
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *Blocklist) Contains(password string) bool {
+	h1, h2 := b.hash(password)
+
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hash две независимые половины SHA-256 для двойного хэширования (Kirsch-Mitzenmacher)
+func (b *Blocklist) hash(password string) (uint64, uint64) {
+	sum := sha256.Sum256([]byte(strings.ToLower(password)))
+
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16]) | 1
+}
+
+func eachLine(path string, fn func(string)) error {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line != "" {
+			fn(line)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,62 @@
+package password
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "common.txt")
+
+	if err := os.WriteFile(path, []byte("123456\r\npassword\n\niloveyou\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := LoadBlocklist(path, 0.001)
+	if err != nil {
+		t.Fatalf("LoadBlocklist: %v", err)
+	}
+
+	for _, p := range []string{"123456", "password", "PassWord", "iloveyou"} {
+		if !b.Contains(p) {
+			t.Errorf("Contains(%q) = false, want true", p)
+		}
+	}
+
+	if b.Contains("") {
+		t.Error("empty line was added to the blocklist")
+	}
+
+	if _, err := LoadBlocklist(filepath.Join(t.TempDir(), "missing.txt"), 0.001); err == nil {
+		t.Error("LoadBlocklist of a missing file succeeded")
+	}
+}
+
+func TestBlocklist_FalsePositiveRate(t *testing.T) {
+	const n = 10000
+
+	b := NewBlocklist(n, 0.01)
+	for i := 0; i < n; i++ {
+		b.Add(fmt.Sprintf("breached-%d", i))
+	}
+
+	for i := 0; i < n; i++ {
+		if !b.Contains(fmt.Sprintf("breached-%d", i)) {
+			t.Fatalf("added password breached-%d not found", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if b.Contains(fmt.Sprintf("unique-%d", i)) {
+			falsePositives++
+		}
+	}
+
+	//ожидается около 100 при 1%, запас на случайный разброс
+	if falsePositives > 2*n/100 {
+		t.Fatalf("false positives = %d of %d, want about 1%%", falsePositives, n)
+	}
+}
@@ -0,0 +1,115 @@
+package password
+
+import (
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"time"
+)
+
+const (
+	// measureRuns замеров на каждые параметры; берется самый быстрый, остальные могли попасть на GC или соседей
+	measureRuns = 3
+	// maxLinearCost верхняя граница проходов argon2id и p у scrypt, чтобы неточный замер не увел оценку в бесконечность
+	maxLinearCost = 1024
+)
+
+// Calibrate самые дорогие параметры не слабее base, с которыми хэш на этой машине считается не дольше target,
+// и время хэша с ними. Растет только время, а не память: память на хэш умножается на размер пула хэширования.
+// bcrypt повышает стоимость, argon2id число проходов, scrypt p. Если base уже дольше target, возвращается base
+func Calibrate(base Scheme, target time.Duration) (Scheme, time.Duration, error) {
+	const op = "password.Calibrate"
+
+	elapsed, err := measure(base)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if elapsed >= target {
+		return base, elapsed, nil
+	}
+
+	var scheme Scheme
+
+	switch s := base.(type) {
+	case Bcrypt:
+		scheme, elapsed, err = calibrateBcrypt(s, elapsed, target)
+	case Argon2id:
+		scheme, elapsed, err = calibrateLinear(int(s.Iterations), elapsed, target, func(n int) Scheme {
+			s.Iterations = uint32(n)
+			return s
+		})
+	case Scrypt:
+		scheme, elapsed, err = calibrateLinear(s.P, elapsed, target, func(n int) Scheme {
+			s.P = n
+			return s
+		})
+	default:
+		return nil, 0, fmt.Errorf("%s: %s cannot be calibrated", op, base.Algorithm())
+	}
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return scheme, elapsed, nil
+}
+
+// calibrateBcrypt каждая единица стоимости удваивает время, поэтому стоимость повышается по одной
+func calibrateBcrypt(base Bcrypt, elapsed, target time.Duration) (Scheme, time.Duration, error) {
+	for base.Cost < bcrypt.MaxCost {
+		next := Bcrypt{Cost: base.Cost + 1}
+
+		d, err := measure(next)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if d > target {
+			break
+		}
+
+		base, elapsed = next, d
+	}
+
+	return base, elapsed, nil
+}
+
+// calibrateLinear время растет линейно от параметра n: оценка по замеру base уточняется замерами вниз,
+// пока хэш не уложится в target
+func calibrateLinear(n int, elapsed, target time.Duration, scheme func(n int) Scheme) (Scheme, time.Duration, error) {
+	estimate := min(int(int64(n)*int64(target)/int64(max(elapsed, 1))), maxLinearCost)
+
+	for k := estimate; k > n; k-- {
+		d, err := measure(scheme(k))
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if d <= target {
+			return scheme(k), d, nil
+		}
+	}
+
+	return scheme(n), elapsed, nil
+}
+
+func measure(s Scheme) (time.Duration, error) {
+	var fastest time.Duration
+
+	for i := range measureRuns {
+		start := time.Now()
+
+		if _, err := s.Hash("calibration"); err != nil {
+			return 0, fmt.Errorf("%s %s: %w", s.Algorithm(), s.Params(), err)
+		}
+
+		if d := time.Since(start); i == 0 || d < fastest {
+			fastest = d
+		}
+	}
+
+	return fastest, nil
+}
@@ -0,0 +1,80 @@
+package password
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheme(t *testing.T) {
+	for _, scheme := range []Scheme{cheapBcrypt, cheapArgon2id, cheapScrypt, DefaultArgon2id} {
+		t.Run(scheme.Algorithm()+" "+scheme.Params(), func(t *testing.T) {
+			parsed, err := ParseScheme(scheme.Algorithm(), scheme.Params())
+			if err != nil {
+				t.Fatalf("ParseScheme: %v", err)
+			}
+
+			if parsed != scheme {
+				t.Errorf("ParseScheme = %#v, want %#v", parsed, scheme)
+			}
+		})
+	}
+
+	for _, tt := range []struct{ algorithm, params string }{
+		{AlgorithmBcrypt, "m=1,t=1,p=1"},
+		{AlgorithmArgon2id, "m=64,t=0,p=1"},
+		{AlgorithmScrypt, "cost=10"},
+		{"md5", "cost=10"},
+	} {
+		if _, err := ParseScheme(tt.algorithm, tt.params); err == nil {
+			t.Errorf("ParseScheme(%q, %q): want error", tt.algorithm, tt.params)
+		}
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	const target = 20 * time.Millisecond
+
+	tests := []struct {
+		base     Scheme
+		stronger func(s Scheme) bool
+	}{
+		{base: cheapBcrypt, stronger: func(s Scheme) bool { return s.(Bcrypt).Cost > cheapBcrypt.Cost }},
+		{base: cheapArgon2id, stronger: func(s Scheme) bool {
+			a := s.(Argon2id)
+			return a.Iterations > cheapArgon2id.Iterations && a.Memory == cheapArgon2id.Memory
+		}},
+		{base: cheapScrypt, stronger: func(s Scheme) bool {
+			sc := s.(Scrypt)
+			return sc.P > cheapScrypt.P && sc.LogN == cheapScrypt.LogN
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.base.Algorithm(), func(t *testing.T) {
+			scheme, elapsed, err := Calibrate(tt.base, target)
+			if err != nil {
+				t.Fatalf("Calibrate: %v", err)
+			}
+
+			if !tt.stronger(scheme) {
+				t.Errorf("Calibrate = %s, want stronger than %s", scheme.Params(), tt.base.Params())
+			}
+
+			if elapsed <= 0 || elapsed > target {
+				t.Errorf("elapsed = %v, want (0, %v]", elapsed, target)
+			}
+		})
+	}
+}
+
+func TestCalibrate_KeepsBaseAboveTarget(t *testing.T) {
+	//параметры конфига - нижняя граница, даже если они дольше цели
+	scheme, _, err := Calibrate(cheapArgon2id, time.Nanosecond)
+	if err != nil {
+		t.Fatalf("Calibrate: %v", err)
+	}
+
+	if scheme != cheapArgon2id {
+		t.Errorf("Calibrate = %s, want base %s", scheme.Params(), cheapArgon2id.Params())
+	}
+}
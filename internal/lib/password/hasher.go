@@ -0,0 +1,183 @@
+package password
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmScrypt   = "scrypt"
+
+	saltLength = 16
+	keyLength  = 32
+)
+
+var (
+	ErrMismatch    = errors.New("password does not match hash")
+	ErrUnknownHash = errors.New("unknown password hash format")
+
+	// b64 кодировка соли и ключа в PHC строке
+	b64 = base64.RawStdEncoding
+)
+
+// Verifier проверяет хэши одного алгоритма
+type Verifier interface {
+	// Algorithm идентификатор алгоритма, он же в начале хэша
+	Algorithm() string
+	// Verify ErrMismatch для неверного пароля; outdated - хэш сделан с другими параметрами, чем у схемы
+	Verify(password string, hash []byte) (outdated bool, err error)
+}
+
+// Scheme алгоритм хэширования с параметрами. Хэш хранится строкой PHC вида $argon2id$v=19$m=...$соль$ключ,
+// поэтому по нему видно, чем и с какими параметрами он сделан
+type Scheme interface {
+	Verifier
+	Hash(password string) ([]byte, error)
+	// Params параметры схемы в формате PHC, например m=19456,t=2,p=1; ParseScheme разбирает их обратно
+	Params() string
+}
+
+// ParseScheme схема algorithm с параметрами params в формате Scheme.Params
+func ParseScheme(algorithm, params string) (Scheme, error) {
+	switch algorithm {
+	case AlgorithmBcrypt:
+		return parseBcrypt(params)
+	case AlgorithmArgon2id:
+		return parseArgon2id(params)
+	case AlgorithmScrypt:
+		return parseScrypt(params)
+	}
+
+	return nil, fmt.Errorf("unknown password hash algorithm %q", algorithm)
+}
+
+// Hasher хэширует новые пароли настроенной схемой и проверяет хэши всех известных алгоритмов
+type Hasher struct {
+	current   Scheme
+	verifiers map[string]Verifier
+}
+
+// NewHasher хэшер с текущей схемой current, который кроме встроенных схем проверяет хэши legacy.
+// Пробный хэш проверяет параметры при старте, а не при первой регистрации
+func NewHasher(current Scheme, legacy ...Verifier) (*Hasher, error) {
+	const op = "password.NewHasher"
+
+	if _, err := current.Hash(""); err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, current.Algorithm(), err)
+	}
+
+	//параметры остальных схем при проверке берутся из самого хэша
+	verifiers := map[string]Verifier{}
+
+	for _, v := range legacy {
+		verifiers[v.Algorithm()] = v
+	}
+
+	for _, v := range []Verifier{DefaultBcrypt, DefaultArgon2id, DefaultScrypt, current} {
+		verifiers[v.Algorithm()] = v
+	}
+
+	return &Hasher{current: current, verifiers: verifiers}, nil
+}
+
+func (h *Hasher) Hash(password string) ([]byte, error) {
+	return h.current.Hash(password)
+}
+
+// Current настроенная схема, которой хэшируются новые пароли
+func (h *Hasher) Current() Scheme {
+	return h.current
+}
+
+// Verify проверяет пароль по хэшу любого известного алгоритма. rehash - хэш сделан другим алгоритмом
+// или с другими параметрами, чем настроены сейчас: его стоит пересчитать, пока пароль известен
+func (h *Hasher) Verify(password string, hash []byte) (rehash bool, err error) {
+	algorithm := Algorithm(hash)
+
+	verifier, ok := h.verifiers[algorithm]
+
+	if !ok {
+		return false, ErrUnknownHash
+	}
+
+	outdated, err := verifier.Verify(password, hash)
+
+	if err != nil {
+		return false, err
+	}
+
+	return outdated || algorithm != h.current.Algorithm(), nil
+}
+
+// Supports хэш известного алгоритма: при импорте пользователей хэш нельзя проверить без пароля,
+// поэтому проверяется только алгоритм
+func (h *Hasher) Supports(hash []byte) bool {
+	_, ok := h.verifiers[Algorithm(hash)]
+	return ok
+}
+
+// Algorithm алгоритм хэша по идентификатору PHC; bcrypt хранит версию $2a$, $2b$ или $2y$,
+// а Django пишет алгоритм без ведущего $
+func Algorithm(hash []byte) string {
+	s := strings.TrimPrefix(string(hash), "$")
+
+	id, _, ok := strings.Cut(s, "$")
+
+	if !ok {
+		return ""
+	}
+
+	switch id {
+	case "2a", "2b", "2y":
+		return AlgorithmBcrypt
+	}
+
+	return id
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltLength)
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
+
+// phcParts поля PHC строки после алгоритма; n ожидаемое число полей
+func phcParts(hash []byte, algorithm string, n int) ([]string, error) {
+	parts := strings.Split(string(hash), "$")
+
+	if len(parts) != n+2 || parts[0] != "" || parts[1] != algorithm {
+		return nil, ErrUnknownHash
+	}
+
+	return parts[2:], nil
+}
+
+// phcSaltKey соль и ключ из двух последних полей PHC строки
+func phcSaltKey(salt, key string) ([]byte, []byte, error) {
+	s, err := b64.DecodeString(salt)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: salt: %w", ErrUnknownHash, err)
+	}
+
+	k, err := b64.DecodeString(key)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: key: %w", ErrUnknownHash, err)
+	}
+
+	if len(k) == 0 {
+		return nil, nil, fmt.Errorf("%w: empty key", ErrUnknownHash)
+	}
+
+	return s, k, nil
+}
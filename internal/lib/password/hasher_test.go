@@ -0,0 +1,159 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// cheap схемы с минимальной стоимостью, чтобы тесты не тратили время на хэширование
+var (
+	cheapBcrypt   = Bcrypt{Cost: 4}
+	cheapArgon2id = Argon2id{Memory: 64, Iterations: 1, Parallelism: 1}
+	cheapScrypt   = Scrypt{LogN: 4, R: 8, P: 1}
+)
+
+func newHasher(t *testing.T, current Scheme, legacy ...Verifier) *Hasher {
+	t.Helper()
+
+	h, err := NewHasher(current, legacy...)
+	if err != nil {
+		t.Fatalf("NewHasher: %v", err)
+	}
+
+	return h
+}
+
+func TestHasher_HashAndVerify(t *testing.T) {
+	tests := []struct {
+		scheme Scheme
+		prefix string
+	}{
+		{scheme: cheapBcrypt, prefix: "$2a$04$"},
+		{scheme: cheapArgon2id, prefix: "$argon2id$v=19$m=64,t=1,p=1$"},
+		{scheme: cheapScrypt, prefix: "$scrypt$ln=4,r=8,p=1$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scheme.Algorithm(), func(t *testing.T) {
+			h := newHasher(t, tt.scheme)
+
+			hash, err := h.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+
+			if !strings.HasPrefix(string(hash), tt.prefix) {
+				t.Fatalf("hash = %s, want prefix %s", hash, tt.prefix)
+			}
+
+			if Algorithm(hash) != tt.scheme.Algorithm() {
+				t.Errorf("Algorithm() = %q, want %q", Algorithm(hash), tt.scheme.Algorithm())
+			}
+
+			rehash, err := h.Verify("correct horse", hash)
+			if err != nil || rehash {
+				t.Fatalf("Verify = %v, %v, want false, nil", rehash, err)
+			}
+
+			if _, err := h.Verify("wrong horse", hash); !errors.Is(err, ErrMismatch) {
+				t.Fatalf("Verify with wrong password: err = %v, want ErrMismatch", err)
+			}
+
+			//соль случайная: одинаковые пароли дают разные хэши
+			again, err := h.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if string(again) == string(hash) {
+				t.Error("two hashes of the same password are equal")
+			}
+		})
+	}
+}
+
+func TestHasher_Rehash(t *testing.T) {
+	schemes := []Scheme{cheapBcrypt, cheapArgon2id, cheapScrypt}
+
+	for _, from := range schemes {
+		hash, err := newHasher(t, from).Hash("secret")
+		if err != nil {
+			t.Fatalf("Hash: %v", err)
+		}
+
+		for _, to := range schemes {
+			rehash, err := newHasher(t, to).Verify("secret", hash)
+			if err != nil {
+				t.Fatalf("%s hash verified by %s hasher: %v", from.Algorithm(), to.Algorithm(), err)
+			}
+
+			if want := from.Algorithm() != to.Algorithm(); rehash != want {
+				t.Errorf("%s hash verified by %s hasher: rehash = %v, want %v", from.Algorithm(), to.Algorithm(), rehash, want)
+			}
+		}
+	}
+
+	//тот же алгоритм с другой стоимостью тоже пересчитывается
+	outdated := []struct {
+		from, to Scheme
+	}{
+		{from: cheapBcrypt, to: Bcrypt{Cost: 5}},
+		{from: cheapArgon2id, to: Argon2id{Memory: 128, Iterations: 1, Parallelism: 1}},
+		{from: cheapScrypt, to: Scrypt{LogN: 5, R: 8, P: 1}},
+	}
+
+	for _, tt := range outdated {
+		hash, err := tt.from.Hash("secret")
+		if err != nil {
+			t.Fatalf("Hash: %v", err)
+		}
+
+		rehash, err := newHasher(t, tt.to).Verify("secret", hash)
+		if err != nil || !rehash {
+			t.Errorf("%s with new parameters: Verify = %v, %v, want true, nil", tt.to.Algorithm(), rehash, err)
+		}
+	}
+}
+
+func TestHasher_KnownHash(t *testing.T) {
+	h := newHasher(t, cheapArgon2id)
+
+	//тестовый вектор RFC 7914: scrypt("password", "NaCl", N=1024, r=8, p=16, 64 байта) в формате PHC
+	hash := "$scrypt$ln=10,r=8,p=16$TmFDbA$/bq+HJ00cgB4VucZDQHp/nxq18vII3gw53N2Y0s3MWIurzDZLiKjiG/xCSedmDDaxyevuUqD7m2DYMvfoswGQA"
+
+	rehash, err := h.Verify("password", []byte(hash))
+	if err != nil || !rehash {
+		t.Fatalf("Verify = %v, %v, want true, nil", rehash, err)
+	}
+}
+
+func TestHasher_RejectsMalformedHashes(t *testing.T) {
+	h := newHasher(t, cheapArgon2id)
+
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$md5$abc",
+		"$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ",
+		"$argon2id$v=16$m=64,t=1,p=1$c29tZXNhbHQ$LcX+qh7RVE4I1c0U2HC3hvTFNEmWPX6AFfpAPqNiD+0",
+		"$argon2id$v=19$m=64,t=0,p=1$c29tZXNhbHQ$LcX+qh7RVE4I1c0U2HC3hvTFNEmWPX6AFfpAPqNiD+0",
+		"$scrypt$ln=4,r=8,p=1$c29tZXNhbHQ$",
+		"$scrypt$ln=40,r=8,p=1$c29tZXNhbHQ$AAAA",
+	} {
+		if _, err := h.Verify("password", []byte(hash)); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("Verify(%q): err = %v, want ErrUnknownHash", hash, err)
+		}
+	}
+}
+
+func TestNewHasher_InvalidParameters(t *testing.T) {
+	for _, scheme := range []Scheme{
+		Bcrypt{Cost: 40},
+		Argon2id{Memory: 64, Iterations: 0, Parallelism: 1},
+		Scrypt{LogN: 4, R: 0, P: 1},
+	} {
+		if _, err := NewHasher(scheme); err == nil {
+			t.Errorf("NewHasher(%+v) succeeded", scheme)
+		}
+	}
+}
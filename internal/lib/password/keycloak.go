@@ -0,0 +1,92 @@
+package password
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// keycloakSecret поле secretData учетных данных типа password из экспорта Keycloak
+type keycloakSecret struct {
+	Value string `json:"value"`
+	Salt  string `json:"salt"`
+}
+
+// keycloakParams поле credentialData; additionalParameters у argon2 хранят значения списками строк
+type keycloakParams struct {
+	HashIterations       int                 `json:"hashIterations"`
+	Algorithm            string              `json:"algorithm"`
+	AdditionalParameters map[string][]string `json:"additionalParameters"`
+}
+
+// KeycloakHash переводит пароль из экспорта Keycloak (строки secretData и credentialData) в формат PHC,
+// который проверяет Hasher: pbkdf2, pbkdf2-sha256 и pbkdf2-sha512 в PBKDF2, argon2 типа id в Argon2id
+func KeycloakHash(secretData, credentialData string) ([]byte, error) {
+	var secret keycloakSecret
+	var params keycloakParams
+
+	if err := json.Unmarshal([]byte(secretData), &secret); err != nil {
+		return nil, fmt.Errorf("%w: keycloak secretData: %w", ErrUnknownHash, err)
+	}
+
+	if err := json.Unmarshal([]byte(credentialData), &params); err != nil {
+		return nil, fmt.Errorf("%w: keycloak credentialData: %w", ErrUnknownHash, err)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(secret.Salt)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: keycloak salt: %w", ErrUnknownHash, err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(secret.Value)
+
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("%w: keycloak value", ErrUnknownHash)
+	}
+
+	switch params.Algorithm {
+	case "pbkdf2", "pbkdf2-sha256", "pbkdf2-sha512":
+		digest := "sha1"
+		if params.Algorithm != "pbkdf2" {
+			digest = params.Algorithm[len("pbkdf2-"):]
+		}
+
+		return []byte(fmt.Sprintf("$%s$i=%d$%s$%s",
+			PBKDF2{Digest: digest}.Algorithm(), params.HashIterations, b64.EncodeToString(salt), b64.EncodeToString(key))), nil
+	case "argon2":
+		if t := params.param("type"); t != "id" {
+			return nil, fmt.Errorf("%w: keycloak argon2 type %q", ErrUnknownHash, t)
+		}
+
+		if v := params.param("version"); v != "" && v != "1.3" {
+			return nil, fmt.Errorf("%w: keycloak argon2 version %q", ErrUnknownHash, v)
+		}
+
+		memory, err := strconv.ParseUint(params.param("memory"), 10, 32)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: keycloak argon2 memory: %w", ErrUnknownHash, err)
+		}
+
+		parallelism, err := strconv.ParseUint(params.param("parallelism"), 10, 8)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: keycloak argon2 parallelism: %w", ErrUnknownHash, err)
+		}
+
+		a := Argon2id{Memory: uint32(memory), Iterations: uint32(params.HashIterations), Parallelism: uint8(parallelism)}
+
+		return []byte(a.encode(salt, key)), nil
+	}
+
+	return nil, fmt.Errorf("%w: keycloak algorithm %q", ErrUnknownHash, params.Algorithm)
+}
+
+func (p keycloakParams) param(name string) string {
+	if values := p.AdditionalParameters[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package password
+
+import (
+	"errors"
+	"testing"
+)
+
+// хэши пароля legacy-password, сделанные Python hashlib.pbkdf2_hmac так, как их хранят исходные системы
+const (
+	legacyPassword = "legacy-password"
+
+	keycloakSecretData = `{"value":"b0B8gYqQXSxqBpSbMRW6dSNXh9Mj0EtFWVwiMaSmZfob4ffB0nwAEeRe+yhrHcqht6yBJv/AuFhJvyMQ7SgYYQ==",` +
+		`"salt":"a2V5Y2xvYWstc2FsdC0wMQ==","additionalParameters":{}}`
+	keycloakCredentialData = `{"hashIterations":1000,"algorithm":"pbkdf2-sha512","additionalParameters":{}}`
+)
+
+func TestHasher_LegacyHashes(t *testing.T) {
+	h := newHasher(t, cheapArgon2id, LegacyVerifiers...)
+
+	keycloak, err := KeycloakHash(keycloakSecretData, keycloakCredentialData)
+	if err != nil {
+		t.Fatalf("KeycloakHash: %v", err)
+	}
+
+	for name, hash := range map[string]string{
+		"django pbkdf2_sha256": "pbkdf2_sha256$1000$Qx3kZ8yWm2Lp$MLghA0elsHMFMS3PzdTzi6fAGF2lLnAavqLsooCZO2g=",
+		"django pbkdf2_sha1":   "pbkdf2_sha1$1000$Qx3kZ8yWm2Lp$/Emjfs/rRGYcDlX7gGpMcJOQZ6E=",
+		"phc pbkdf2-sha256":    "$pbkdf2-sha256$i=1000$cGhjLXNhbHQtMDAwMDAwMQ$1kn6lFyadRLYaHqxHv4za5IkRxjELRp1AwcD0FZtHLw",
+		"keycloak":             string(keycloak),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if !h.Supports([]byte(hash)) {
+				t.Fatalf("Supports(%s) = false", hash)
+			}
+
+			//проверенный чужой хэш всегда пересчитывается
+			rehash, err := h.Verify(legacyPassword, []byte(hash))
+			if err != nil || !rehash {
+				t.Fatalf("Verify = %v, %v, want true, nil", rehash, err)
+			}
+
+			if _, err := h.Verify("wrong-password", []byte(hash)); !errors.Is(err, ErrMismatch) {
+				t.Fatalf("Verify with wrong password: err = %v, want ErrMismatch", err)
+			}
+		})
+	}
+}
+
+func TestHasher_LegacyVerifiersArePluggable(t *testing.T) {
+	hash := []byte("pbkdf2_sha256$1000$Qx3kZ8yWm2Lp$MLghA0elsHMFMS3PzdTzi6fAGF2lLnAavqLsooCZO2g=")
+
+	h := newHasher(t, cheapArgon2id)
+
+	if h.Supports(hash) {
+		t.Fatal("hasher without legacy verifiers supports a django hash")
+	}
+
+	if _, err := h.Verify(legacyPassword, hash); !errors.Is(err, ErrUnknownHash) {
+		t.Fatalf("Verify: err = %v, want ErrUnknownHash", err)
+	}
+}
+
+func TestKeycloakHash(t *testing.T) {
+	hash, err := KeycloakHash(keycloakSecretData, keycloakCredentialData)
+	if err != nil {
+		t.Fatalf("KeycloakHash: %v", err)
+	}
+
+	if Algorithm(hash) != "pbkdf2-sha512" {
+		t.Errorf("algorithm = %q, want pbkdf2-sha512", Algorithm(hash))
+	}
+
+	argon2, err := KeycloakHash(`{"value":"AAAA","salt":"AAAA"}`,
+		`{"hashIterations":5,"algorithm":"argon2","additionalParameters":{"type":["id"],"version":["1.3"],"memory":["7168"],"parallelism":["1"],"hashLength":["32"]}}`)
+	if err != nil {
+		t.Fatalf("KeycloakHash(argon2): %v", err)
+	}
+
+	if want := "$argon2id$v=19$m=7168,t=5,p=1$AAAA$AAAA"; string(argon2) != want {
+		t.Errorf("argon2 hash = %s, want %s", argon2, want)
+	}
+
+	for _, credentialData := range []string{
+		`{"hashIterations":1000,"algorithm":"md5"}`,
+		`{"hashIterations":5,"algorithm":"argon2","additionalParameters":{"type":["i"],"memory":["7168"],"parallelism":["1"]}}`,
+		`not json`,
+	} {
+		if _, err := KeycloakHash(keycloakSecretData, credentialData); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("KeycloakHash(%s): err = %v, want ErrUnknownHash", credentialData, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package password
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// maxPBKDF2Iterations больше итераций не бывает в реальных системах; импортированный хэш с таким числом
+// превратил бы каждый вход в нагрузку на CPU
+const maxPBKDF2Iterations = 10_000_000
+
+// LegacyVerifiers форматы других систем, из которых импортируются пользователи. Ими хэши только проверяются,
+// после входа они пересчитываются настроенной схемой
+var LegacyVerifiers = []Verifier{
+	PBKDF2{Digest: "sha1"},
+	PBKDF2{Digest: "sha256"},
+	PBKDF2{Digest: "sha512"},
+	DjangoPBKDF2{Digest: "sha1"},
+	DjangoPBKDF2{Digest: "sha256"},
+}
+
+var digests = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// PBKDF2 (RFC 8018) в формате PHC $pbkdf2-sha256$i=итерации$соль$ключ. В него же переводятся
+// хэши из экспорта Keycloak
+type PBKDF2 struct {
+	Digest string
+}
+
+func (p PBKDF2) Algorithm() string {
+	return "pbkdf2-" + p.Digest
+}
+
+func (p PBKDF2) Verify(password string, hash []byte) (bool, error) {
+	parts, err := phcParts(hash, p.Algorithm(), 3)
+
+	if err != nil {
+		return false, err
+	}
+
+	var iterations int
+
+	if _, err := fmt.Sscanf(parts[0], "i=%d", &iterations); err != nil {
+		return false, fmt.Errorf("%w: pbkdf2 parameters %q", ErrUnknownHash, parts[0])
+	}
+
+	salt, key, err := phcSaltKey(parts[1], parts[2])
+
+	if err != nil {
+		return false, err
+	}
+
+	return false, verifyPBKDF2(p.Digest, password, salt, iterations, key)
+}
+
+// DjangoPBKDF2 формат Django: pbkdf2_sha256$итерации$соль$ключ в base64, соль хранится как есть
+type DjangoPBKDF2 struct {
+	Digest string
+}
+
+func (d DjangoPBKDF2) Algorithm() string {
+	return "pbkdf2_" + d.Digest
+}
+
+func (d DjangoPBKDF2) Verify(password string, hash []byte) (bool, error) {
+	parts := strings.Split(string(hash), "$")
+
+	if len(parts) != 4 || parts[0] != d.Algorithm() {
+		return false, ErrUnknownHash
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+
+	if err != nil {
+		return false, fmt.Errorf("%w: django iterations %q", ErrUnknownHash, parts[1])
+	}
+
+	key, err := base64.StdEncoding.DecodeString(parts[3])
+
+	if err != nil || len(key) == 0 {
+		return false, fmt.Errorf("%w: django key", ErrUnknownHash)
+	}
+
+	return false, verifyPBKDF2(d.Digest, password, []byte(parts[2]), iterations, key)
+}
+
+func verifyPBKDF2(digest, password string, salt []byte, iterations int, key []byte) error {
+	newHash, ok := digests[digest]
+
+	if !ok {
+		return fmt.Errorf("%w: pbkdf2 digest %q", ErrUnknownHash, digest)
+	}
+
+	if iterations < 1 || iterations > maxPBKDF2Iterations {
+		return fmt.Errorf("%w: pbkdf2 iterations %d", ErrUnknownHash, iterations)
+	}
+
+	computed, err := pbkdf2.Key(newHash, password, salt, iterations, len(key))
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnknownHash, err)
+	}
+
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return ErrMismatch
+	}
+
+	return nil
+}
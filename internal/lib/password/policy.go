@@ -0,0 +1,137 @@
+// Package password пароли пользователей: требования к новым паролям (длина, классы символов, отсутствие email,
+// локальный список утекших и частых паролей) и хэширование bcrypt, argon2id и scrypt в формате PHC
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// причины нарушений в формате google.rpc.BadRequest.FieldViolation.reason
+const (
+	ReasonTooShort      = "PASSWORD_TOO_SHORT"
+	ReasonTooLong       = "PASSWORD_TOO_LONG"
+	ReasonCharClasses   = "PASSWORD_CHAR_CLASSES"
+	ReasonContainsEmail = "PASSWORD_CONTAINS_EMAIL"
+	ReasonBreached      = "PASSWORD_BREACHED"
+
+	// minEmailPart короче этого локальная часть email не ищется в пароле: "al" встречается слишком часто
+	minEmailPart = 3
+)
+
+// Policy требования к паролю. Нулевое значение поля отключает соответствующую проверку
+type Policy struct {
+	// MinLength минимальная длина в символах
+	MinLength int
+	// MaxLength максимальная длина в байтах: bcrypt не принимает пароли длиннее 72 байт
+	MaxLength int
+	// MinCharClasses сколько классов из четырех нужно: строчные, заглавные, цифры, остальные символы
+	MinCharClasses int
+	// RejectEmail запретить пароли, содержащие email или его локальную часть
+	RejectEmail bool
+	// Blocklist утекшие и частые пароли
+	Blocklist *Blocklist
+}
+
+// Violation нарушенное требование: Reason для клиентов, Description для пользователя
+type Violation struct {
+	Reason      string
+	Description string
+}
+
+// PolicyError пароль не прошел проверку, в Violations все нарушенные требования
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	descriptions := make([]string, 0, len(e.Violations))
+
+	for _, v := range e.Violations {
+		descriptions = append(descriptions, v.Description)
+	}
+
+	return "password " + strings.Join(descriptions, "; ")
+}
+
+// Check проверяет пароль пользователя с адресом email. Возвращает *PolicyError со всеми нарушениями сразу,
+// чтобы пользователь не подбирал пароль по одному требованию
+func (p Policy) Check(password, email string) error {
+	var violations []Violation
+
+	if p.MinLength > 0 && utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{
+			Reason:      ReasonTooShort,
+			Description: fmt.Sprintf("must be at least %d characters long", p.MinLength),
+		})
+	}
+
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, Violation{
+			Reason:      ReasonTooLong,
+			Description: fmt.Sprintf("must be at most %d bytes long", p.MaxLength),
+		})
+	}
+
+	if p.MinCharClasses > 0 && charClasses(password) < p.MinCharClasses {
+		violations = append(violations, Violation{
+			Reason: ReasonCharClasses,
+			Description: fmt.Sprintf("must contain at least %d of: lowercase letters, uppercase letters, digits, symbols",
+				p.MinCharClasses),
+		})
+	}
+
+	if p.RejectEmail && containsEmail(password, email) {
+		violations = append(violations, Violation{
+			Reason:      ReasonContainsEmail,
+			Description: "must not contain the email address",
+		})
+	}
+
+	if p.Blocklist != nil && p.Blocklist.Contains(password) {
+		violations = append(violations, Violation{
+			Reason:      ReasonBreached,
+			Description: "is too common or has appeared in a data breach",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, other int
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+
+	return lower + upper + digit + other
+}
+
+func containsEmail(password, email string) bool {
+	password = strings.ToLower(password)
+	email = strings.ToLower(email)
+
+	local, _, _ := strings.Cut(email, "@")
+
+	if utf8.RuneCountInString(local) < minEmailPart {
+		return email != "" && strings.Contains(password, email)
+	}
+
+	return strings.Contains(password, local)
+}
@@ -0,0 +1,77 @@
+package password
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func reasons(err error) []string {
+	var policyErr *PolicyError
+
+	if !errors.As(err, &policyErr) {
+		return nil
+	}
+
+	var res []string
+	for _, v := range policyErr.Violations {
+		res = append(res, v.Reason)
+	}
+
+	return res
+}
+
+func TestPolicy_Check(t *testing.T) {
+	blocklist := NewBlocklist(2, 0.001)
+	blocklist.Add("correcthorse")
+	blocklist.Add("qwerty123")
+
+	policy := Policy{MinLength: 8, MaxLength: 72, MinCharClasses: 2, RejectEmail: true, Blocklist: blocklist}
+
+	tests := []struct {
+		name     string
+		password string
+		email    string
+		want     []string
+	}{
+		{name: "valid", password: "Tr0ub4dor&3", email: "user@example.com"},
+		{name: "multibyte characters count as one", password: "пароль12", email: "user@example.com"},
+		{name: "too short", password: "Ab1!", email: "user@example.com", want: []string{ReasonTooShort}},
+		{name: "too long", password: strings.Repeat("Ab1", 25), email: "user@example.com", want: []string{ReasonTooLong}},
+		{name: "single class", password: "abcdefghij", email: "user@example.com", want: []string{ReasonCharClasses}},
+		{name: "contains local part", password: "Alice-2024", email: "alice@example.com", want: []string{ReasonContainsEmail}},
+		{name: "short local part is not searched", password: "Al-2024-xyz", email: "al@example.com"},
+		{name: "contains short local email", password: "al@example.com1", email: "al@example.com", want: []string{ReasonContainsEmail}},
+		{name: "breached ignores case", password: "QWERTY123", email: "user@example.com", want: []string{ReasonBreached}},
+		{
+			name:     "all violations at once",
+			password: "bob",
+			email:    "bob@example.com",
+			want:     []string{ReasonTooShort, ReasonCharClasses, ReasonContainsEmail},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.password, tt.email)
+
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Check() = %v, want nil", err)
+				}
+				return
+			}
+
+			if got := reasons(err); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Check() reasons = %v, want %v (err %v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_ZeroValueAcceptsAnything(t *testing.T) {
+	if err := (Policy{}).Check("a", "a@example.com"); err != nil {
+		t.Fatalf("Check() = %v, want nil", err)
+	}
+}
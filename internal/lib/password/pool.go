@@ -0,0 +1,174 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrPoolFull = errors.New("password hashing queue is full")
+
+// waitBuckets верхние границы корзин гистограммы ожидания в очереди
+var waitBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Pool ограничивает число одновременных хэширований: хэш стоит десятки миллисекунд процессора, и всплеск
+// входов без ограничения занимает все ядра. Сверх concurrency ждут в очереди до queueDepth запросов,
+// остальные сразу получают ErrPoolFull
+type Pool struct {
+	hasher *Hasher
+	//slots занятые хэшированием, admitted - они же плюс ожидающие в очереди
+	slots    chan struct{}
+	admitted chan struct{}
+
+	rejected  atomic.Uint64
+	completed atomic.Uint64
+
+	mu   sync.Mutex
+	wait waitHistogram
+}
+
+// PoolStats состояние пула для метрик
+type PoolStats struct {
+	Concurrency int               `json:"concurrency"`
+	QueueDepth  int               `json:"queue_depth"`
+	Running     int               `json:"running"`
+	Queued      int               `json:"queued"`
+	Rejected    uint64            `json:"rejected"`
+	Completed   uint64            `json:"completed"`
+	QueueWait   QueueWaitSnapshot `json:"queue_wait"`
+}
+
+// QueueWaitSnapshot гистограмма времени ожидания в очереди. Buckets накопительные, как у Prometheus:
+// число ожиданий не дольше границы в секундах
+type QueueWaitSnapshot struct {
+	Count      uint64            `json:"count"`
+	SumSeconds float64           `json:"sum_seconds"`
+	Buckets    map[string]uint64 `json:"buckets"`
+}
+
+type waitHistogram struct {
+	count   uint64
+	sum     time.Duration
+	buckets []uint64
+}
+
+func NewPool(hasher *Hasher, concurrency, queueDepth int) *Pool {
+	return &Pool{
+		hasher:   hasher,
+		slots:    make(chan struct{}, concurrency),
+		admitted: make(chan struct{}, concurrency+queueDepth),
+		wait:     waitHistogram{buckets: make([]uint64, len(waitBuckets))},
+	}
+}
+
+// Hash как Hasher.Hash, но в очереди пула
+func (p *Pool) Hash(ctx context.Context, password string) ([]byte, error) {
+	var (
+		hash []byte
+		err  error
+	)
+
+	if poolErr := p.do(ctx, func() { hash, err = p.hasher.Hash(password) }); poolErr != nil {
+		return nil, poolErr
+	}
+
+	return hash, err
+}
+
+// Verify как Hasher.Verify, но в очереди пула
+func (p *Pool) Verify(ctx context.Context, password string, hash []byte) (rehash bool, err error) {
+	if poolErr := p.do(ctx, func() { rehash, err = p.hasher.Verify(password, hash) }); poolErr != nil {
+		return false, poolErr
+	}
+
+	return rehash, err
+}
+
+// do выполняет fn, когда освободится слот. ErrPoolFull - очередь заполнена, ошибка ctx - запрос
+// отменен, пока ждал
+func (p *Pool) do(ctx context.Context, fn func()) error {
+	select {
+	case p.admitted <- struct{}{}:
+	default:
+		p.rejected.Add(1)
+		return ErrPoolFull
+	}
+	defer func() { <-p.admitted }()
+
+	queued := time.Now()
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-p.slots }()
+
+	p.observeWait(time.Since(queued))
+
+	fn()
+
+	p.completed.Add(1)
+
+	return nil
+}
+
+func (p *Pool) observeWait(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.wait.count++
+	p.wait.sum += d
+
+	for i, bound := range waitBuckets {
+		if d <= bound {
+			p.wait.buckets[i]++
+		}
+	}
+}
+
+// Stats снимок для метрик; Running и Queued меняются конкурентно и согласованы приблизительно
+func (p *Pool) Stats() PoolStats {
+	running := len(p.slots)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	buckets := make(map[string]uint64, len(waitBuckets)+1)
+
+	for i, bound := range waitBuckets {
+		buckets[formatSeconds(bound)] = p.wait.buckets[i]
+	}
+
+	buckets["+Inf"] = p.wait.count
+
+	return PoolStats{
+		Concurrency: cap(p.slots),
+		QueueDepth:  cap(p.admitted) - cap(p.slots),
+		Running:     running,
+		Queued:      max(len(p.admitted)-running, 0),
+		Rejected:    p.rejected.Load(),
+		Completed:   p.completed.Load(),
+		QueueWait: QueueWaitSnapshot{
+			Count:      p.wait.count,
+			SumSeconds: p.wait.sum.Seconds(),
+			Buckets:    buckets,
+		},
+	}
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
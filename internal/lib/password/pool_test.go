@@ -0,0 +1,128 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// occupy занимает n слотов пула, пока не закрыт release
+func occupy(t *testing.T, p *Pool, n int, release <-chan struct{}) *sync.WaitGroup {
+	t.Helper()
+
+	var wg sync.WaitGroup
+
+	for range n {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := p.do(context.Background(), func() { <-release }); err != nil {
+				t.Errorf("do: %v", err)
+			}
+		}()
+	}
+
+	waitFor(t, func() bool {
+		stats := p.Stats()
+		return stats.Running == min(n, stats.Concurrency) && stats.Running+stats.Queued == n
+	})
+
+	return &wg
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPool_HashAndVerify(t *testing.T) {
+	p := NewPool(newHasher(t, cheapArgon2id), 2, 2)
+
+	hash, err := p.Hash(context.Background(), "correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	if _, err := p.Verify(context.Background(), "correct horse", hash); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if _, err := p.Verify(context.Background(), "wrong horse", hash); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("Verify with wrong password: err = %v, want ErrMismatch", err)
+	}
+
+	stats := p.Stats()
+
+	if stats.Completed != 3 || stats.QueueWait.Count != 3 || stats.QueueWait.Buckets["+Inf"] != 3 {
+		t.Errorf("stats = %+v, want 3 completed and observed", stats)
+	}
+}
+
+func TestPool_LimitsConcurrency(t *testing.T) {
+	p := NewPool(newHasher(t, cheapArgon2id), 2, 1)
+
+	release := make(chan struct{})
+	wg := occupy(t, p, 3, release)
+
+	stats := p.Stats()
+
+	if stats.Running != 2 || stats.Queued != 1 {
+		t.Fatalf("running = %d, queued = %d, want 2 and 1", stats.Running, stats.Queued)
+	}
+
+	//слоты заняты, очередь заполнена: новый запрос не ждет
+	if _, err := p.Hash(context.Background(), "correct horse"); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("Hash with full queue: err = %v, want ErrPoolFull", err)
+	}
+
+	if p.Stats().Rejected != 1 {
+		t.Errorf("rejected = %d, want 1", p.Stats().Rejected)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if _, err := p.Hash(context.Background(), "correct horse"); err != nil {
+		t.Fatalf("Hash after release: %v", err)
+	}
+
+	stats = p.Stats()
+
+	if stats.Running != 0 || stats.Queued != 0 || stats.Completed != 4 {
+		t.Errorf("stats = %+v, want empty pool with 4 completed", stats)
+	}
+}
+
+func TestPool_CanceledWhileQueued(t *testing.T) {
+	p := NewPool(newHasher(t, cheapArgon2id), 1, 1)
+
+	release := make(chan struct{})
+	wg := occupy(t, p, 1, release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := p.Hash(ctx, "correct horse"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Hash: err = %v, want DeadlineExceeded", err)
+	}
+
+	//отмененный запрос освобождает место в очереди
+	if p.Stats().Queued != 0 {
+		t.Errorf("queued = %d, want 0", p.Stats().Queued)
+	}
+
+	close(release)
+	wg.Wait()
+}
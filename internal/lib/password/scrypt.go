@@ -0,0 +1,93 @@
+package password
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// DefaultScrypt N=2^15, r=8, p=1: 32 MiB памяти, параметры из документации x/crypto/scrypt
+var DefaultScrypt = Scrypt{LogN: 15, R: 8, P: 1}
+
+// Scrypt (RFC 7914) в формате $scrypt$ln=log2(N),r=...,p=...$соль$ключ
+type Scrypt struct {
+	LogN uint8
+	R    int
+	P    int
+}
+
+func (s Scrypt) Algorithm() string {
+	return AlgorithmScrypt
+}
+
+func (s Scrypt) Hash(password string) ([]byte, error) {
+	salt, err := newSalt()
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.key(password, salt, keyLength)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf("$%s$%s$%s$%s",
+		AlgorithmScrypt, s.Params(), b64.EncodeToString(salt), b64.EncodeToString(key))), nil
+}
+
+func (s Scrypt) Verify(password string, hash []byte) (bool, error) {
+	parts, err := phcParts(hash, AlgorithmScrypt, 3)
+
+	if err != nil {
+		return false, err
+	}
+
+	stored, err := parseScrypt(parts[0])
+
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrUnknownHash, err)
+	}
+
+	salt, key, err := phcSaltKey(parts[1], parts[2])
+
+	if err != nil {
+		return false, err
+	}
+
+	computed, err := stored.key(password, salt, len(key))
+
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrUnknownHash, err)
+	}
+
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return false, ErrMismatch
+	}
+
+	return stored != s || len(salt) != saltLength || len(key) != keyLength, nil
+}
+
+// Params параметры в формате PHC: ln=log2(N),r=...,p=...
+func (s Scrypt) Params() string {
+	return fmt.Sprintf("ln=%d,r=%d,p=%d", s.LogN, s.R, s.P)
+}
+
+func (s Scrypt) key(password string, salt []byte, length int) ([]byte, error) {
+	if s.LogN < 1 || s.LogN > 30 || s.R < 1 || s.P < 1 {
+		return nil, fmt.Errorf("invalid scrypt parameters ln=%d,r=%d,p=%d", s.LogN, s.R, s.P)
+	}
+
+	return scrypt.Key([]byte(password), salt, 1<<s.LogN, s.R, s.P, length)
+}
+
+func parseScrypt(params string) (Scrypt, error) {
+	var s Scrypt
+
+	if _, err := fmt.Sscanf(params, "ln=%d,r=%d,p=%d", &s.LogN, &s.R, &s.P); err != nil {
+		return Scrypt{}, fmt.Errorf("scrypt parameters %q", params)
+	}
+
+	return s, nil
+}
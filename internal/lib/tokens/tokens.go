@@ -0,0 +1,163 @@
+// Package tokens описывает содержимое access токена независимо от формата: JWT или PASETO
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/lib/opaque"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrUnknownAttribute = errors.New("unknown user attribute in claims template")
+	ErrExpired          = errors.New("token is expired")
+	ErrNotValidYet      = errors.New("token is not valid yet")
+)
+
+// reservedClaims claims, которые выставляет sso и которые нельзя переопределить шаблоном
+var reservedClaims = map[string]struct{}{
+	"iss": {}, "sub": {}, "aud": {}, "exp": {}, "nbf": {}, "iat": {}, "jti": {}, "app_id": {}, "uid": {}, "amr": {}, "sid": {}, "roles": {}, "permissions": {},
+}
+
+// Params параметры выпуска токена, общие для всех приложений
+type Params struct {
+	// Issuer значение iss
+	Issuer string
+	TTL    time.Duration
+	// LegacyClaims дублировать sub в числовом claim uid для старых потребителей
+	LegacyClaims bool
+}
+
+// Claims содержимое access токена. Зарегистрированные claims (RFC 7519) и app_id - отдельными полями,
+// остальное - в Extra
+type Claims struct {
+	ID        string
+	Subject   string
+	Issuer    string
+	Audience  string
+	IssuedAt  time.Time
+	NotBefore time.Time
+	ExpiresAt time.Time
+	AppID     int
+	// AMR способы аутентификации (RFC 8176): pwd, otp, mfa
+	AMR []string
+	// SessionID сессия, в которой выпущен токен; ее отзыв делает токен недействительным
+	SessionID string
+	// Roles и Permissions роли пользователя, действующие в приложении токена, и объединение их прав
+	Roles       []string
+	Permissions []string
+	// Extra claims из шаблона приложения, а у токенов старого формата еще и uid
+	Extra map[string]any
+}
+
+// NewClaims собирает claims токена пользователя для приложения; aud - имя приложения
+func NewClaims(user models.User, app models.App, params Params, now time.Time) (Claims, error) {
+	jti, err := opaque.NewID()
+	if err != nil {
+		return Claims{}, err
+	}
+
+	claims := Claims{
+		ID:        jti,
+		Subject:   strconv.FormatInt(user.ID, 10),
+		Issuer:    params.Issuer,
+		Audience:  app.Name,
+		IssuedAt:  now,
+		NotBefore: now,
+		ExpiresAt: now.Add(params.TTL),
+		AppID:     app.ID,
+		Extra:     map[string]any{},
+	}
+
+	if err := applyTemplate(claims.Extra, user, app.ClaimsTemplate); err != nil {
+		return Claims{}, err
+	}
+
+	if params.LegacyClaims {
+		claims.Extra["uid"] = user.ID
+	}
+
+	return claims, nil
+}
+
+// ParseStrings claim со списком строк (amr, roles, permissions) после разбора JSON, где массив приходит как []any
+func ParseStrings(value any) []string {
+	list, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+
+	res := make([]string, 0, len(list))
+
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			res = append(res, s)
+		}
+	}
+
+	return res
+}
+
+// IsReserved сообщает, что claim выставляется sso и не относится к Extra
+func IsReserved(name string) bool {
+	_, ok := reservedClaims[name]
+	return ok
+}
+
+// UserID id пользователя из sub, а у токенов старого формата - из uid
+func (c Claims) UserID() (int64, bool) {
+	if c.Subject != "" {
+		id, err := strconv.ParseInt(c.Subject, 10, 64)
+		return id, err == nil
+	}
+
+	switch uid := c.Extra["uid"].(type) {
+	case float64:
+		return int64(uid), true
+	case int64:
+		return uid, true
+	}
+
+	return 0, false
+}
+
+// Validate проверяет exp и nbf с допустимым расхождением часов leeway. Токен без exp недействителен
+func (c Claims) Validate(now time.Time, leeway time.Duration) error {
+	if c.ExpiresAt.IsZero() || !now.Before(c.ExpiresAt.Add(leeway)) {
+		return ErrExpired
+	}
+
+	if !c.NotBefore.IsZero() && now.Add(leeway).Before(c.NotBefore) {
+		return ErrNotValidYet
+	}
+
+	return nil
+}
+
+func applyTemplate(extra map[string]any, user models.User, template *models.ClaimsTemplate) error {
+	if template == nil {
+		extra[models.UserAttributeEmail] = user.Email
+		return nil
+	}
+
+	for name, value := range template.Custom {
+		if !IsReserved(name) {
+			extra[name] = value
+		}
+	}
+
+	for _, attr := range template.UserAttributes {
+		switch attr {
+		case models.UserAttributeEmail:
+			extra[attr] = user.Email
+		case models.UserAttributeIsAdmin:
+			extra[attr] = user.IsAdmin
+		default:
+			return fmt.Errorf("%w: %s", ErrUnknownAttribute, attr)
+		}
+	}
+
+	return nil
+}
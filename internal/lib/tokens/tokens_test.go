@@ -0,0 +1,73 @@
+package tokens
+
+import (
+	"errors"
+	"sso/internal/domain/models"
+	"testing"
+	"time"
+)
+
+func TestNewClaims_Template(t *testing.T) {
+	user := models.User{ID: 42, Email: "user@test.com", IsAdmin: true}
+	app := models.App{
+		ID:     7,
+		Name:   "TestApp",
+		Secret: "super-secret",
+		ClaimsTemplate: &models.ClaimsTemplate{
+			UserAttributes: []string{models.UserAttributeIsAdmin},
+			Custom:         map[string]any{"tenant": "acme", "sub": "spoofed"},
+		},
+	}
+
+	claims, err := NewClaims(user, app, Params{TTL: time.Hour}, time.Now())
+	if err != nil {
+		t.Fatalf("NewClaims: %v", err)
+	}
+
+	if _, ok := claims.Extra["email"]; ok {
+		t.Error("expected email to be left out by template")
+	}
+	if claims.Extra["is_admin"] != true {
+		t.Errorf("expected is_admin true, got %v", claims.Extra["is_admin"])
+	}
+	if claims.Extra["tenant"] != "acme" {
+		t.Errorf("expected tenant acme, got %v", claims.Extra["tenant"])
+	}
+	if _, ok := claims.Extra["sub"]; ok || claims.Subject != "42" {
+		t.Errorf("expected reserved sub to stay 42, got %q and %v", claims.Subject, claims.Extra["sub"])
+	}
+
+	app.ClaimsTemplate.UserAttributes = []string{"password"}
+
+	if _, err := NewClaims(user, app, Params{TTL: time.Hour}, time.Now()); !errors.Is(err, ErrUnknownAttribute) {
+		t.Fatalf("expected ErrUnknownAttribute, got: %v", err)
+	}
+}
+
+func TestClaims_Validate(t *testing.T) {
+	now := time.Now()
+	claims := Claims{NotBefore: now, ExpiresAt: now.Add(time.Minute)}
+
+	tests := []struct {
+		name    string
+		claims  Claims
+		at      time.Time
+		leeway  time.Duration
+		wantErr error
+	}{
+		{name: "valid", claims: claims, at: now},
+		{name: "expired", claims: claims, at: now.Add(2 * time.Minute), wantErr: ErrExpired},
+		{name: "expired within leeway", claims: claims, at: now.Add(2 * time.Minute), leeway: 2 * time.Minute},
+		{name: "not valid yet", claims: claims, at: now.Add(-time.Minute), wantErr: ErrNotValidYet},
+		{name: "no exp", claims: Claims{}, at: now, wantErr: ErrExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.claims.Validate(tt.at, tt.leeway)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// RFC 6238, приложение B: SHA1, секрет "12345678901234567890", 8 цифр
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode_RFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		step := Step(time.Unix(tt.unix, 0))
+
+		if got := code(rfcSecret, step, 8); got != tt.want {
+			t.Errorf("code(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+
+		//6 цифр - младшие разряды того же значения
+		if got := Code(rfcSecret, step); got != tt.want[2:] {
+			t.Errorf("Code(%d) = %s, want %s", tt.unix, got, tt.want[2:])
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+
+	step, ok := Validate(rfcSecret, Code(rfcSecret, current-1), now, 0)
+	if !ok || step != current-1 {
+		t.Fatalf("expected previous step to be accepted, got %d %v", step, ok)
+	}
+
+	if _, ok := Validate(rfcSecret, Code(rfcSecret, current-2), now, 0); ok {
+		t.Error("expected code outside skew to be rejected")
+	}
+
+	if _, ok := Validate(rfcSecret, Code(rfcSecret, current), now, current); ok {
+		t.Error("expected already used step to be rejected")
+	}
+
+	if _, ok := Validate(rfcSecret, "12345", now, 0); ok {
+		t.Error("expected short code to be rejected")
+	}
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("sso", "user@test.com", rfcSecret))
+	if err != nil {
+		t.Fatalf("parse uri: %v", err)
+	}
+
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/sso:user@test.com" {
+		t.Errorf("unexpected uri %s", u)
+	}
+
+	if got := u.Query().Get("secret"); got != EncodeSecret(rfcSecret) {
+		t.Errorf("secret = %s, want %s", got, EncodeSecret(rfcSecret))
+	}
+}
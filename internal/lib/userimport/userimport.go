@@ -0,0 +1,201 @@
+// Package userimport чтение пользователей других систем для импорта вместе с хэшами паролей.
+// CSV с заголовком email,password_hash[,email_verified] или JSONL, где строка - объект с теми же полями
+// либо пользователь из экспорта Keycloak (например, jq -c '.users[]' realm-export.json)
+package userimport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sso/internal/domain/models"
+	"sso/internal/lib/password"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+
+	maxLineSize = 1 << 20
+)
+
+var ErrInvalidInput = errors.New("invalid import input")
+
+// record строка JSONL: собственный формат или пользователь Keycloak с credentials
+type record struct {
+	Email         string `json:"email"`
+	PasswordHash  string `json:"password_hash"`
+	EmailVerified bool   `json:"email_verified"`
+
+	KeycloakEmailVerified bool                 `json:"emailVerified"`
+	Credentials           []keycloakCredential `json:"credentials"`
+}
+
+type keycloakCredential struct {
+	Type           string `json:"type"`
+	SecretData     string `json:"secretData"`
+	CredentialData string `json:"credentialData"`
+}
+
+// Parse читает пользователей в формате format. Строки, которые не удалось разобрать, попадают в failures,
+// чтение продолжается; ошибка ErrInvalidInput означает, что вход не подходит целиком
+func Parse(r io.Reader, format string) ([]models.ImportedUser, []models.ImportFailure, error) {
+	const op = "userimport.Parse"
+
+	var (
+		users    []models.ImportedUser
+		failures []models.ImportFailure
+		err      error
+	)
+
+	switch format {
+	case FormatCSV:
+		users, failures, err = parseCSV(r)
+	case FormatJSONL:
+		users, failures, err = parseJSONL(r)
+	default:
+		err = fmt.Errorf("%w: unknown format %q", ErrInvalidInput, format)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, failures, nil
+}
+
+func parseCSV(r io.Reader) ([]models.ImportedUser, []models.ImportFailure, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	//email_verified можно не заполнять до конца строки
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: csv header: %w", ErrInvalidInput, err)
+	}
+
+	columns := map[string]int{}
+
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	emailColumn, hasEmail := columns["email"]
+	hashColumn, hasHash := columns["password_hash"]
+	verifiedColumn, hasVerified := columns["email_verified"]
+
+	if !hasEmail || !hasHash {
+		return nil, nil, fmt.Errorf("%w: csv header must contain email and password_hash", ErrInvalidInput)
+	}
+
+	var (
+		users    []models.ImportedUser
+		failures []models.ImportFailure
+	)
+
+	for {
+		row, err := reader.Read()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		if len(row) <= max(emailColumn, hashColumn) {
+			failures = append(failures, models.ImportFailure{Line: line, Reason: "missing fields"})
+			continue
+		}
+
+		user := models.ImportedUser{
+			Line:     line,
+			Email:    strings.TrimSpace(row[emailColumn]),
+			PassHash: []byte(row[hashColumn]),
+		}
+
+		if hasVerified && len(row) > verifiedColumn && row[verifiedColumn] != "" {
+			user.EmailVerified, err = strconv.ParseBool(row[verifiedColumn])
+
+			if err != nil {
+				failures = append(failures, models.ImportFailure{Line: line, Email: user.Email, Reason: "invalid email_verified"})
+				continue
+			}
+		}
+
+		users = append(users, user)
+	}
+
+	return users, failures, nil
+}
+
+func parseJSONL(r io.Reader) ([]models.ImportedUser, []models.ImportFailure, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var (
+		users    []models.ImportedUser
+		failures []models.ImportFailure
+	)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" {
+			continue
+		}
+
+		var rec record
+
+		if err := json.Unmarshal([]byte(text), &rec); err != nil {
+			failures = append(failures, models.ImportFailure{Line: line, Reason: "invalid json"})
+			continue
+		}
+
+		user := models.ImportedUser{
+			Line:          line,
+			Email:         strings.TrimSpace(rec.Email),
+			PassHash:      []byte(rec.PasswordHash),
+			EmailVerified: rec.EmailVerified || rec.KeycloakEmailVerified,
+		}
+
+		if rec.PasswordHash == "" && len(rec.Credentials) > 0 {
+			hash, err := keycloakHash(rec.Credentials)
+
+			if err != nil {
+				failures = append(failures, models.ImportFailure{Line: line, Email: user.Email, Reason: err.Error()})
+				continue
+			}
+
+			user.PassHash = hash
+		}
+
+		users = append(users, user)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+
+	return users, failures, nil
+}
+
+// keycloakHash хэш из учетных данных типа password пользователя Keycloak
+func keycloakHash(credentials []keycloakCredential) ([]byte, error) {
+	for _, c := range credentials {
+		if c.Type == "password" {
+			return password.KeycloakHash(c.SecretData, c.CredentialData)
+		}
+	}
+
+	return nil, errors.New("no password credential")
+}
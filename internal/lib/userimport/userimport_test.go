@@ -0,0 +1,93 @@
+package userimport
+
+import (
+	"errors"
+	"reflect"
+	"sso/internal/domain/models"
+	"sso/internal/lib/password"
+	"strings"
+	"testing"
+)
+
+func TestParse_CSV(t *testing.T) {
+	input := "email,password_hash,email_verified\n" +
+		"a@example.com,$2a$04$hash,true\n" +
+		" b@example.com ,\"pbkdf2_sha256$1000$salt$key=\",\n" +
+		"c@example.com,$2a$04$hash\n" +
+		"d@example.com,$2a$04$hash,maybe\n" +
+		"e@example.com\n"
+
+	users, failures, err := Parse(strings.NewReader(input), FormatCSV)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	wantUsers := []models.ImportedUser{
+		{Line: 2, Email: "a@example.com", PassHash: []byte("$2a$04$hash"), EmailVerified: true},
+		{Line: 3, Email: "b@example.com", PassHash: []byte("pbkdf2_sha256$1000$salt$key=")},
+		{Line: 4, Email: "c@example.com", PassHash: []byte("$2a$04$hash")},
+	}
+
+	if !reflect.DeepEqual(users, wantUsers) {
+		t.Errorf("users = %+v, want %+v", users, wantUsers)
+	}
+
+	wantFailures := []models.ImportFailure{
+		{Line: 5, Email: "d@example.com", Reason: "invalid email_verified"},
+		{Line: 6, Reason: "missing fields"},
+	}
+
+	if !reflect.DeepEqual(failures, wantFailures) {
+		t.Errorf("failures = %+v, want %+v", failures, wantFailures)
+	}
+}
+
+func TestParse_JSONL(t *testing.T) {
+	keycloak := `{"username":"kc","email":"kc@example.com","emailVerified":true,"credentials":[` +
+		`{"type":"otp"},` +
+		`{"type":"password","secretData":"{\"value\":\"AAAA\",\"salt\":\"AAAA\"}",` +
+		`"credentialData":"{\"hashIterations\":27500,\"algorithm\":\"pbkdf2-sha256\"}"}]}`
+
+	input := `{"email":"a@example.com","password_hash":"$2a$04$hash"}` + "\n" +
+		"\n" +
+		keycloak + "\n" +
+		`{"email":"b@example.com",` + "\n" +
+		`{"email":"c@example.com","credentials":[{"type":"otp"}]}` + "\n"
+
+	users, failures, err := Parse(strings.NewReader(input), FormatJSONL)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	wantUsers := []models.ImportedUser{
+		{Line: 1, Email: "a@example.com", PassHash: []byte("$2a$04$hash")},
+		{Line: 3, Email: "kc@example.com", PassHash: []byte("$pbkdf2-sha256$i=27500$AAAA$AAAA"), EmailVerified: true},
+	}
+
+	if !reflect.DeepEqual(users, wantUsers) {
+		t.Errorf("users = %+v, want %+v", users, wantUsers)
+	}
+
+	if password.Algorithm(users[1].PassHash) != "pbkdf2-sha256" {
+		t.Errorf("keycloak hash algorithm = %q", password.Algorithm(users[1].PassHash))
+	}
+
+	if len(failures) != 2 || failures[0].Line != 4 || failures[1].Line != 5 || failures[1].Email != "c@example.com" {
+		t.Errorf("failures = %+v, want lines 4 and 5", failures)
+	}
+}
+
+func TestParse_InvalidInput(t *testing.T) {
+	for name, tt := range map[string]struct{ input, format string }{
+		"unknown format":      {input: "", format: "xml"},
+		"empty csv":           {input: "", format: FormatCSV},
+		"csv without hashes":  {input: "email\na@example.com\n", format: FormatCSV},
+		"csv with bare quote": {input: "email,password_hash\na@example.com,ab\"c\n", format: FormatCSV},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := Parse(strings.NewReader(tt.input), tt.format); !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("err = %v, want ErrInvalidInput", err)
+			}
+		})
+	}
+}
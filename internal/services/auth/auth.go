@@ -4,20 +4,46 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"golang.org/x/crypto/bcrypt"
 	"log/slog"
 	"sso/internal/domain/models"
+	"sso/internal/lib/jwks"
 	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/mail"
+	"sso/internal/lib/paseto"
+	"sso/internal/lib/passkey"
+	"sso/internal/lib/password"
+	"sso/internal/lib/tokens"
 	"sso/internal/storage"
 	"time"
 )
 
 type Auth struct {
-	log          *slog.Logger
-	userSaver    UserSaver
-	userProvider UserProvider
-	appProvider  AppProvider
-	tokenTTL     time.Duration
+	log             *slog.Logger
+	userSaver       UserSaver
+	userProvider    UserProvider
+	appProvider     AppProvider
+	keyProvider     KeyProvider
+	tokenStorage    TokenStorage
+	codeStorage     CodeStorage
+	attemptStorage  AttemptStorage
+	mfaStorage      MFAStorage
+	passkeyStorage  PasskeyStorage
+	sessionStorage  SessionStorage
+	roleStorage     RoleStorage
+	mailer          Mailer
+	relyingParty    *passkey.RelyingParty
+	passwordHasher  PasswordHasher
+	importer        UserImporter
+	hashParams      models.HashParams
+	tokenTTL        time.Duration
+	refreshTTL      time.Duration
+	verificationTTL time.Duration
+	resetTTL        time.Duration
+	throttle        ThrottlePolicy
+	passwordPolicy  password.Policy
+	issuer          string
+	legacyClaims    bool
 }
 
 type UserSaver interface {
@@ -25,10 +51,13 @@ type UserSaver interface {
 		ctx context.Context,
 		email string,
 		passHash []byte) (uid int64, err error)
+	UpdatePassword(ctx context.Context, userId int64, passHash []byte, now time.Time, revokeSessions bool) error
+	RehashPassword(ctx context.Context, userId int64, oldHash []byte, newHash []byte) error
 }
 
 type UserProvider interface {
 	User(ctx context.Context, email string) (models.User, error)
+	UserByID(ctx context.Context, id int64) (models.User, error)
 	IsAdmin(ctx context.Context, userId int64) (bool, error)
 }
 
@@ -36,73 +65,244 @@ type AppProvider interface {
 	App(ctx context.Context, appId int) (models.App, error)
 }
 
+type KeyProvider interface {
+	ActiveAppKey(ctx context.Context, appId int) (models.AppKey, error)
+	AppKeys(ctx context.Context, appId int) ([]models.AppKey, error)
+	PublishedAppKeys(ctx context.Context, now time.Time) ([]models.AppKey, error)
+}
+
+type TokenStorage interface {
+	SaveRefreshToken(ctx context.Context, token models.RefreshToken) error
+	RefreshToken(ctx context.Context, tokenHash []byte) (models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, usedId int64, next models.RefreshToken, now time.Time) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyId string, now time.Time) error
+	DeleteExpiredRefreshTokens(ctx context.Context, now time.Time) (int64, error)
+
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time, now time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	DeleteExpiredRevokedTokens(ctx context.Context, now time.Time) (int64, error)
+}
+
+type CodeStorage interface {
+	SaveCode(ctx context.Context, code models.OneTimeCode) error
+	Code(ctx context.Context, purpose string, codeHash []byte) (models.OneTimeCode, error)
+	UseCode(ctx context.Context, codeId int64, now time.Time) error
+	VerifyEmail(ctx context.Context, codeId int64, userId int64, now time.Time) error
+	ResetPassword(ctx context.Context, codeId int64, userId int64, passHash []byte, now time.Time) error
+	DeleteExpiredCodes(ctx context.Context, now time.Time) (int64, error)
+}
+
+// PasswordHasher хэширует новые пароли настроенным алгоритмом и проверяет хэши всех поддерживаемых.
+// rehash - хэш сделан другим алгоритмом или с другой стоимостью. Хэширование ограничено пулом:
+// password.ErrPoolFull - очередь заполнена
+type PasswordHasher interface {
+	Hash(ctx context.Context, password string) ([]byte, error)
+	Verify(ctx context.Context, password string, hash []byte) (rehash bool, err error)
+}
+
+// Mailer доставляет письма пользователям: SMTP в проде, outbox в разработке и тестах
+type Mailer interface {
+	Send(ctx context.Context, msg mail.Message) error
+}
+
+// TokenFormat выпускает и проверяет access токены одного формата: JWT или PASETO
+type TokenFormat interface {
+	NewToken(claims tokens.Claims, app models.App, key models.AppKey) (string, error)
+	Parse(token string, app models.App, keys []models.AppKey, now time.Time) (tokens.Claims, error)
+	UnverifiedAppID(token string) (int, error)
+}
+
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrInvalidUserId      = errors.New("invalid user id")
 	ErrUserExists         = errors.New("user exists")
 	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrInvalidClient      = errors.New("invalid client credentials")
+	ErrKeyPolicy          = errors.New("active key does not match app signing algorithm")
+	ErrEmailNotVerified   = errors.New("email is not verified")
+	ErrInvalidCode        = errors.New("invalid or expired code")
+	ErrTooManyAttempts    = errors.New("too many login attempts")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrMFAEnabled         = errors.New("mfa already enabled")
+	ErrMFANotEnabled      = errors.New("mfa is not enabled")
+	ErrInvalidPasskey     = errors.New("invalid passkey")
+	ErrPasskeyExists      = errors.New("passkey already registered")
+	ErrWeakPassword       = errors.New("password does not meet policy")
+	ErrInvalidImport      = errors.New("invalid import data")
+	ErrBusy               = errors.New("too many concurrent password operations")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrAppNotFound        = errors.New("app not found")
+	ErrRoleExists         = errors.New("role already exists")
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrRoleNotGranted     = errors.New("role is not granted")
 )
 
+// Storages хранилища, с которыми работает сервис. В приложении все поля реализует одно хранилище
+type Storages struct {
+	UserSaver      UserSaver
+	UserProvider   UserProvider
+	AppProvider    AppProvider
+	KeyProvider    KeyProvider
+	TokenStorage   TokenStorage
+	CodeStorage    CodeStorage
+	AttemptStorage AttemptStorage
+	MFAStorage     MFAStorage
+	PasskeyStorage PasskeyStorage
+	SessionStorage SessionStorage
+	RoleStorage    RoleStorage
+}
+
 func New(
 	log *slog.Logger,
-	userSaver UserSaver,
-	userProvider UserProvider,
-	appProvider AppProvider,
-	tokenTTL time.Duration) *Auth {
-	return &Auth{log: log, userSaver: userSaver, userProvider: userProvider, appProvider: appProvider, tokenTTL: tokenTTL}
+	storages Storages,
+	mailer Mailer,
+	relyingParty *passkey.RelyingParty,
+	passwordHasher PasswordHasher,
+	importer UserImporter,
+	hashParams models.HashParams,
+	tokenTTL time.Duration,
+	refreshTTL time.Duration,
+	verificationTTL time.Duration,
+	resetTTL time.Duration,
+	throttle ThrottlePolicy,
+	passwordPolicy password.Policy,
+	issuer string,
+	legacyClaims bool) *Auth {
+	return &Auth{
+		log:             log,
+		userSaver:       storages.UserSaver,
+		userProvider:    storages.UserProvider,
+		appProvider:     storages.AppProvider,
+		keyProvider:     storages.KeyProvider,
+		tokenStorage:    storages.TokenStorage,
+		codeStorage:     storages.CodeStorage,
+		attemptStorage:  storages.AttemptStorage,
+		mfaStorage:      storages.MFAStorage,
+		passkeyStorage:  storages.PasskeyStorage,
+		sessionStorage:  storages.SessionStorage,
+		roleStorage:     storages.RoleStorage,
+		mailer:          mailer,
+		relyingParty:    relyingParty,
+		passwordHasher:  passwordHasher,
+		importer:        importer,
+		hashParams:      hashParams,
+		tokenTTL:        tokenTTL,
+		refreshTTL:      refreshTTL,
+		verificationTTL: verificationTTL,
+		resetTTL:        resetTTL,
+		throttle:        throttle,
+		passwordPolicy:  passwordPolicy,
+		issuer:          issuer,
+		legacyClaims:    legacyClaims,
+	}
 }
 
 func (auth *Auth) Login(
 	ctx context.Context,
 	email, password string,
-	appID int) (string, error) {
+	appID int,
+	clientIP, userAgent string) (models.TokenPair, error) {
 	const op = "auth.Login"
 
 	log := auth.log.With(
 		slog.String("op", op),
 		slog.String("username", email),
+		slog.String("client_ip", clientIP),
 	)
 
 	log.Info("attempting to login user")
+
+	subjects := loginSubjects(email, clientIP)
+
+	//заблокированный вход не проверяет пароль, поэтому перебор не продвигается даже с верным паролем
+	if err := auth.checkThrottle(ctx, log, subjects); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
 	user, err := auth.userProvider.User(ctx, email)
 
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
-			auth.log.Warn("user not found", err)
-			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+			auth.log.Warn("user not found", sl.Err(err))
+			auth.recordLoginFailure(ctx, log, subjects)
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 		}
 
 		if errors.Is(err, storage.ErrAppNotFound) {
-			auth.log.Warn("app not found", err)
-			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+			auth.log.Warn("app not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 		}
 
-		auth.log.Warn("failed to get user", err)
-		return "", fmt.Errorf("%s: %w", op, err)
+		auth.log.Warn("failed to get user", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
-		auth.log.Info("Invalid credentials", err)
-		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	rehash, err := auth.passwordHasher.Verify(ctx, password, user.PassHash)
+
+	//перегрузка и отмена запроса ничего не говорят о пароле и не считаются неудачной попыткой
+	if hashingUnavailable(err) {
+		log.Warn("failed to verify password", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, hashingError(err))
+	}
+
+	if err != nil {
+		auth.log.Info("Invalid credentials", sl.Err(err))
+		auth.recordLoginFailure(ctx, log, subjects)
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	//пароль известен только сейчас: устаревший хэш пересчитывается настроенным алгоритмом
+	if rehash {
+		auth.rehashPassword(ctx, log, user, password)
 	}
 
 	app, err := auth.appProvider.App(ctx, appID)
 
 	if err != nil {
-		auth.log.Info("Error getting app id", err)
-		return "", fmt.Errorf("%s: %w", op, err)
+		auth.log.Info("Error getting app id", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("User logged in successfully")
+	if app.RequireVerifiedEmail && !user.EmailVerified {
+		log.Info("email is not verified")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrEmailNotVerified)
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := auth.mfaChallenge(ctx, user, app)
+
+		if err != nil {
+			log.Error("Failed to create mfa challenge", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
 
-	token, err := jwt.NewToken(user, app, auth.tokenTTL)
+		log.Info("Second factor required")
+
+		return challenge, nil
+	}
+
+	session, err := newSession(clientIP, userAgent)
+
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := auth.issueTokens(ctx, user, app, session, 0, []string{models.AMRPassword})
 
 	if err != nil {
-		auth.log.Info("Failed to generate token", err)
-		return "", fmt.Errorf("%s: %w", op, err)
+		log.Error("Failed to issue tokens", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return token, nil
+	//удачный вход прощает неудачи аккаунта, но не IP: иначе свой аккаунт позволил бы перебирать чужие.
+	//Счетчик сбрасывается только после выдачи токенов: верный пароль без второго фактора его не прощает
+	if _, err := auth.attemptStorage.ResetLoginAttempts(ctx, models.LoginAttemptEmail, subjects[models.LoginAttemptEmail]); err != nil {
+		log.Error("failed to reset login attempts", sl.Err(err))
+	}
+
+	log.Info("User logged in successfully")
+
+	return tokens, nil
 }
 
 func (auth *Auth) RegisterNewUser(
@@ -117,54 +317,136 @@ func (auth *Auth) RegisterNewUser(
 
 	log.Info("Register new user")
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err := auth.checkPassword(password, email); err != nil {
+		log.Info("password rejected by policy", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := auth.passwordHasher.Hash(ctx, password)
 
 	if err != nil {
-		log.Error("failed to generate password hash", err)
-		return 0, fmt.Errorf("%s: %w", op, err)
+		log.Error("failed to generate password hash", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, hashingError(err))
 	}
 
 	id, err := auth.userSaver.SaveUser(ctx, email, passHash)
 
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
-			auth.log.Warn("user already exists", err)
+			auth.log.Warn("user already exists", sl.Err(err))
 			return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
 		}
-		log.Error("failed to save user", err)
+		log.Error("failed to save user", sl.Err(err))
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
 	log.Info("User registered")
 
+	//письмо не должно ломать регистрацию: код можно запросить повторно через SendVerification
+	auth.sendInBackground(ctx, log, func(ctx context.Context) error {
+		return auth.sendVerification(ctx, models.User{ID: id, Email: email})
+	})
+
 	return id, nil
 
 }
 
-func (auth *Auth) IsAdmin(
-	ctx context.Context,
-	userId int64) (bool, error) {
-
+// IsAdmin есть ли у пользователя userId глобальная роль администратора. Себя может проверить любой
+// пользователь, других - только администратор, чтобы по ответам нельзя было перебирать id
+func (auth *Auth) IsAdmin(ctx context.Context, token string, userId int64) (bool, error) {
 	const op = "auth.IsAdmin"
 
 	log := auth.log.With(
 		slog.String("op", op),
-		slog.Int64("userId", userId),
+		slog.Int64("user_id", userId),
 	)
 
-	log.Info("Checking if user is admin")
+	_, caller, err := auth.userByToken(ctx, token)
+
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			log.Warn("admin check denied", sl.Err(err))
+		} else {
+			log.Error("failed to verify token", sl.Err(err))
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if caller.ID != userId && !caller.IsAdmin {
+		log.Warn("admin check denied", slog.Int64("caller_id", caller.ID))
+		return false, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
 
-	isAdmin, err := auth.IsAdmin(ctx, userId)
+	isAdmin, err := auth.userProvider.IsAdmin(ctx, userId)
 
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
-			auth.log.Warn("user not found", err)
-			return false, fmt.Errorf("%s: %w", op, ErrInvalidUserId)
+			log.Info("user not found")
+			return false, fmt.Errorf("%s: %w", op, ErrUserNotFound)
 		}
+		log.Error("failed to check admin", sl.Err(err))
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("Checking if user is admin", slog.Bool("is_admin", isAdmin))
+	log.Info("checked if user is admin", slog.Bool("is_admin", isAdmin))
 
 	return isAdmin, nil
 }
+
+// signingKey активный ключ приложения. HS256 приложения без связки ключей подписывают общим секретом.
+// Ключ с алгоритмом, отличным от разрешенного приложению, не используется
+func (auth *Auth) signingKey(ctx context.Context, app models.App) (models.AppKey, error) {
+	key, err := auth.keyProvider.ActiveAppKey(ctx, app.ID)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrKeyNotFound) && app.SignAlg == jwt.AlgHS256 {
+			return models.AppKey{}, nil
+		}
+		return models.AppKey{}, err
+	}
+
+	if key.Alg != app.SignAlg {
+		return models.AppKey{}, fmt.Errorf("%w: app %d allows %s, key %s is %s", ErrKeyPolicy, app.ID, app.SignAlg, key.Kid, key.Alg)
+	}
+
+	return key, nil
+}
+
+// JWKS собирает опубликованные публичные ключи всех приложений, включая следующие
+// и выведенные из оборота в пределах grace периода. Ключи v4.public публикуются с alg v4.public
+func (auth *Auth) JWKS(ctx context.Context) (jwks.Set, error) {
+	const op = "auth.JWKS"
+
+	log := auth.log.With(slog.String("op", op))
+
+	keys, err := auth.keyProvider.PublishedAppKeys(ctx, time.Now())
+
+	if err != nil {
+		log.Error("failed to get app keys", sl.Err(err))
+		return jwks.Set{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	set := jwks.Set{Keys: []jwks.JWK{}}
+
+	for _, key := range keys {
+		var jwk jwks.JWK
+
+		switch {
+		case jwt.IsAsymmetric(key.Alg):
+			jwk, err = jwt.PublicJWK(key)
+		case key.Alg == paseto.AlgV4Public:
+			jwk, err = paseto.PublicJWK(key)
+		default:
+			continue
+		}
+
+		if err != nil {
+			log.Warn("skipping app key", slog.Int64("key_id", key.ID), sl.Err(err))
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/userimport"
+)
+
+// UserImporter переносит пользователей других систем вместе с хэшами паролей
+type UserImporter interface {
+	Import(ctx context.Context, r io.Reader, format string) (models.ImportResult, error)
+}
+
+// ImportUsers импортирует пользователей из data в формате csv или jsonl. Доступно только администратору,
+// access токен которого передан в token
+func (auth *Auth) ImportUsers(ctx context.Context, token, format string, data []byte) (models.ImportResult, error) {
+	const op = "auth.ImportUsers"
+
+	log := auth.log.With(slog.String("op", op))
+
+	if err := auth.requireAdmin(ctx, token); err != nil {
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrPermissionDenied) {
+			log.Warn("import denied", sl.Err(err))
+		} else {
+			log.Error("failed to authorize import", sl.Err(err))
+		}
+		return models.ImportResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result, err := auth.importer.Import(ctx, bytes.NewReader(data), format)
+
+	if err != nil {
+		if errors.Is(err, userimport.ErrInvalidInput) {
+			return models.ImportResult{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidImport, err)
+		}
+		return models.ImportResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
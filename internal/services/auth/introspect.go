@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/opaque"
+	"sso/internal/storage"
+	"time"
+)
+
+// Introspect сообщает, активен ли токен, и кому он выдан (RFC 7662). Вызывающий аутентифицируется
+// как приложение по app_id и secret. Понимает как JWT access токены, так и непрозрачные refresh токены;
+// tokenTypeHint только подсказывает, с чего начинать. Невалидный токен - не ошибка, а Active: false.
+// Токен другого приложения тоже неактивен: иначе любое приложение узнавало бы email чужих пользователей
+func (auth *Auth) Introspect(
+	ctx context.Context,
+	clientID int,
+	clientSecret string,
+	token string,
+	tokenTypeHint string) (models.Introspection, error) {
+	const op = "auth.Introspect"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int("client_id", clientID),
+	)
+
+	if err := auth.authenticateClient(ctx, clientID, clientSecret); err != nil {
+		log.Warn("introspection client authentication failed", sl.Err(err))
+		return models.Introspection{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	lookups := []func(context.Context, string) (models.Introspection, error){
+		auth.introspectAccessToken,
+		auth.introspectRefreshToken,
+	}
+
+	if tokenTypeHint == models.TokenTypeRefresh {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		res, err := lookup(ctx, token)
+
+		if err != nil {
+			log.Error("failed to introspect token", sl.Err(err))
+			return models.Introspection{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if res.Active && res.AppID != clientID {
+			log.Warn("introspection of another app's token", slog.Int("token_app_id", res.AppID))
+			return models.Introspection{}, nil
+		}
+
+		if res.Active {
+			return res, nil
+		}
+	}
+
+	return models.Introspection{}, nil
+}
+
+func (auth *Auth) authenticateClient(ctx context.Context, clientID int, clientSecret string) error {
+	app, err := auth.appProvider.App(ctx, clientID)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return ErrInvalidClient
+		}
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(app.Secret), []byte(clientSecret)) != 1 {
+		return ErrInvalidClient
+	}
+
+	return nil
+}
+
+// introspectAccessToken проверяет JWT так же, как остальные операции сервера.
+// Ошибка возвращается только при сбоях хранилища
+func (auth *Auth) introspectAccessToken(ctx context.Context, token string) (models.Introspection, error) {
+	access, err := auth.verifyAccessToken(ctx, token)
+
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return models.Introspection{}, nil
+		}
+		return models.Introspection{}, err
+	}
+
+	return models.Introspection{
+		Active:    true,
+		TokenType: models.TokenTypeAccess,
+		UserID:    access.UserID,
+		Email:     access.Email,
+		AppID:     access.AppID,
+		ExpiresAt: access.ExpiresAt,
+		IssuedAt:  access.IssuedAt,
+		Scopes:    access.Scopes,
+	}, nil
+}
+
+// introspectRefreshToken ищет непрозрачный refresh токен по хэшу
+func (auth *Auth) introspectRefreshToken(ctx context.Context, token string) (models.Introspection, error) {
+	stored, err := auth.tokenStorage.RefreshToken(ctx, opaque.Hash(token))
+
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return models.Introspection{}, nil
+		}
+		return models.Introspection{}, err
+	}
+
+	if !stored.RevokedAt.IsZero() || !stored.UsedAt.IsZero() || !time.Now().Before(stored.ExpiresAt) {
+		return models.Introspection{}, nil
+	}
+
+	user, err := auth.userProvider.UserByID(ctx, stored.UserID)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.Introspection{}, nil
+		}
+		return models.Introspection{}, err
+	}
+
+	return models.Introspection{
+		Active:    true,
+		TokenType: models.TokenTypeRefresh,
+		UserID:    user.ID,
+		Email:     user.Email,
+		AppID:     stored.AppID,
+		ExpiresAt: stored.ExpiresAt,
+		IssuedAt:  stored.CreatedAt,
+	}, nil
+}
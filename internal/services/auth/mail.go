@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"sso/internal/lib/logger/sl"
+	"time"
+)
+
+// mailTimeout время на подготовку и отправку одного письма в фоне
+const mailTimeout = 30 * time.Second
+
+// sendInBackground выполняет send (создание кода и отправку письма) вне запроса: ответ не ждет почтовый
+// сервер, и время ответа не выдает, есть ли такой адрес. Ошибки только логируются
+func (auth *Auth) sendInBackground(ctx context.Context, log *slog.Logger, send func(ctx context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+		defer cancel()
+
+		if err := send(ctx); err != nil {
+			log.Error("failed to send mail", sl.Err(err))
+			return
+		}
+
+		log.Info("mail sent")
+	}()
+}
@@ -0,0 +1,343 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/opaque"
+	"sso/internal/lib/totp"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+const (
+	// mfaChallengeTTL время на ввод кода второго фактора после пароля
+	mfaChallengeTTL = 5 * time.Minute
+
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+type MFAStorage interface {
+	SetTOTPSecret(ctx context.Context, userId int64, secret []byte) error
+	EnableTOTP(ctx context.Context, userId int64, step int64, codes []models.RecoveryCode) error
+	DisableTOTP(ctx context.Context, userId int64) error
+	UseTOTPStep(ctx context.Context, userId int64, step int64) error
+	UseRecoveryCode(ctx context.Context, userId int64, codeHash []byte, now time.Time) error
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EnrollTOTP начинает подключение второго фактора: генерирует секрет и otpauth ссылку для QR кода.
+// Второй фактор включается только после ConfirmTOTP; повторный EnrollTOTP до этого заменяет секрет
+func (auth *Auth) EnrollTOTP(ctx context.Context, token string) (secret string, uri string, err error) {
+	const op = "auth.EnrollTOTP"
+
+	log := auth.log.With(slog.String("op", op))
+
+	_, user, err := auth.userByToken(ctx, token)
+
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", user.ID))
+
+	if user.TOTPEnabled {
+		return "", "", fmt.Errorf("%s: %w", op, ErrMFAEnabled)
+	}
+
+	raw, err := totp.NewSecret()
+
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.mfaStorage.SetTOTPSecret(ctx, user.ID, raw); err != nil {
+		if errors.Is(err, storage.ErrMFAEnabled) {
+			return "", "", fmt.Errorf("%s: %w", op, ErrMFAEnabled)
+		}
+		log.Error("failed to save totp secret", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp enrollment started")
+
+	return totp.EncodeSecret(raw), totp.URI(auth.issuer, user.Email, raw), nil
+}
+
+// ConfirmTOTP включает второй фактор по первому коду из приложения-аутентификатора
+// и возвращает коды восстановления. Коды показываются один раз, хранятся только их хэши.
+// Неверные коды ограничиваются так же, как попытки входа
+func (auth *Auth) ConfirmTOTP(ctx context.Context, token, code string) ([]string, error) {
+	const op = "auth.ConfirmTOTP"
+
+	log := auth.log.With(slog.String("op", op))
+
+	_, user, err := auth.userByToken(ctx, token)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", user.ID))
+
+	if user.TOTPEnabled {
+		return nil, fmt.Errorf("%s: %w", op, ErrMFAEnabled)
+	}
+
+	if user.TOTPSecret == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrMFANotEnabled)
+	}
+
+	subjects := loginSubjects(user.Email, "")
+
+	if err := auth.checkThrottle(ctx, log, subjects); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+
+	if !ok {
+		log.Info("invalid totp code")
+		auth.recordLoginFailure(ctx, log, subjects)
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+
+	codes, records, err := newRecoveryCodes()
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.mfaStorage.EnableTOTP(ctx, user.ID, step, records); err != nil {
+		if errors.Is(err, storage.ErrMFAEnabled) {
+			return nil, fmt.Errorf("%s: %w", op, ErrMFAEnabled)
+		}
+		log.Error("failed to enable totp", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp enabled")
+
+	return codes, nil
+}
+
+// DisableTOTP выключает второй фактор. Нужен текущий код TOTP или код восстановления,
+// чтобы украденного access токена было недостаточно. Неверные коды считаются неудачными
+// попытками входа аккаунта, иначе с украденным токеном код можно было бы перебрать
+func (auth *Auth) DisableTOTP(ctx context.Context, token, code string) error {
+	const op = "auth.DisableTOTP"
+
+	log := auth.log.With(slog.String("op", op))
+
+	_, user, err := auth.userByToken(ctx, token)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", user.ID))
+
+	subjects := loginSubjects(user.Email, "")
+
+	if err := auth.checkThrottle(ctx, log, subjects); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := auth.verifySecondFactor(ctx, user, code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			log.Info("invalid second factor code")
+			auth.recordLoginFailure(ctx, log, subjects)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.mfaStorage.DisableTOTP(ctx, user.ID); err != nil {
+		log.Error("failed to disable totp", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp disabled")
+
+	return nil
+}
+
+// VerifyMFA второй шаг входа: по challenge из Login и коду TOTP или коду восстановления выпускает токены.
+// Challenge одноразовый и гасится до проверки кода: после неверного кода нужно снова войти по паролю,
+// поэтому перебор кодов упирается в ограничение попыток входа
+func (auth *Auth) VerifyMFA(ctx context.Context, challenge, code, clientIP, userAgent string) (models.TokenPair, error) {
+	const op = "auth.VerifyMFA"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("client_ip", clientIP),
+	)
+
+	stored, err := auth.useCode(ctx, models.CodePurposeMFAChallenge, challenge)
+
+	if err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			log.Info("invalid mfa challenge")
+		}
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", stored.UserID))
+
+	if err := auth.codeStorage.UseCode(ctx, stored.ID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrTokenUsed) {
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+		log.Error("failed to use mfa challenge", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := auth.userProvider.UserByID(ctx, stored.UserID)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+		log.Error("failed to get user", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subjects := loginSubjects(user.Email, clientIP)
+
+	if err := auth.checkThrottle(ctx, log, subjects); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	amr, err := auth.verifySecondFactor(ctx, user, code)
+
+	if err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			log.Info("invalid second factor code")
+			auth.recordLoginFailure(ctx, log, subjects)
+		}
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := auth.appProvider.App(ctx, stored.AppID)
+
+	if err != nil {
+		log.Error("failed to get app", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := newSession(clientIP, userAgent)
+
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := auth.issueTokens(ctx, user, app, session, 0, amr)
+
+	if err != nil {
+		log.Error("failed to issue tokens", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := auth.attemptStorage.ResetLoginAttempts(ctx, models.LoginAttemptEmail, subjects[models.LoginAttemptEmail]); err != nil {
+		log.Error("failed to reset login attempts", sl.Err(err))
+	}
+
+	log.Info("User logged in with second factor", slog.Any("amr", amr))
+
+	return tokens, nil
+}
+
+// mfaChallenge выдает challenge вместо токенов пользователю с включенным вторым фактором
+func (auth *Auth) mfaChallenge(ctx context.Context, user models.User, app models.App) (models.TokenPair, error) {
+	challenge, err := auth.newCode(ctx, user, models.CodePurposeMFAChallenge, app.ID, mfaChallengeTTL)
+
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return models.TokenPair{MFAChallenge: challenge}, nil
+}
+
+// verifySecondFactor проверяет код TOTP или код восстановления и возвращает amr сессии
+func (auth *Auth) verifySecondFactor(ctx context.Context, user models.User, code string) ([]string, error) {
+	if !user.TOTPEnabled {
+		return nil, ErrMFANotEnabled
+	}
+
+	if len(code) != totp.Digits {
+		err := auth.mfaStorage.UseRecoveryCode(ctx, user.ID, opaque.Hash(normalizeRecoveryCode(code)), time.Now())
+
+		if err != nil {
+			if errors.Is(err, storage.ErrTokenNotFound) {
+				return nil, ErrInvalidCode
+			}
+			return nil, err
+		}
+
+		return []string{models.AMRPassword, models.AMRMFA}, nil
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	if err := auth.mfaStorage.UseTOTPStep(ctx, user.ID, step); err != nil {
+		if errors.Is(err, storage.ErrTokenUsed) {
+			return nil, ErrInvalidCode
+		}
+		return nil, err
+	}
+
+	return []string{models.AMRPassword, models.AMROTP, models.AMRMFA}, nil
+}
+
+// newRecoveryCodes коды восстановления вида abcd-efgh-ijkl-mnop и их записи для хранилища
+func newRecoveryCodes() ([]string, []models.RecoveryCode, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	now := time.Now()
+
+	for range recoveryCodeCount {
+		raw := make([]byte, recoveryCodeLength)
+
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+
+		encoded := strings.ToLower(recoveryEncoding.EncodeToString(raw))
+
+		var groups []string
+		for i := 0; i < len(encoded); i += 4 {
+			groups = append(groups, encoded[i:min(i+4, len(encoded))])
+		}
+
+		code := strings.Join(groups, "-")
+
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{
+			CodeHash:  opaque.Hash(normalizeRecoveryCode(code)),
+			CreatedAt: now,
+		})
+	}
+
+	return codes, records, nil
+}
+
+// normalizeRecoveryCode убирает дефисы, пробелы и регистр, с которыми пользователь может ввести код
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+}
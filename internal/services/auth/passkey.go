@@ -0,0 +1,377 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/opaque"
+	"sso/internal/lib/passkey"
+	"sso/internal/storage"
+	"time"
+)
+
+type PasskeyStorage interface {
+	SavePasskey(ctx context.Context, key models.Passkey) (int64, error)
+	Passkeys(ctx context.Context, userId int64) ([]models.Passkey, error)
+	UsePasskey(ctx context.Context, id int64, signCount uint32, backupState bool, now time.Time) error
+	SavePasskeyCeremony(ctx context.Context, ceremony models.PasskeyCeremony) error
+	UsePasskeyCeremony(ctx context.Context, purpose string, sessionHash []byte) (models.PasskeyCeremony, error)
+	DeleteExpiredPasskeyCeremonies(ctx context.Context, now time.Time) (int64, error)
+}
+
+// BeginPasskeyRegistration начинает регистрацию passkey для пользователя access токена. Возвращает
+// идентификатор церемонии и параметры для navigator.credentials.create() в JSON
+func (auth *Auth) BeginPasskeyRegistration(ctx context.Context, token string) (sessionID string, options []byte, err error) {
+	const op = "auth.BeginPasskeyRegistration"
+
+	log := auth.log.With(slog.String("op", op))
+
+	_, user, err := auth.userByToken(ctx, token)
+
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", user.ID))
+
+	owner, err := auth.passkeyUser(ctx, user)
+
+	if err != nil {
+		log.Error("failed to get passkeys", sl.Err(err))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ceremony, err := auth.relyingParty.BeginRegistration(owner)
+
+	if err != nil {
+		log.Error("failed to begin registration", sl.Err(err))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessionID, err = auth.saveCeremony(ctx, models.PasskeyCeremonyRegistration, user.ID, 0, ceremony)
+
+	if err != nil {
+		log.Error("failed to save ceremony", sl.Err(err))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessionID, ceremony.Options, nil
+}
+
+// FinishPasskeyRegistration проверяет ответ аутентификатора и сохраняет ключ. Церемония одноразовая
+// и принадлежит пользователю, который ее начал
+func (auth *Auth) FinishPasskeyRegistration(ctx context.Context, token, sessionID string, credential []byte) error {
+	const op = "auth.FinishPasskeyRegistration"
+
+	log := auth.log.With(slog.String("op", op))
+
+	_, user, err := auth.userByToken(ctx, token)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", user.ID))
+
+	stored, err := auth.useCeremony(ctx, models.PasskeyCeremonyRegistration, sessionID)
+
+	if err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			log.Info("invalid passkey ceremony")
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if stored.UserID != user.ID {
+		log.Warn("passkey ceremony of another user", slog.Int64("owner_id", stored.UserID))
+		return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+
+	owner, err := auth.passkeyUser(ctx, user)
+
+	if err != nil {
+		log.Error("failed to get passkeys", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := auth.relyingParty.FinishRegistration(owner, stored.SessionData, credential)
+
+	if err != nil {
+		if errors.Is(err, passkey.ErrInvalidResponse) {
+			log.Info("invalid passkey registration", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+		}
+		log.Error("failed to finish registration", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	key.CreatedAt = time.Now()
+
+	if _, err := auth.passkeyStorage.SavePasskey(ctx, key); err != nil {
+		if errors.Is(err, storage.ErrPasskeyExists) {
+			return fmt.Errorf("%s: %w", op, ErrPasskeyExists)
+		}
+		log.Error("failed to save passkey", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("passkey registered", slog.String("attestation", key.AttestationType))
+
+	return nil
+}
+
+// BeginPasskeyLogin начинает вход по passkey в приложение appID. С email аутентификатор предлагает только
+// ключи этого пользователя; без email, для неизвестного email или пользователя без ключей - любые ключи домена,
+// чтобы по ответу нельзя было узнать, зарегистрирован ли адрес
+func (auth *Auth) BeginPasskeyLogin(ctx context.Context, email string, appID int) (sessionID string, options []byte, err error) {
+	const op = "auth.BeginPasskeyLogin"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("username", email),
+	)
+
+	//приложение проверяется до поиска пользователя, чтобы ответ не зависел от того, есть ли такой адрес
+	if _, err := auth.appProvider.App(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Info("unknown app", slog.Int("app_id", appID))
+			return "", nil, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+		log.Error("failed to get app", sl.Err(err))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var owner *passkey.User
+
+	if email != "" {
+		user, err := auth.userProvider.User(ctx, email)
+
+		if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("failed to get user", sl.Err(err))
+			return "", nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err == nil {
+			u, err := auth.passkeyUser(ctx, user)
+
+			if err != nil {
+				log.Error("failed to get passkeys", sl.Err(err))
+				return "", nil, fmt.Errorf("%s: %w", op, err)
+			}
+
+			if len(u.Passkeys) > 0 {
+				owner = &u
+			}
+		}
+	}
+
+	ceremony, err := auth.relyingParty.BeginLogin(owner)
+
+	if err != nil {
+		log.Error("failed to begin login", sl.Err(err))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var userID int64
+	if owner != nil {
+		userID = owner.ID
+	}
+
+	sessionID, err = auth.saveCeremony(ctx, models.PasskeyCeremonyLogin, userID, appID, ceremony)
+
+	if err != nil {
+		log.Error("failed to save ceremony", sl.Err(err))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessionID, ceremony.Options, nil
+}
+
+// FinishPasskeyLogin проверяет подпись аутентификатора и выпускает токены так же, как Login.
+// Passkey с проверкой пользователя - это и владение ключом, и PIN или биометрия, поэтому второй
+// фактор не запрашивается. Неудачи учитываются в ограничении попыток входа по IP, а после определения
+// пользователя и по email
+func (auth *Auth) FinishPasskeyLogin(ctx context.Context, sessionID string, credential []byte, clientIP, userAgent string) (models.TokenPair, error) {
+	const op = "auth.FinishPasskeyLogin"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("client_ip", clientIP),
+	)
+
+	subjects := map[string]string{}
+
+	if clientIP != "" {
+		subjects[models.LoginAttemptIP] = clientIP
+	}
+
+	if err := auth.checkThrottle(ctx, log, subjects); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stored, err := auth.useCeremony(ctx, models.PasskeyCeremonyLogin, sessionID)
+
+	if err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			log.Info("invalid passkey ceremony")
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var user models.User
+
+	key, err := auth.relyingParty.FinishLogin(stored.SessionData, credential, func(userID int64) (passkey.User, error) {
+		var err error
+
+		user, err = auth.userProvider.UserByID(ctx, userID)
+
+		if err != nil {
+			if errors.Is(err, storage.ErrUserNotFound) {
+				return passkey.User{}, ErrInvalidCredentials
+			}
+			return passkey.User{}, err
+		}
+
+		subjects = loginSubjects(user.Email, clientIP)
+
+		if err := auth.checkThrottle(ctx, log, subjects); err != nil {
+			return passkey.User{}, err
+		}
+
+		return auth.passkeyUser(ctx, user)
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTooManyAttempts):
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		case errors.Is(err, passkey.ErrCloned):
+			log.Warn("passkey sign count did not increase, authenticator may be cloned", slog.Int64("user_id", user.ID))
+		case errors.Is(err, passkey.ErrInvalidResponse), errors.Is(err, ErrInvalidCredentials):
+			log.Info("invalid passkey assertion", sl.Err(err))
+		default:
+			log.Error("failed to finish login", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		auth.recordLoginFailure(ctx, log, subjects)
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	log = log.With(slog.Int64("user_id", user.ID))
+
+	if err := auth.passkeyStorage.UsePasskey(ctx, key.ID, key.SignCount, key.BackupState, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrTokenUsed) {
+			log.Warn("passkey assertion replayed")
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		log.Error("failed to update passkey", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := auth.appProvider.App(ctx, stored.AppID)
+
+	if err != nil {
+		log.Info("Error getting app id", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if app.RequireVerifiedEmail && !user.EmailVerified {
+		log.Info("email is not verified")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrEmailNotVerified)
+	}
+
+	session, err := newSession(clientIP, userAgent)
+
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := auth.issueTokens(ctx, user, app, session, 0, []string{models.AMRHardwareKey, models.AMRMFA})
+
+	if err != nil {
+		log.Error("Failed to issue tokens", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	//как и в Login, счетчик аккаунта сбрасывается только после выдачи токенов: вход, отклоненный
+	//приложением, неудачи не прощает
+	if _, err := auth.attemptStorage.ResetLoginAttempts(ctx, models.LoginAttemptEmail, subjects[models.LoginAttemptEmail]); err != nil {
+		log.Error("failed to reset login attempts", sl.Err(err))
+	}
+
+	log.Info("User logged in with passkey")
+
+	return tokens, nil
+}
+
+// PrunePasskeyCeremonies удаляет брошенные церемонии с истекшим сроком
+func (auth *Auth) PrunePasskeyCeremonies(ctx context.Context) (int64, error) {
+	const op = "auth.PrunePasskeyCeremonies"
+
+	deleted, err := auth.passkeyStorage.DeleteExpiredPasskeyCeremonies(ctx, time.Now())
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+// passkeyUser пользователь с его зарегистрированными ключами
+func (auth *Auth) passkeyUser(ctx context.Context, user models.User) (passkey.User, error) {
+	keys, err := auth.passkeyStorage.Passkeys(ctx, user.ID)
+
+	if err != nil {
+		return passkey.User{}, err
+	}
+
+	return passkey.User{ID: user.ID, Email: user.Email, Passkeys: keys}, nil
+}
+
+// saveCeremony сохраняет состояние первого шага и возвращает идентификатор церемонии; в хранилище только его хэш
+func (auth *Auth) saveCeremony(ctx context.Context, purpose string, userID int64, appID int, ceremony passkey.Ceremony) (string, error) {
+	sessionID, hash, err := opaque.New()
+
+	if err != nil {
+		return "", err
+	}
+
+	err = auth.passkeyStorage.SavePasskeyCeremony(ctx, models.PasskeyCeremony{
+		SessionHash: hash,
+		Purpose:     purpose,
+		UserID:      userID,
+		AppID:       appID,
+		SessionData: ceremony.Session,
+		CreatedAt:   time.Now(),
+		ExpiresAt:   ceremony.ExpiresAt,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return sessionID, nil
+}
+
+// useCeremony гасит церемонию; неизвестная, уже использованная и истекшая церемония - ErrInvalidCode
+func (auth *Auth) useCeremony(ctx context.Context, purpose, sessionID string) (models.PasskeyCeremony, error) {
+	stored, err := auth.passkeyStorage.UsePasskeyCeremony(ctx, purpose, opaque.Hash(sessionID))
+
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return models.PasskeyCeremony{}, ErrInvalidCode
+		}
+		return models.PasskeyCeremony{}, err
+	}
+
+	if !time.Now().Before(stored.ExpiresAt) {
+		return models.PasskeyCeremony{}, ErrInvalidCode
+	}
+
+	return stored, nil
+}
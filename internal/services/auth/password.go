@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/mail"
+	"sso/internal/lib/password"
+	"sso/internal/storage"
+	"time"
+)
+
+// RequestPasswordReset отправляет код сброса пароля. Для неизвестного адреса письмо не отправляется,
+// но ответ тот же, чтобы по нему нельзя было перебирать адреса: код создается и отправляется в фоне
+func (auth *Auth) RequestPasswordReset(ctx context.Context, email string) error {
+	const op = "auth.RequestPasswordReset"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	user, err := auth.userProvider.User(ctx, email)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("password reset requested for unknown email")
+			return nil
+		}
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	auth.sendInBackground(ctx, log, func(ctx context.Context) error {
+		code, err := auth.newCode(ctx, user, models.CodePurposePasswordReset, 0, auth.resetTTL)
+
+		if err != nil {
+			return fmt.Errorf("save reset code: %w", err)
+		}
+
+		return auth.mailer.Send(ctx, mail.Message{
+			To:      user.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Use this code to set a new password:\r\n\r\ncode: %s\r\n\r\n"+
+				"The code expires in %s. If you didn't request a reset, ignore this message; "+
+				"your password stays the same.\r\n", code, auth.resetTTL),
+		})
+	})
+
+	return nil
+}
+
+// ResetPassword погашает код сброса и задает новый пароль. Все сессии пользователя завершаются:
+// refresh токены отзываются, а выпущенные раньше access токены перестают проходить проверку
+func (auth *Auth) ResetPassword(ctx context.Context, code, newPassword string) error {
+	const op = "auth.ResetPassword"
+
+	log := auth.log.With(slog.String("op", op))
+
+	stored, err := auth.useCode(ctx, models.CodePurposePasswordReset, code)
+
+	if err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			log.Info("invalid reset code")
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := auth.userProvider.UserByID(ctx, stored.UserID)
+
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	//код не погашен, поэтому после отказа политики его можно использовать с другим паролем
+	if err := auth.checkPassword(newPassword, user.Email); err != nil {
+		log.Info("password rejected by policy", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := auth.passwordHasher.Hash(ctx, newPassword)
+
+	if err != nil {
+		log.Error("failed to generate password hash", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, hashingError(err))
+	}
+
+	err = auth.codeStorage.ResetPassword(ctx, stored.ID, stored.UserID, passHash, time.Now())
+
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenUsed) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+		log.Error("failed to reset password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password reset", slog.Int64("user_id", stored.UserID))
+
+	return nil
+}
+
+// ChangePassword меняет пароль пользователя access токена после проверки текущего пароля.
+// При revokeOtherSessions все сессии пользователя завершаются, а текущей выдается новая пара токенов
+// того же приложения; иначе возвращается пустая пара
+func (auth *Auth) ChangePassword(
+	ctx context.Context,
+	token string,
+	currentPassword string,
+	newPassword string,
+	revokeOtherSessions bool) (models.TokenPair, error) {
+	const op = "auth.ChangePassword"
+
+	log := auth.log.With(slog.String("op", op))
+
+	access, user, err := auth.userByToken(ctx, token)
+
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			log.Info("invalid access token")
+		} else {
+			log.Error("failed to verify access token", sl.Err(err))
+		}
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", user.ID))
+
+	//неверный текущий пароль - неудачная попытка входа аккаунта: украденный токен не дает перебирать пароль
+	subjects := loginSubjects(user.Email, "")
+
+	if err := auth.checkThrottle(ctx, log, subjects); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := auth.passwordHasher.Verify(ctx, currentPassword, user.PassHash); err != nil {
+		if hashingUnavailable(err) {
+			log.Warn("failed to verify current password", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, hashingError(err))
+		}
+		log.Info("invalid current password")
+		auth.recordLoginFailure(ctx, log, subjects)
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if err := auth.checkPassword(newPassword, user.Email); err != nil {
+		log.Info("password rejected by policy", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := auth.passwordHasher.Hash(ctx, newPassword)
+
+	if err != nil {
+		log.Error("failed to generate password hash", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, hashingError(err))
+	}
+
+	err = auth.userSaver.UpdatePassword(ctx, user.ID, passHash, time.Now(), revokeOtherSessions)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("failed to update password", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password changed", slog.Bool("revoke_other_sessions", revokeOtherSessions))
+
+	if !revokeOtherSessions {
+		return models.TokenPair{}, nil
+	}
+
+	app, err := auth.appProvider.App(ctx, access.AppID)
+
+	if err != nil {
+		log.Error("failed to get app", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := auth.continuedSession(ctx, access)
+
+	if err != nil {
+		log.Error("failed to get current session", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	//новая сессия продолжает текущую, поэтому наследует ее способы аутентификации
+	tokens, err := auth.issueTokens(ctx, user, app, session, 0, access.AMR)
+
+	if err != nil {
+		log.Error("failed to issue tokens", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// checkPassword проверяет новый пароль по политике. Ошибка оборачивает ErrWeakPassword и *password.PolicyError
+// с нарушениями для ответа клиенту
+func (auth *Auth) checkPassword(newPassword, email string) error {
+	if err := auth.passwordPolicy.Check(newPassword, email); err != nil {
+		return fmt.Errorf("%w: %w", ErrWeakPassword, err)
+	}
+
+	return nil
+}
+
+// rehashPassword заменяет хэш пароля на хэш настроенного алгоритма. Ошибка не мешает входу:
+// хэш пересчитается при следующем входе
+func (auth *Auth) rehashPassword(ctx context.Context, log *slog.Logger, user models.User, password string) {
+	passHash, err := auth.passwordHasher.Hash(ctx, password)
+
+	if err != nil {
+		log.Error("failed to rehash password", sl.Err(err))
+		return
+	}
+
+	if err := auth.userSaver.RehashPassword(ctx, user.ID, user.PassHash, passHash); err != nil {
+		log.Error("failed to save rehashed password", sl.Err(err))
+		return
+	}
+
+	log.Info("password rehashed")
+}
+
+// PasswordHashParams алгоритм и параметры, которыми хэшируются новые пароли, и результат их калибровки.
+// Доступно только администратору, access токен которого передан в token
+func (auth *Auth) PasswordHashParams(ctx context.Context, token string) (models.HashParams, error) {
+	const op = "auth.PasswordHashParams"
+
+	log := auth.log.With(slog.String("op", op))
+
+	if err := auth.requireAdmin(ctx, token); err != nil {
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrPermissionDenied) {
+			log.Warn("hash params request denied", sl.Err(err))
+		} else {
+			log.Error("failed to authorize hash params request", sl.Err(err))
+		}
+		return models.HashParams{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return auth.hashParams, nil
+}
+
+// hashingUnavailable пароль не проверен из-за переполненного пула хэширования или отмены запроса,
+// поэтому ошибка ничего не говорит о самом пароле
+func hashingUnavailable(err error) bool {
+	return errors.Is(err, password.ErrPoolFull) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// hashingError ErrBusy вместо переполненного пула, чтобы клиент повторил запрос позже
+func hashingError(err error) error {
+	if errors.Is(err, password.ErrPoolFull) {
+		return fmt.Errorf("%w: %w", ErrBusy, err)
+	}
+
+	return err
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwe"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/opaque"
+	"sso/internal/lib/paseto"
+	"sso/internal/lib/tokens"
+	"sso/internal/storage"
+	"time"
+)
+
+// Refresh обменивает refresh токен на новую пару токенов. Старый refresh токен становится использованным;
+// повторное предъявление использованного токена означает утечку, и все семейство отзывается
+func (auth *Auth) Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error) {
+	const op = "auth.Refresh"
+
+	log := auth.log.With(slog.String("op", op))
+
+	now := time.Now()
+
+	stored, err := auth.tokenStorage.RefreshToken(ctx, opaque.Hash(refreshToken))
+
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Warn("refresh token not found")
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("failed to get refresh token", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(
+		slog.Int64("user_id", stored.UserID),
+		slog.String("family_id", stored.FamilyID),
+	)
+
+	if !stored.RevokedAt.IsZero() {
+		log.Warn("revoked refresh token presented")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if !stored.UsedAt.IsZero() {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, auth.revokeReusedFamily(ctx, log, stored, now))
+	}
+
+	if !now.Before(stored.ExpiresAt) {
+		log.Info("refresh token expired")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	user, err := auth.userProvider.UserByID(ctx, stored.UserID)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := auth.appProvider.App(ctx, stored.AppID)
+
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := auth.issueTokens(ctx, user, app, models.Session{ID: stored.FamilyID}, stored.ID, stored.AMR)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenUsed) {
+			//параллельный запрос успел обменять этот же токен
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, auth.revokeReusedFamily(ctx, log, stored, now))
+		}
+		log.Error("failed to issue tokens", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("tokens refreshed")
+
+	return tokens, nil
+}
+
+func (auth *Auth) revokeReusedFamily(ctx context.Context, log *slog.Logger, stored models.RefreshToken, now time.Time) error {
+	log.Warn("refresh token reuse detected, revoking family")
+
+	if err := auth.tokenStorage.RevokeRefreshTokenFamily(ctx, stored.FamilyID, now); err != nil {
+		log.Error("failed to revoke refresh token family", sl.Err(err))
+		return err
+	}
+
+	return ErrInvalidToken
+}
+
+// issueTokens выпускает access токен и следующий refresh токен сессии; id сессии - это семейство refresh токенов.
+// Если usedRefreshID не ноль, этот refresh токен атомарно помечается использованным.
+// amr способы аутентификации сессии, они переходят и в refresh токен
+func (auth *Auth) issueTokens(
+	ctx context.Context,
+	user models.User,
+	app models.App,
+	session models.Session,
+	usedRefreshID int64,
+	amr []string) (models.TokenPair, error) {
+	key, err := auth.signingKey(ctx, app)
+
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	accessTTL, refreshTTL := auth.tokenTTL, auth.refreshTTL
+
+	if app.AccessTokenTTL > 0 {
+		accessTTL = app.AccessTokenTTL
+	}
+
+	if app.RefreshTokenTTL > 0 {
+		refreshTTL = app.RefreshTokenTTL
+	}
+
+	now := time.Now()
+
+	claims, err := tokens.NewClaims(user, app, tokens.Params{
+		Issuer:       auth.issuer,
+		TTL:          accessTTL,
+		LegacyClaims: auth.legacyClaims,
+	}, now)
+
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	claims.AMR = amr
+	claims.SessionID = session.ID
+
+	//роли читаются при каждом выпуске: выданная или отозванная роль действует со следующего refresh
+	claims.Roles, claims.Permissions, err = auth.appRoles(ctx, user.ID, app.ID)
+
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	accessToken, err := formatByAlg(key.Alg).NewToken(claims, app, key)
+
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	if app.EncryptionKey != nil {
+		//PASETO v4.local уже скрывает содержимое; JWE вкладывает только JWT
+		if paseto.Supports(key.Alg) {
+			return models.TokenPair{}, fmt.Errorf("%w: app %d encrypts tokens but signs with %s", ErrKeyPolicy, app.ID, key.Alg)
+		}
+
+		accessToken, err = jwe.Encrypt(accessToken, app.EncryptionKey)
+
+		if err != nil {
+			return models.TokenPair{}, err
+		}
+	}
+
+	refreshToken, refreshHash, err := opaque.New()
+
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	next := models.RefreshToken{
+		TokenHash: refreshHash,
+		FamilyID:  session.ID,
+		UserID:    user.ID,
+		AppID:     app.ID,
+		AMR:       amr,
+		CreatedAt: now,
+		ExpiresAt: now.Add(refreshTTL),
+	}
+
+	if usedRefreshID == 0 {
+		err = auth.tokenStorage.SaveRefreshToken(ctx, next)
+	} else {
+		err = auth.tokenStorage.RotateRefreshToken(ctx, usedRefreshID, next, now)
+	}
+
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	//у существующей сессии обновляются только время использования и срок
+	session.UserID = user.ID
+	session.AppID = app.ID
+	session.CreatedAt = now
+	session.LastUsedAt = now
+	session.ExpiresAt = next.ExpiresAt
+
+	if err := auth.sessionStorage.SaveSession(ctx, session); err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return models.TokenPair{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// PruneRefreshTokens удаляет семейства refresh токенов, в которых не осталось действующих токенов,
+// в том числе семейства сессий, удаленных PruneSessions
+func (auth *Auth) PruneRefreshTokens(ctx context.Context) (int64, error) {
+	const op = "auth.PruneRefreshTokens"
+
+	deleted, err := auth.tokenStorage.DeleteExpiredRefreshTokens(ctx, time.Now())
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/opaque"
+	"sso/internal/storage"
+	"time"
+)
+
+// Logout отзывает access токен пользователя и, если передан, семейство его refresh токена
+func (auth *Auth) Logout(ctx context.Context, token, refreshToken string) error {
+	const op = "auth.Logout"
+
+	log := auth.log.With(slog.String("op", op))
+
+	access, err := auth.verifyAccessToken(ctx, token)
+
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			log.Info("logout with invalid token")
+		} else {
+			log.Error("failed to verify token", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("user_id", access.UserID))
+
+	if err := auth.revokeAccessToken(ctx, access); err != nil {
+		log.Error("failed to revoke access token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if refreshToken != "" {
+		if _, err := auth.revokeRefreshToken(ctx, refreshToken, access.UserID, 0); err != nil {
+			log.Error("failed to revoke refresh token", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("user logged out")
+
+	return nil
+}
+
+// RevokeToken отзыв токена приложением по RFC 7009: приложение аутентифицируется по app_id и secret,
+// неизвестный или уже недействительный токен отзывом не считается ошибкой. Токены других приложений
+// молча пропускаются (RFC 7009 §2.1), чтобы одно приложение не могло разлогинивать пользователей другого
+func (auth *Auth) RevokeToken(
+	ctx context.Context,
+	clientID int,
+	clientSecret string,
+	token string,
+	tokenTypeHint string) error {
+	const op = "auth.RevokeToken"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int("client_id", clientID),
+	)
+
+	if err := auth.authenticateClient(ctx, clientID, clientSecret); err != nil {
+		log.Warn("revocation client authentication failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tokenTypeHint != models.TokenTypeRefresh {
+		access, err := auth.verifyAccessToken(ctx, token)
+
+		switch {
+		case err == nil && access.AppID != clientID:
+			log.Warn("ignoring revocation of another app's token", slog.Int("token_app_id", access.AppID))
+			return nil
+		case err == nil:
+			if err := auth.revokeAccessToken(ctx, access); err != nil {
+				log.Error("failed to revoke access token", sl.Err(err))
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			log.Info("access token revoked", slog.Int64("user_id", access.UserID))
+			return nil
+		case !errors.Is(err, ErrInvalidToken):
+			log.Error("failed to verify token", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	revoked, err := auth.revokeRefreshToken(ctx, token, 0, clientID)
+
+	if err != nil {
+		log.Error("failed to revoke refresh token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if revoked {
+		log.Info("refresh token family revoked")
+	}
+
+	return nil
+}
+
+// IsRevoked проверка jti по denylist для верификаторов, которые проверяют JWT сами
+func (auth *Auth) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	const op = "auth.IsRevoked"
+
+	revoked, err := auth.tokenStorage.IsTokenRevoked(ctx, jti)
+
+	if err != nil {
+		auth.log.Error("failed to check revocation", slog.String("op", op), sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
+// PruneRevokedTokens удаляет из denylist записи об истекших токенах
+func (auth *Auth) PruneRevokedTokens(ctx context.Context) (int64, error) {
+	const op = "auth.PruneRevokedTokens"
+
+	deleted, err := auth.tokenStorage.DeleteExpiredRevokedTokens(ctx, time.Now())
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+func (auth *Auth) revokeAccessToken(ctx context.Context, access accessToken) error {
+	if access.ID == "" {
+		//токены, выпущенные до появления jti, отозвать нельзя: они доживут до exp
+		return nil
+	}
+
+	return auth.tokenStorage.RevokeToken(ctx, access.ID, access.ExpiresAt, time.Now())
+}
+
+// revokeRefreshToken отзывает семейство refresh токена. Если userID или appID не ноль,
+// токен другого пользователя или приложения не трогается
+func (auth *Auth) revokeRefreshToken(ctx context.Context, refreshToken string, userID int64, appID int) (bool, error) {
+	stored, err := auth.tokenStorage.RefreshToken(ctx, opaque.Hash(refreshToken))
+
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if userID != 0 && stored.UserID != userID {
+		return false, nil
+	}
+
+	if appID != 0 && stored.AppID != appID {
+		return false, nil
+	}
+
+	if err := auth.tokenStorage.RevokeRefreshTokenFamily(ctx, stored.FamilyID, time.Now()); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
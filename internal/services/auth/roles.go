@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+// RoleStorage роли и права пользователей. Глобальная роль admin заменяет флаг администратора
+type RoleStorage interface {
+	CreateRole(ctx context.Context, role models.Role) (int64, error)
+	GrantRole(ctx context.Context, userId int64, roleId int64, now time.Time) error
+	RevokeRole(ctx context.Context, userId int64, roleId int64) error
+	UserRoles(ctx context.Context, userId int64) ([]models.Role, error)
+}
+
+// CreateRole создает роль; appID 0 - глобальная роль. Доступно только администратору
+func (auth *Auth) CreateRole(ctx context.Context, token string, role models.Role) (int64, error) {
+	const op = "auth.CreateRole"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("role", role.Name),
+		slog.Int("app_id", role.AppID),
+	)
+
+	if err := auth.requireAdmin(ctx, token); err != nil {
+		auth.logRoleDenied(log, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if role.AppID != 0 {
+		if _, err := auth.appProvider.App(ctx, role.AppID); err != nil {
+			if errors.Is(err, storage.ErrAppNotFound) {
+				return 0, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+			}
+			log.Error("failed to get app", sl.Err(err))
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	role.CreatedAt = time.Now()
+
+	id, err := auth.roleStorage.CreateRole(ctx, role)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrRoleExists) {
+			return 0, fmt.Errorf("%s: %w", op, ErrRoleExists)
+		}
+		log.Error("failed to create role", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role created", slog.Int64("role_id", id))
+
+	return id, nil
+}
+
+// GrantRole выдает роль пользователю. Роль попадает в токены при следующем входе или обмене refresh токена
+func (auth *Auth) GrantRole(ctx context.Context, token string, userID, roleID int64) error {
+	const op = "auth.GrantRole"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("role_id", roleID),
+	)
+
+	if err := auth.requireAdmin(ctx, token); err != nil {
+		auth.logRoleDenied(log, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.roleStorage.GrantRole(ctx, userID, roleID, time.Now()); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		case errors.Is(err, storage.ErrRoleNotFound):
+			return fmt.Errorf("%s: %w", op, ErrRoleNotFound)
+		}
+		log.Error("failed to grant role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role granted")
+
+	return nil
+}
+
+// RevokeRole забирает роль у пользователя. Уже выпущенные access токены сохраняют ее до истечения
+func (auth *Auth) RevokeRole(ctx context.Context, token string, userID, roleID int64) error {
+	const op = "auth.RevokeRole"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("role_id", roleID),
+	)
+
+	if err := auth.requireAdmin(ctx, token); err != nil {
+		auth.logRoleDenied(log, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := auth.roleStorage.RevokeRole(ctx, userID, roleID); err != nil {
+		if errors.Is(err, storage.ErrRoleNotGranted) {
+			return fmt.Errorf("%s: %w", op, ErrRoleNotGranted)
+		}
+		log.Error("failed to revoke role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role revoked")
+
+	return nil
+}
+
+// UserRoles роли пользователя userID во всех приложениях; 0 - владелец токена. Чужие роли видит
+// только администратор
+func (auth *Auth) UserRoles(ctx context.Context, token string, userID int64) ([]models.Role, error) {
+	const op = "auth.UserRoles"
+
+	log := auth.log.With(slog.String("op", op))
+
+	_, user, err := auth.userByToken(ctx, token)
+
+	if err != nil {
+		if !errors.Is(err, ErrInvalidToken) {
+			log.Error("failed to verify token", sl.Err(err))
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if userID == 0 {
+		userID = user.ID
+	}
+
+	if userID != user.ID && !user.IsAdmin {
+		log.Warn("listing roles denied", slog.Int64("user_id", user.ID), slog.Int64("target_user_id", userID))
+		return nil, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	roles, err := auth.roleStorage.UserRoles(ctx, userID)
+
+	if err != nil {
+		log.Error("failed to get roles", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// appRoles имена ролей пользователя, действующих в приложении appID, и объединение их прав для токена
+func (auth *Auth) appRoles(ctx context.Context, userID int64, appID int) (roles []string, permissions []string, err error) {
+	all, err := auth.roleStorage.UserRoles(ctx, userID)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, role := range all {
+		if role.AppID != 0 && role.AppID != appID {
+			continue
+		}
+
+		roles = append(roles, role.Name)
+		permissions = append(permissions, role.Permissions...)
+	}
+
+	//одно имя может быть и у глобальной роли, и у роли приложения
+	slices.Sort(roles)
+	slices.Sort(permissions)
+
+	return slices.Compact(roles), slices.Compact(permissions), nil
+}
+
+func (auth *Auth) logRoleDenied(log *slog.Logger, err error) {
+	if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrPermissionDenied) {
+		log.Warn("role management denied", sl.Err(err))
+	} else {
+		log.Error("failed to authorize role management", sl.Err(err))
+	}
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/opaque"
+	"sso/internal/storage"
+	"time"
+)
+
+// SessionStorage сессии пользователей. Id сессии совпадает с семейством ее refresh токенов,
+// поэтому отзыв сессии отзывает и их
+type SessionStorage interface {
+	SaveSession(ctx context.Context, session models.Session) error
+	Session(ctx context.Context, id string) (models.Session, error)
+	ActiveSessions(ctx context.Context, userId int64, now time.Time) ([]models.Session, error)
+	RevokeSession(ctx context.Context, id string, userId int64, now time.Time) error
+	RevokeOtherSessions(ctx context.Context, userId int64, keepId string, now time.Time) error
+	RevokeAllSessions(ctx context.Context, userId int64, now time.Time) error
+	DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error)
+}
+
+// ListSessions активные сессии владельца токена и id сессии, в которой выпущен сам токен
+func (auth *Auth) ListSessions(ctx context.Context, token string) ([]models.Session, string, error) {
+	const op = "auth.ListSessions"
+
+	log := auth.log.With(slog.String("op", op))
+
+	access, err := auth.verifyAccessToken(ctx, token)
+
+	if err != nil {
+		if !errors.Is(err, ErrInvalidToken) {
+			log.Error("failed to verify token", sl.Err(err))
+		}
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessions, err := auth.sessionStorage.ActiveSessions(ctx, access.UserID, time.Now())
+
+	if err != nil {
+		log.Error("failed to get sessions", sl.Err(err))
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, access.SessionID, nil
+}
+
+// RevokeSession завершает одну сессию владельца токена; ее access токены перестают проходить проверку сразу,
+// а refresh токены отзываются
+func (auth *Auth) RevokeSession(ctx context.Context, token, sessionID string) error {
+	const op = "auth.RevokeSession"
+
+	log := auth.log.With(slog.String("op", op))
+
+	access, err := auth.verifyAccessToken(ctx, token)
+
+	if err != nil {
+		if !errors.Is(err, ErrInvalidToken) {
+			log.Error("failed to verify token", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(
+		slog.Int64("user_id", access.UserID),
+		slog.String("session_id", sessionID),
+	)
+
+	//чужая сессия неотличима от несуществующей, чтобы не раскрывать id
+	if err := auth.sessionStorage.RevokeSession(ctx, sessionID, access.UserID, time.Now()); err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+		}
+		log.Error("failed to revoke session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("session revoked")
+
+	return nil
+}
+
+// RevokeAllSessions завершает все сессии владельца токена. С keepCurrent остается сессия самого токена;
+// токен без сессии в этом случае ничего не сохраняет
+func (auth *Auth) RevokeAllSessions(ctx context.Context, token string, keepCurrent bool) error {
+	const op = "auth.RevokeAllSessions"
+
+	log := auth.log.With(slog.String("op", op))
+
+	access, err := auth.verifyAccessToken(ctx, token)
+
+	if err != nil {
+		if !errors.Is(err, ErrInvalidToken) {
+			log.Error("failed to verify token", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(
+		slog.Int64("user_id", access.UserID),
+		slog.Bool("keep_current", keepCurrent),
+	)
+
+	if keepCurrent && access.SessionID != "" {
+		err = auth.sessionStorage.RevokeOtherSessions(ctx, access.UserID, access.SessionID, time.Now())
+	} else {
+		err = auth.sessionStorage.RevokeAllSessions(ctx, access.UserID, time.Now())
+	}
+
+	if err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("sessions revoked")
+
+	return nil
+}
+
+// IsSessionRevoked проверка sid для верификаторов, которые проверяют токены сами. Удаленная после истечения
+// сессия считается отозванной: ее токены к этому времени тоже истекли
+func (auth *Auth) IsSessionRevoked(ctx context.Context, sid string) (bool, error) {
+	const op = "auth.IsSessionRevoked"
+
+	revoked, err := auth.isSessionRevoked(ctx, sid, 0)
+
+	if err != nil {
+		auth.log.Error("failed to check session", slog.String("op", op), sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
+// PruneSessions удаляет истекшие сессии
+func (auth *Auth) PruneSessions(ctx context.Context) (int64, error) {
+	const op = "auth.PruneSessions"
+
+	deleted, err := auth.sessionStorage.DeleteExpiredSessions(ctx, time.Now())
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+// isSessionRevoked сессия отозвана, удалена или, если userID не ноль, принадлежит другому пользователю
+func (auth *Auth) isSessionRevoked(ctx context.Context, sid string, userID int64) (bool, error) {
+	session, err := auth.sessionStorage.Session(ctx, sid)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	if userID != 0 && session.UserID != userID {
+		return true, nil
+	}
+
+	return !session.RevokedAt.IsZero(), nil
+}
+
+// newSession новая сессия входа; остальные поля заполняет issueTokens
+func newSession(clientIP, userAgent string) (models.Session, error) {
+	id, err := opaque.NewID()
+
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	return models.Session{ID: id, ClientIP: clientIP, UserAgent: userAgent}, nil
+}
+
+// continuedSession новая сессия, которая продолжает сессию access токена с того же клиента
+func (auth *Auth) continuedSession(ctx context.Context, access accessToken) (models.Session, error) {
+	var clientIP, userAgent string
+
+	if access.SessionID != "" {
+		current, err := auth.sessionStorage.Session(ctx, access.SessionID)
+
+		if err != nil && !errors.Is(err, storage.ErrSessionNotFound) {
+			return models.Session{}, err
+		}
+
+		clientIP, userAgent = current.ClientIP, current.UserAgent
+	}
+
+	return newSession(clientIP, userAgent)
+}
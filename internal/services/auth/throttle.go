@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"strings"
+	"time"
+)
+
+// ThrottlePolicy ограничение попыток входа. Неудачи считаются отдельно по email и по IP клиента.
+// После BackoffAfter неудач подряд по email каждая следующая попытка ждет вдвое дольше предыдущей,
+// начиная с BackoffBase и не дольше BackoffMax. По достижении порога блокировки вход закрывается
+// на LockoutDuration. Нулевые пороги отключают соответствующую проверку
+type ThrottlePolicy struct {
+	// Window неудачи старше окна забываются
+	Window                time.Duration
+	BackoffAfter          int
+	BackoffBase           time.Duration
+	BackoffMax            time.Duration
+	EmailLockoutThreshold int
+	IPLockoutThreshold    int
+	LockoutDuration       time.Duration
+}
+
+type AttemptStorage interface {
+	LoginAttempt(ctx context.Context, kind, subject string) (models.LoginAttempt, error)
+	RecordLoginFailure(ctx context.Context, kind, subject string, now, windowStart time.Time) (models.LoginAttempt, error)
+	LockLogin(ctx context.Context, kind, subject string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, kind, subject string) (bool, error)
+	DeleteStaleLoginAttempts(ctx context.Context, before, now time.Time) (int64, error)
+}
+
+// allowed сообщает, можно ли сейчас попытаться войти с этим счетчиком
+func (p ThrottlePolicy) allowed(attempt models.LoginAttempt, now time.Time) bool {
+	if now.Before(attempt.LockedUntil) {
+		return false
+	}
+
+	if attempt.Kind != models.LoginAttemptEmail {
+		return true
+	}
+
+	return !now.Before(attempt.LastFailureAt.Add(p.backoff(attempt.Failures)))
+}
+
+// backoff пауза после failures неудач подряд
+func (p ThrottlePolicy) backoff(failures int) time.Duration {
+	if p.BackoffAfter <= 0 || failures < p.BackoffAfter {
+		return 0
+	}
+
+	delay := p.BackoffBase
+
+	for i := p.BackoffAfter; i < failures && delay < p.BackoffMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, p.BackoffMax)
+}
+
+func (p ThrottlePolicy) lockoutThreshold(kind string) int {
+	if kind == models.LoginAttemptIP {
+		return p.IPLockoutThreshold
+	}
+	return p.EmailLockoutThreshold
+}
+
+// loginSubjects ключи счетчиков попытки входа. Email приводится к нижнему регистру, чтобы регистр
+// не давал новых попыток; IP может быть неизвестен, тогда считается только email
+func loginSubjects(email, clientIP string) map[string]string {
+	subjects := map[string]string{models.LoginAttemptEmail: strings.ToLower(email)}
+
+	if clientIP != "" {
+		subjects[models.LoginAttemptIP] = clientIP
+	}
+
+	return subjects
+}
+
+// checkThrottle возвращает ErrTooManyAttempts, если email или IP заблокированы или еще ждут паузы
+func (auth *Auth) checkThrottle(ctx context.Context, log *slog.Logger, subjects map[string]string) error {
+	now := time.Now()
+
+	for kind, subject := range subjects {
+		attempt, err := auth.attemptStorage.LoginAttempt(ctx, kind, subject)
+
+		if err != nil {
+			return err
+		}
+
+		if !auth.throttle.allowed(attempt, now) {
+			log.Warn("login throttled", slog.String("kind", kind), slog.Int("failures", attempt.Failures))
+			return ErrTooManyAttempts
+		}
+	}
+
+	return nil
+}
+
+// recordLoginFailure учитывает неудачную попытку и блокирует вход по достижении порога.
+// Ошибки хранилища только логируются: клиент в любом случае получает ErrInvalidCredentials
+func (auth *Auth) recordLoginFailure(ctx context.Context, log *slog.Logger, subjects map[string]string) {
+	now := time.Now()
+
+	for kind, subject := range subjects {
+		attempt, err := auth.attemptStorage.RecordLoginFailure(ctx, kind, subject, now, now.Add(-auth.throttle.Window))
+
+		if err != nil {
+			log.Error("failed to record login failure", slog.String("kind", kind), sl.Err(err))
+			continue
+		}
+
+		threshold := auth.throttle.lockoutThreshold(kind)
+
+		if threshold <= 0 || attempt.Failures < threshold {
+			continue
+		}
+
+		until := now.Add(auth.throttle.LockoutDuration)
+
+		if err := auth.attemptStorage.LockLogin(ctx, kind, subject, until); err != nil {
+			log.Error("failed to lock login", slog.String("kind", kind), sl.Err(err))
+			continue
+		}
+
+		log.Warn("login locked", slog.String("kind", kind), slog.Int("failures", attempt.Failures), slog.Time("until", until))
+	}
+}
+
+// UnlockLogin снимает блокировку и сбрасывает счетчики неудач для email и/или IP.
+// Доступно только администратору, access токен которого передан в token
+func (auth *Auth) UnlockLogin(ctx context.Context, token, email, clientIP string) error {
+	const op = "auth.UnlockLogin"
+
+	log := auth.log.With(slog.String("op", op))
+
+	if err := auth.requireAdmin(ctx, token); err != nil {
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrPermissionDenied) {
+			log.Warn("unlock denied", sl.Err(err))
+		} else {
+			log.Error("failed to authorize unlock", sl.Err(err))
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	subjects := map[string]string{}
+
+	if email != "" {
+		subjects[models.LoginAttemptEmail] = strings.ToLower(email)
+	}
+
+	if clientIP != "" {
+		subjects[models.LoginAttemptIP] = clientIP
+	}
+
+	for kind, subject := range subjects {
+		reset, err := auth.attemptStorage.ResetLoginAttempts(ctx, kind, subject)
+
+		if err != nil {
+			log.Error("failed to reset login attempts", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("login unlocked", slog.String("kind", kind), slog.String("subject", subject), slog.Bool("had_failures", reset))
+	}
+
+	return nil
+}
+
+// PruneLoginAttempts удаляет счетчики, у которых истекли и окно неудач, и блокировка
+func (auth *Auth) PruneLoginAttempts(ctx context.Context) (int64, error) {
+	const op = "auth.PruneLoginAttempts"
+
+	now := time.Now()
+
+	deleted, err := auth.attemptStorage.DeleteStaleLoginAttempts(ctx, now.Add(-auth.throttle.Window), now)
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
+
+// requireAdmin проверяет, что access токен действителен и выпущен администратору
+func (auth *Auth) requireAdmin(ctx context.Context, token string) error {
+	_, user, err := auth.userByToken(ctx, token)
+
+	if err != nil {
+		return err
+	}
+
+	if !user.IsAdmin {
+		return fmt.Errorf("%w: user %d is not admin", ErrPermissionDenied, user.ID)
+	}
+
+	return nil
+}
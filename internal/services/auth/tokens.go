@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwe"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/paseto"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+// accessToken проверенный access токен
+type accessToken struct {
+	ID        string
+	UserID    int64
+	Email     string
+	AppID     int
+	ExpiresAt time.Time
+	IssuedAt  time.Time
+	Scopes    []string
+	AMR       []string
+	SessionID string
+}
+
+// formatByAlg формат токенов, которые выпускает ключ с алгоритмом alg; пустой alg - общий секрет приложения
+func formatByAlg(alg string) TokenFormat {
+	if paseto.Supports(alg) {
+		return paseto.Format{}
+	}
+	return jwt.Format{}
+}
+
+// formatOf формат уже выпущенного токена. Он определяется по самому токену, а не по настройке приложения,
+// чтобы после смены формата ранее выпущенные токены проверялись до истечения срока
+func formatOf(token string) TokenFormat {
+	if paseto.IsToken(token) {
+		return paseto.Format{}
+	}
+	return jwt.Format{}
+}
+
+// verifyAccessToken проверяет подпись ключами приложения из app_id, срок действия, denylist,
+// существование пользователя и то, что токен выпущен после последнего сброса пароля.
+// Любая причина недействительности - ErrInvalidToken
+func (auth *Auth) verifyAccessToken(ctx context.Context, token string) (accessToken, error) {
+	//приватного ключа приложения у sso нет, поэтому JWE не читается; приложение передает вложенный JWT
+	if jwe.IsToken(token) {
+		return accessToken{}, ErrInvalidToken
+	}
+
+	format := formatOf(token)
+
+	appID, err := format.UnverifiedAppID(token)
+
+	if err != nil {
+		return accessToken{}, ErrInvalidToken
+	}
+
+	app, err := auth.appProvider.App(ctx, appID)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return accessToken{}, ErrInvalidToken
+		}
+		return accessToken{}, err
+	}
+
+	keys, err := auth.keyProvider.AppKeys(ctx, app.ID)
+
+	if err != nil {
+		return accessToken{}, err
+	}
+
+	claims, err := format.Parse(token, app, keys, time.Now())
+
+	if err != nil {
+		return accessToken{}, ErrInvalidToken
+	}
+
+	userID, ok := claims.UserID()
+
+	if !ok {
+		return accessToken{}, ErrInvalidToken
+	}
+
+	res := accessToken{
+		ID:        claims.ID,
+		UserID:    userID,
+		AppID:     app.ID,
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		AMR:       claims.AMR,
+		SessionID: claims.SessionID,
+	}
+
+	if scope, ok := claims.Extra["scope"].(string); ok {
+		res.Scopes = strings.Fields(scope)
+	}
+
+	if res.ID != "" {
+		revoked, err := auth.tokenStorage.IsTokenRevoked(ctx, res.ID)
+
+		if err != nil {
+			return accessToken{}, err
+		}
+
+		if revoked {
+			return accessToken{}, ErrInvalidToken
+		}
+	}
+
+	//токены без sid выпущены до появления сессий и отзываются только по jti и сбросу пароля
+	if res.SessionID != "" {
+		revoked, err := auth.isSessionRevoked(ctx, res.SessionID, res.UserID)
+
+		if err != nil {
+			return accessToken{}, err
+		}
+
+		if revoked {
+			return accessToken{}, ErrInvalidToken
+		}
+	}
+
+	//пользователь мог быть удален после выпуска токена; email берется из хранилища,
+	//потому что шаблон claims приложения может его не включать
+	user, err := auth.userProvider.UserByID(ctx, res.UserID)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return accessToken{}, ErrInvalidToken
+		}
+		return accessToken{}, err
+	}
+
+	//токены с sid отозваны сбросом вместе с сессиями. Для токенов без sid iat в секундах, поэтому
+	//недействителен и токен, выпущенный в ту же секунду, что и сброс
+	if res.SessionID == "" && !claims.IssuedAt.After(user.TokensValidAfter) {
+		return accessToken{}, ErrInvalidToken
+	}
+
+	res.Email = user.Email
+
+	return res, nil
+}
+
+// userByToken пользователь действительного access токена
+func (auth *Auth) userByToken(ctx context.Context, token string) (accessToken, models.User, error) {
+	access, err := auth.verifyAccessToken(ctx, token)
+
+	if err != nil {
+		return accessToken{}, models.User{}, err
+	}
+
+	user, err := auth.userProvider.UserByID(ctx, access.UserID)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return accessToken{}, models.User{}, ErrInvalidToken
+		}
+		return accessToken{}, models.User{}, err
+	}
+
+	return access, user, nil
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/mail"
+	"sso/internal/lib/opaque"
+	"sso/internal/storage"
+	"time"
+)
+
+// SendVerification отправляет код подтверждения email. Для неизвестного или уже подтвержденного адреса
+// письмо не отправляется, но результат тот же, чтобы по ответу нельзя было перебирать адреса: код
+// создается и отправляется в фоне
+func (auth *Auth) SendVerification(ctx context.Context, email string) error {
+	const op = "auth.SendVerification"
+
+	log := auth.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	user, err := auth.userProvider.User(ctx, email)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("verification requested for unknown email")
+			return nil
+		}
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.EmailVerified {
+		log.Info("email already verified")
+		return nil
+	}
+
+	auth.sendInBackground(ctx, log, func(ctx context.Context) error {
+		return auth.sendVerification(ctx, user)
+	})
+
+	return nil
+}
+
+// VerifyEmail погашает код из письма и отмечает email пользователя подтвержденным
+func (auth *Auth) VerifyEmail(ctx context.Context, code string) error {
+	const op = "auth.VerifyEmail"
+
+	log := auth.log.With(slog.String("op", op))
+
+	stored, err := auth.useCode(ctx, models.CodePurposeEmailVerification, code)
+
+	if err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			log.Info("invalid verification code")
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = auth.codeStorage.VerifyEmail(ctx, stored.ID, stored.UserID, time.Now())
+
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenUsed) {
+			return fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+		log.Error("failed to verify email", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email verified", slog.Int64("user_id", stored.UserID))
+
+	return nil
+}
+
+func (auth *Auth) sendVerification(ctx context.Context, user models.User) error {
+	code, err := auth.newCode(ctx, user, models.CodePurposeEmailVerification, 0, auth.verificationTTL)
+
+	if err != nil {
+		return err
+	}
+
+	return auth.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Use this code to confirm your email address:\r\n\r\ncode: %s\r\n\r\n"+
+			"The code expires in %s. If you didn't register, ignore this message.\r\n", code, auth.verificationTTL),
+	})
+}
+
+// newCode выпускает одноразовый код; в хранилище попадает только его хэш. appID нужен только MFA challenge
+func (auth *Auth) newCode(ctx context.Context, user models.User, purpose string, appID int, ttl time.Duration) (string, error) {
+	code, hash, err := opaque.New()
+
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	err = auth.codeStorage.SaveCode(ctx, models.OneTimeCode{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		AppID:     appID,
+		CodeHash:  hash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// useCode находит действующий код; неизвестный, использованный и истекший код - ErrInvalidCode.
+// Сам код погашается транзакцией вместе с действием, которое он разрешает
+func (auth *Auth) useCode(ctx context.Context, purpose, code string) (models.OneTimeCode, error) {
+	stored, err := auth.codeStorage.Code(ctx, purpose, opaque.Hash(code))
+
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return models.OneTimeCode{}, ErrInvalidCode
+		}
+		return models.OneTimeCode{}, err
+	}
+
+	if !stored.UsedAt.IsZero() || !time.Now().Before(stored.ExpiresAt) {
+		return models.OneTimeCode{}, ErrInvalidCode
+	}
+
+	return stored, nil
+}
+
+// PruneCodes удаляет истекшие коды подтверждения email и сброса пароля
+func (auth *Auth) PruneCodes(ctx context.Context) (int64, error) {
+	const op = "auth.PruneCodes"
+
+	deleted, err := auth.codeStorage.DeleteExpiredCodes(ctx, time.Now())
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,381 @@
+package authz
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/authz"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/storage"
+	"time"
+)
+
+// Authz проверки доступа на основе отношений: приложение описывает схему своих объектов, пишет кортежи
+// object#relation@subject и спрашивает, есть ли у субъекта отношение к объекту. Схема и кортежи у каждого
+// приложения свои; приложение аутентифицируется app_id и секретом, как при интроспекции
+type Authz struct {
+	log           *slog.Logger
+	appProvider   AppProvider
+	schemaStorage SchemaStorage
+	tupleStorage  TupleStorage
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appId int) (models.App, error)
+}
+
+type SchemaStorage interface {
+	AuthzSchema(ctx context.Context, appId int) (models.AuthzSchema, error)
+	SaveAuthzSchema(ctx context.Context, schema models.AuthzSchema) error
+}
+
+type TupleStorage interface {
+	WriteTuples(ctx context.Context, appId int, writes, deletes []models.RelationTuple, now time.Time) error
+	RelationTuples(ctx context.Context, appId int, namespace, objectId, relation string) ([]models.RelationTuple, error)
+	TupleObjectIDs(ctx context.Context, appId int, namespace, after string, limit int) ([]string, error)
+}
+
+var (
+	ErrInvalidClient  = errors.New("invalid client credentials")
+	ErrSchemaNotFound = errors.New("authz schema not found")
+	//ошибки разбора и вычисления - из authz, их текст описывает проблему
+	ErrInvalidSchema   = authz.ErrInvalidSchema
+	ErrInvalidTuple    = authz.ErrInvalidTuple
+	ErrDepthExceeded   = authz.ErrDepthExceeded
+	ErrCyclicExclusion = authz.ErrCyclicExclusion
+	ErrBudgetExceeded  = authz.ErrBudgetExceeded
+)
+
+func New(log *slog.Logger, appProvider AppProvider, schemaStorage SchemaStorage, tupleStorage TupleStorage) *Authz {
+	return &Authz{
+		log:           log,
+		appProvider:   appProvider,
+		schemaStorage: schemaStorage,
+		tupleStorage:  tupleStorage,
+	}
+}
+
+// WriteSchema заменяет схему приложения. Кортежи отношений, удаленных из схемы, перестают учитываться
+func (a *Authz) WriteSchema(ctx context.Context, clientID int, clientSecret string, schema []byte) error {
+	const op = "authz.WriteSchema"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", clientID),
+	)
+
+	if err := a.authenticateClient(ctx, clientID, clientSecret); err != nil {
+		log.Warn("authz client authentication failed", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := authz.ParseSchema(schema); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err := a.schemaStorage.SaveAuthzSchema(ctx, models.AuthzSchema{AppID: clientID, Schema: schema, UpdatedAt: time.Now()})
+
+	if err != nil {
+		log.Error("failed to save schema", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("authz schema updated")
+
+	return nil
+}
+
+func (a *Authz) ReadSchema(ctx context.Context, clientID int, clientSecret string) (models.AuthzSchema, error) {
+	const op = "authz.ReadSchema"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", clientID),
+	)
+
+	if err := a.authenticateClient(ctx, clientID, clientSecret); err != nil {
+		log.Warn("authz client authentication failed", sl.Err(err))
+		return models.AuthzSchema{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	schema, err := a.schemaStorage.AuthzSchema(ctx, clientID)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrSchemaNotFound) {
+			return models.AuthzSchema{}, fmt.Errorf("%s: %w", op, ErrSchemaNotFound)
+		}
+		log.Error("failed to get schema", sl.Err(err))
+		return models.AuthzSchema{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return schema, nil
+}
+
+// WriteTuples атомарно удаляет deletes и добавляет writes в записи namespace:id#relation@subject.
+// Добавляемые кортежи должны соответствовать схеме
+func (a *Authz) WriteTuples(ctx context.Context, clientID int, clientSecret string, writes, deletes []string) error {
+	const op = "authz.WriteTuples"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", clientID),
+	)
+
+	schema, err := a.appSchema(ctx, clientID, clientSecret)
+
+	if err != nil {
+		a.logError(log, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	toWrite, err := parseTuples(writes)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, t := range toWrite {
+		if err := schema.ValidateTuple(t); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	//удалить можно и кортеж, который перестал соответствовать схеме
+	toDelete, err := parseTuples(deletes)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.tupleStorage.WriteTuples(ctx, clientID, toWrite, toDelete, time.Now()); err != nil {
+		log.Error("failed to write tuples", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("relation tuples written", slog.Int("writes", len(toWrite)), slog.Int("deletes", len(toDelete)))
+
+	return nil
+}
+
+// Check есть ли у subject (namespace:id или namespace:id#relation) отношение relation к object (namespace:id)
+func (a *Authz) Check(ctx context.Context, clientID int, clientSecret, object, relation, subject string) (bool, error) {
+	const op = "authz.Check"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", clientID),
+	)
+
+	schema, err := a.appSchema(ctx, clientID, clientSecret)
+
+	if err != nil {
+		a.logError(log, err)
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	o, err := authz.ParseObject(object)
+
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sub, err := parseSubject(schema, subject)
+
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	allowed, err := authz.Check(ctx, schema, appTuples{a.tupleStorage, clientID}, o, relation, sub)
+
+	if err != nil {
+		err = engineError(err)
+		a.logError(log, err)
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return allowed, nil
+}
+
+// ListObjects страница объектов пространства имен namespace, к которым у subject есть отношение relation,
+// в записи namespace:id. pageToken - next_page_token предыдущей страницы; пустой nextPageToken - объекты
+// кончились. Страница может быть короче pageSize, если закончился бюджет проверки
+func (a *Authz) ListObjects(
+	ctx context.Context,
+	clientID int,
+	clientSecret, namespace, relation, subject, pageToken string,
+	pageSize int) (objects []string, nextPageToken string, err error) {
+	const op = "authz.ListObjects"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", clientID),
+	)
+
+	schema, err := a.appSchema(ctx, clientID, clientSecret)
+
+	if err != nil {
+		a.logError(log, err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	sub, err := parseSubject(schema, subject)
+
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	ids, next, err := authz.ListObjects(ctx, schema, appTuples{a.tupleStorage, clientID}, namespace, relation, sub, pageToken, pageSize)
+
+	if err != nil {
+		err = engineError(err)
+		a.logError(log, err)
+		return nil, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	objects = make([]string, 0, len(ids))
+
+	for _, id := range ids {
+		objects = append(objects, authz.Object{Namespace: namespace, ID: id}.String())
+	}
+
+	return objects, next, nil
+}
+
+// Expand дерево субъектов отношения relation объекта object
+func (a *Authz) Expand(ctx context.Context, clientID int, clientSecret, object, relation string) (*authz.Tree, error) {
+	const op = "authz.Expand"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", clientID),
+	)
+
+	schema, err := a.appSchema(ctx, clientID, clientSecret)
+
+	if err != nil {
+		a.logError(log, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	o, err := authz.ParseObject(object)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tree, err := authz.Expand(ctx, schema, appTuples{a.tupleStorage, clientID}, o, relation)
+
+	if err != nil {
+		err = engineError(err)
+		a.logError(log, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tree, nil
+}
+
+// appSchema аутентифицирует приложение и разбирает его схему
+func (a *Authz) appSchema(ctx context.Context, clientID int, clientSecret string) (*authz.Schema, error) {
+	if err := a.authenticateClient(ctx, clientID, clientSecret); err != nil {
+		return nil, err
+	}
+
+	stored, err := a.schemaStorage.AuthzSchema(ctx, clientID)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrSchemaNotFound) {
+			return nil, ErrSchemaNotFound
+		}
+		return nil, err
+	}
+
+	//схема проверена при записи, поэтому ошибка здесь - повреждение хранилища
+	return authz.ParseSchema(stored.Schema)
+}
+
+func (a *Authz) authenticateClient(ctx context.Context, clientID int, clientSecret string) error {
+	app, err := a.appProvider.App(ctx, clientID)
+
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return ErrInvalidClient
+		}
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(app.Secret), []byte(clientSecret)) != 1 {
+		return ErrInvalidClient
+	}
+
+	return nil
+}
+
+// logError ошибки клиента пишутся в warn, сбои - в error
+func (a *Authz) logError(log *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidClient):
+		log.Warn("authz client authentication failed", sl.Err(err))
+	case errors.Is(err, ErrSchemaNotFound), errors.Is(err, ErrInvalidTuple):
+	case errors.Is(err, ErrDepthExceeded), errors.Is(err, ErrBudgetExceeded):
+		log.Warn("relation graph is too large", sl.Err(err))
+	case errors.Is(err, ErrCyclicExclusion):
+		log.Warn("relation graph has a cycle through an exclusion", sl.Err(err))
+	default:
+		log.Error("failed to evaluate relation", sl.Err(err))
+	}
+}
+
+// engineError отношение, которого нет в схеме, - ошибка запроса
+func engineError(err error) error {
+	if errors.Is(err, authz.ErrUnknownRelation) {
+		return fmt.Errorf("%w: %w", ErrInvalidTuple, err)
+	}
+	return err
+}
+
+func parseTuples(tuples []string) ([]models.RelationTuple, error) {
+	res := make([]models.RelationTuple, 0, len(tuples))
+
+	for _, s := range tuples {
+		t, err := authz.ParseTuple(s)
+
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, t)
+	}
+
+	return res, nil
+}
+
+func parseSubject(schema *authz.Schema, subject string) (authz.Subject, error) {
+	sub, err := authz.ParseSubject(subject)
+
+	if err != nil {
+		return authz.Subject{}, err
+	}
+
+	if err := schema.ValidateSubject(sub); err != nil {
+		return authz.Subject{}, err
+	}
+
+	return sub, nil
+}
+
+// appTuples кортежи одного приложения для вычисления отношений
+type appTuples struct {
+	storage TupleStorage
+	appID   int
+}
+
+func (t appTuples) RelationTuples(ctx context.Context, namespace, objectID, relation string) ([]models.RelationTuple, error) {
+	return t.storage.RelationTuples(ctx, t.appID, namespace, objectID, relation)
+}
+
+func (t appTuples) ObjectIDs(ctx context.Context, namespace, after string, limit int) ([]string, error) {
+	return t.storage.TupleObjectIDs(ctx, t.appID, namespace, after, limit)
+}
@@ -0,0 +1,140 @@
+package calibration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/password"
+	"sso/internal/storage"
+	"time"
+)
+
+// Calibrator подбирает стоимость хэширования паролей под железо и хранит результат в общем хранилище,
+// чтобы реплики хэшировали с одними параметрами
+type Calibrator struct {
+	log           *slog.Logger
+	paramsStorage ParamsStorage
+	target        time.Duration
+}
+
+type ParamsStorage interface {
+	HashParams(ctx context.Context, algorithm string) (models.HashParams, error)
+	SaveHashParams(ctx context.Context, params models.HashParams, replace bool) (models.HashParams, error)
+}
+
+var ErrInvalidTarget = errors.New("calibration target must be positive")
+
+func New(log *slog.Logger, paramsStorage ParamsStorage, target time.Duration) *Calibrator {
+	return &Calibrator{log: log, paramsStorage: paramsStorage, target: target}
+}
+
+// Scheme схема для хэширования новых паролей. Сохраненные калибровкой параметры используются, если калибровка
+// шла от тех же параметров конфига base. Иначе при calibrate параметры подбираются и сохраняются,
+// а без calibrate используется base
+func (c *Calibrator) Scheme(ctx context.Context, base password.Scheme, calibrate bool) (password.Scheme, models.HashParams, error) {
+	const op = "calibration.Scheme"
+
+	log := c.log.With(
+		slog.String("op", op),
+		slog.String("algorithm", base.Algorithm()),
+	)
+
+	params, err := c.paramsStorage.HashParams(ctx, base.Algorithm())
+
+	switch {
+	case err == nil && params.BaseParams == base.Params():
+		log.Info("using calibrated password hash params", slog.String("params", params.Params))
+		return c.scheme(op, params)
+	case err == nil:
+		log.Warn("calibrated password hash params are stale", slog.String("base_params", params.BaseParams))
+	case !errors.Is(err, storage.ErrHashParamsNotFound):
+		return nil, models.HashParams{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !calibrate {
+		return base, models.HashParams{
+			Algorithm:  base.Algorithm(),
+			Params:     base.Params(),
+			BaseParams: base.Params(),
+			Target:     c.target,
+		}, nil
+	}
+
+	//если другая реплика успела откалибровать от тех же base, хранилище вернет ее параметры
+	params, err = c.calibrate(ctx, base, false)
+
+	if err != nil {
+		return nil, models.HashParams{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return c.scheme(op, params)
+}
+
+// Calibrate заново подбирает параметры от base и заменяет сохраненные. Реплики переходят на них при перезапуске
+func (c *Calibrator) Calibrate(ctx context.Context, base password.Scheme) (models.HashParams, error) {
+	const op = "calibration.Calibrate"
+
+	params, err := c.calibrate(ctx, base, true)
+
+	if err != nil {
+		return models.HashParams{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return params, nil
+}
+
+func (c *Calibrator) calibrate(ctx context.Context, base password.Scheme, replace bool) (models.HashParams, error) {
+	if c.target <= 0 {
+		return models.HashParams{}, ErrInvalidTarget
+	}
+
+	log := c.log.With(
+		slog.String("algorithm", base.Algorithm()),
+		slog.String("base_params", base.Params()),
+		slog.Duration("target", c.target),
+	)
+
+	log.Info("calibrating password hashing")
+
+	scheme, elapsed, err := password.Calibrate(base, c.target)
+
+	if err != nil {
+		return models.HashParams{}, err
+	}
+
+	if elapsed > c.target {
+		log.Warn("configured password hash params are slower than target", slog.Duration("hash_duration", elapsed))
+	}
+
+	params, err := c.paramsStorage.SaveHashParams(ctx, models.HashParams{
+		Algorithm:    base.Algorithm(),
+		Params:       scheme.Params(),
+		BaseParams:   base.Params(),
+		Target:       c.target,
+		HashDuration: elapsed,
+		CalibratedAt: time.Now(),
+	}, replace)
+
+	if err != nil {
+		return models.HashParams{}, err
+	}
+
+	log.Info("password hashing calibrated",
+		slog.String("params", params.Params),
+		slog.Duration("hash_duration", params.HashDuration),
+	)
+
+	return params, nil
+}
+
+func (c *Calibrator) scheme(op string, params models.HashParams) (password.Scheme, models.HashParams, error) {
+	scheme, err := password.ParseScheme(params.Algorithm, params.Params)
+
+	if err != nil {
+		return nil, models.HashParams{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return scheme, params, nil
+}
@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/userimport"
+)
+
+// Importer переносит пользователей из других систем с их хэшами паролей. Хэши проверяются при первом входе
+// и пересчитываются настроенным алгоритмом, поэтому сбрасывать пароли не нужно
+type Importer struct {
+	log         *slog.Logger
+	userStorage UserStorage
+	hashes      HashChecker
+}
+
+type UserStorage interface {
+	ImportUsers(ctx context.Context, users []models.ImportedUser) (created []bool, err error)
+}
+
+// HashChecker знает, хэши каких алгоритмов сервис умеет проверять
+type HashChecker interface {
+	Supports(hash []byte) bool
+}
+
+func New(log *slog.Logger, userStorage UserStorage, hashes HashChecker) *Importer {
+	return &Importer{log: log, userStorage: userStorage, hashes: hashes}
+}
+
+// Import читает пользователей из r в формате userimport.FormatCSV или userimport.FormatJSONL и сохраняет их.
+// Строки без email или с хэшем неизвестного формата не сохраняются и попадают в Failures
+func (i *Importer) Import(ctx context.Context, r io.Reader, format string) (models.ImportResult, error) {
+	const op = "importer.Import"
+
+	log := i.log.With(
+		slog.String("op", op),
+		slog.String("format", format),
+	)
+
+	users, failures, err := userimport.Parse(r, format)
+
+	if err != nil {
+		log.Warn("failed to parse import", sl.Err(err))
+		return models.ImportResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	valid := make([]models.ImportedUser, 0, len(users))
+
+	for _, user := range users {
+		switch {
+		case user.Email == "":
+			failures = append(failures, models.ImportFailure{Line: user.Line, Reason: "email is required"})
+		case !i.hashes.Supports(user.PassHash):
+			failures = append(failures, models.ImportFailure{Line: user.Line, Email: user.Email, Reason: "unsupported password hash"})
+		default:
+			valid = append(valid, user)
+		}
+	}
+
+	var result models.ImportResult
+
+	if len(valid) > 0 {
+		created, err := i.userStorage.ImportUsers(ctx, valid)
+
+		if err != nil {
+			log.Error("failed to import users", sl.Err(err))
+			return models.ImportResult{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, c := range created {
+			if c {
+				result.Imported++
+			} else {
+				result.Existing++
+			}
+		}
+	}
+
+	slices.SortFunc(failures, func(a, b models.ImportFailure) int { return a.Line - b.Line })
+	result.Failures = failures
+
+	log.Info("users imported",
+		slog.Int("imported", result.Imported),
+		slog.Int("existing", result.Existing),
+		slog.Int("failed", len(result.Failures)),
+	)
+
+	return result, nil
+}
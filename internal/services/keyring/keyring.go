@@ -0,0 +1,218 @@
+package keyring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwe"
+	"sso/internal/lib/jwks"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/logger/sl"
+	"sso/internal/lib/paseto"
+	"time"
+)
+
+// KeyRing управляет связками ключей подписи приложений и их ключами шифрования токенов
+type KeyRing struct {
+	log         *slog.Logger
+	appProvider AppProvider
+	keyStorage  KeyStorage
+}
+
+type AppProvider interface {
+	App(ctx context.Context, appId int) (models.App, error)
+}
+
+type KeyStorage interface {
+	AppKeys(ctx context.Context, appId int) ([]models.AppKey, error)
+	SaveAppKey(ctx context.Context, key models.AppKey) (int64, error)
+	RotateAppKeys(
+		ctx context.Context,
+		appId int,
+		promoteId int64,
+		next models.AppKey,
+		retiredUntil time.Time,
+		now time.Time) error
+	SetAppEncryptionKey(ctx context.Context, appId int, jwk []byte) error
+}
+
+var (
+	ErrInvalidGrace          = errors.New("grace period must be positive")
+	ErrEncryptionUnsupported = errors.New("token encryption requires a JWT signing algorithm")
+)
+
+func New(log *slog.Logger, appProvider AppProvider, keyStorage KeyStorage) *KeyRing {
+	return &KeyRing{log: log, appProvider: appProvider, keyStorage: keyStorage}
+}
+
+// Rotate делает следующий ключ активным, а текущий активный принимается при проверке еще grace.
+// Если alg пустой, используется алгоритм приложения, иначе приложение переключается на alg
+func (k *KeyRing) Rotate(ctx context.Context, appID int, alg string, grace time.Duration) error {
+	const op = "keyring.Rotate"
+
+	log := k.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	if grace <= 0 {
+		return fmt.Errorf("%s: %w", op, ErrInvalidGrace)
+	}
+
+	app, err := k.appProvider.App(ctx, appID)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if alg == "" {
+		alg = app.SignAlg
+	}
+
+	if _, err := jwt.SigningMethod(alg); err != nil && !paseto.Supports(alg) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys, err := k.keyStorage.AppKeys(ctx, appID)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	promote, ok := nextKey(keys, alg)
+
+	if !ok {
+		//заранее опубликованного ключа нет: верификаторы увидят новый ключ только после обновления JWKS
+		log.Warn("no pre-published next key, generating one")
+
+		promote, err = newKey(appID, alg, models.KeyStatusNext)
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		promote.ID, err = k.keyStorage.SaveAppKey(ctx, promote)
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	next, err := newKey(appID, alg, models.KeyStatusNext)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+
+	if err := k.keyStorage.RotateAppKeys(ctx, appID, promote.ID, next, now.Add(grace), now); err != nil {
+		log.Error("failed to rotate keys", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("keys rotated", slog.String("kid", promote.Kid), slog.String("next_kid", next.Kid), slog.String("alg", alg))
+
+	return nil
+}
+
+// RotateIfDue ротирует ключи, если активный ключ старше maxAge или его нет
+func (k *KeyRing) RotateIfDue(ctx context.Context, appID int, alg string, maxAge, grace time.Duration) (bool, error) {
+	const op = "keyring.RotateIfDue"
+
+	keys, err := k.keyStorage.AppKeys(ctx, appID)
+
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, key := range keys {
+		if key.Status == models.KeyStatusActive && time.Since(key.CreatedAt) < maxAge {
+			return false, nil
+		}
+	}
+
+	if err := k.Rotate(ctx, appID, alg, grace); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+// SetEncryptionKey регистрирует публичный JWK приложения, на который шифруются его access токены.
+// Пустой jwk отключает шифрование
+func (k *KeyRing) SetEncryptionKey(ctx context.Context, appID int, raw []byte) error {
+	const op = "keyring.SetEncryptionKey"
+
+	log := k.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", appID),
+	)
+
+	if len(raw) == 0 {
+		if err := k.keyStorage.SetAppEncryptionKey(ctx, appID, nil); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("token encryption disabled")
+
+		return nil
+	}
+
+	app, err := k.appProvider.App(ctx, appID)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if paseto.Supports(app.SignAlg) {
+		return fmt.Errorf("%s: %w: app signs with %s", op, ErrEncryptionUnsupported, app.SignAlg)
+	}
+
+	jwk, err := jwe.ParseKey(raw)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if jwk.Kid == "" {
+		if jwk.Kid, err = jwks.Thumbprint(jwk); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	//хранится нормализованный JWK без посторонних полей
+	normalized, err := json.Marshal(jwk)
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := k.keyStorage.SetAppEncryptionKey(ctx, appID, normalized); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("token encryption key registered", slog.String("kid", jwk.Kid))
+
+	return nil
+}
+
+// newKey генерирует ключ JWT или PASETO в зависимости от алгоритма
+func newKey(appID int, alg string, status string) (models.AppKey, error) {
+	if paseto.Supports(alg) {
+		return paseto.NewKey(appID, alg, status)
+	}
+	return jwt.NewKey(appID, alg, status)
+}
+
+func nextKey(keys []models.AppKey, alg string) (models.AppKey, bool) {
+	for _, key := range keys {
+		if key.Status == models.KeyStatusNext && key.Alg == alg {
+			return key, true
+		}
+	}
+	return models.AppKey{}, false
+}
@@ -0,0 +1,215 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+const appKeyColumns = "id, app_id, kid, alg, private_key, public_key, status, created_at, expires_at"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAppKey(row scanner) (models.AppKey, error) {
+	var (
+		key       models.AppKey
+		kid       sql.NullString
+		createdAt int64
+		expiresAt sql.NullInt64
+	)
+
+	err := row.Scan(&key.ID, &key.AppID, &kid, &key.Alg, &key.PrivateKey, &key.PublicKey, &key.Status, &createdAt, &expiresAt)
+
+	if err != nil {
+		return models.AppKey{}, err
+	}
+
+	key.Kid = kid.String
+	key.CreatedAt = time.Unix(createdAt, 0)
+
+	if expiresAt.Valid {
+		key.ExpiresAt = time.Unix(expiresAt.Int64, 0)
+	}
+
+	return key, nil
+}
+
+func (s *Storage) queryAppKeys(ctx context.Context, op, query string, args ...any) ([]models.AppKey, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []models.AppKey
+
+	for rows.Next() {
+		key, err := scanAppKey(rows)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return keys, nil
+}
+
+// AppKeys все ключи из связки приложения
+func (s *Storage) AppKeys(ctx context.Context, appId int) ([]models.AppKey, error) {
+	const op = "storage.sqlite.AppKeys"
+
+	return s.queryAppKeys(ctx, op,
+		"SELECT "+appKeyColumns+" FROM app_keys WHERE app_id = ? ORDER BY created_at DESC", appId)
+}
+
+// PublishedAppKeys ключи всех приложений, которые должны быть в JWKS: следующие, активные
+// и выведенные из оборота, у которых еще не закончился grace период
+func (s *Storage) PublishedAppKeys(ctx context.Context, now time.Time) ([]models.AppKey, error) {
+	const op = "storage.sqlite.PublishedAppKeys"
+
+	return s.queryAppKeys(ctx, op,
+		"SELECT "+appKeyColumns+" FROM app_keys WHERE status != ? OR expires_at > ? ORDER BY app_id, created_at DESC",
+		models.KeyStatusRetired, now.Unix())
+}
+
+func (s *Storage) ActiveAppKey(ctx context.Context, appId int) (models.AppKey, error) {
+	const op = "storage.sqlite.ActiveAppKey"
+
+	stmt, err := s.db.Prepare("SELECT " + appKeyColumns + " FROM app_keys WHERE app_id = ? AND status = ?")
+
+	if err != nil {
+		return models.AppKey{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	key, err := scanAppKey(stmt.QueryRowContext(ctx, appId, models.KeyStatusActive))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AppKey{}, fmt.Errorf("%s:%w", op, storage.ErrKeyNotFound)
+		}
+		return models.AppKey{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return key, nil
+}
+
+func (s *Storage) SaveAppKey(ctx context.Context, key models.AppKey) (int64, error) {
+	const op = "storage.sqlite.SaveAppKey"
+
+	id, err := insertAppKey(ctx, s.db, key)
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return id, nil
+}
+
+// RotateAppKeys в одной транзакции выводит активный ключ из оборота до retiredUntil, делает ключ promoteId
+// активным, заменяет остальные следующие ключи новым next и удаляет ключи с истекшим grace периодом.
+// Алгоритм приложения переключается на алгоритм next, чтобы политика и активный ключ не расходились
+func (s *Storage) RotateAppKeys(
+	ctx context.Context,
+	appId int,
+	promoteId int64,
+	next models.AppKey,
+	retiredUntil time.Time,
+	now time.Time) error {
+	const op = "storage.sqlite.RotateAppKeys"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE app_keys SET status = ?, expires_at = ? WHERE app_id = ? AND status = ?",
+		models.KeyStatusRetired, retiredUntil.Unix(), appId, models.KeyStatusActive); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE app_keys SET status = ? WHERE id = ? AND app_id = ? AND status = ?",
+		models.KeyStatusActive, promoteId, appId, models.KeyStatusNext)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	promoted, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if promoted == 0 {
+		return fmt.Errorf("%s:%w", op, storage.ErrKeyNotFound)
+	}
+
+	//ключами next никогда не подписывали, поэтому их можно удалять без grace периода
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM app_keys WHERE app_id = ? AND status = ?",
+		appId, models.KeyStatusNext); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	next.AppID = appId
+	next.Status = models.KeyStatusNext
+
+	if _, err := insertAppKey(ctx, tx, next); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE apps SET sign_alg = ? WHERE id = ?", next.Alg, appId); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM app_keys WHERE app_id = ? AND status = ? AND expires_at <= ?",
+		appId, models.KeyStatusRetired, now.Unix()); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertAppKey(ctx context.Context, db execer, key models.AppKey) (int64, error) {
+	var expiresAt sql.NullInt64
+
+	if !key.ExpiresAt.IsZero() {
+		expiresAt = sql.NullInt64{Int64: key.ExpiresAt.Unix(), Valid: true}
+	}
+
+	res, err := db.ExecContext(ctx,
+		"INSERT INTO app_keys (app_id, kid, alg, private_key, public_key, status, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		key.AppID, key.Kid, key.Alg, key.PrivateKey, key.PublicKey, key.Status, key.CreatedAt.Unix(), expiresAt)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
@@ -0,0 +1,168 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+func (s *Storage) AuthzSchema(ctx context.Context, appId int) (models.AuthzSchema, error) {
+	const op = "storage.sqlite.AuthzSchema"
+
+	var (
+		schema    models.AuthzSchema
+		raw       string
+		updatedAt int64
+	)
+
+	err := s.db.QueryRowContext(ctx,
+		"SELECT app_id, schema, updated_at FROM authz_schemas WHERE app_id = ?", appId,
+	).Scan(&schema.AppID, &raw, &updatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AuthzSchema{}, fmt.Errorf("%s:%w", op, storage.ErrSchemaNotFound)
+		}
+		return models.AuthzSchema{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	schema.Schema = []byte(raw)
+	schema.UpdatedAt = time.Unix(updatedAt, 0)
+
+	return schema, nil
+}
+
+// SaveAuthzSchema создает или заменяет схему приложения. Кортежи отношений, которых нет в новой схеме,
+// остаются в хранилище, но не участвуют в проверках
+func (s *Storage) SaveAuthzSchema(ctx context.Context, schema models.AuthzSchema) error {
+	const op = "storage.sqlite.SaveAuthzSchema"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO authz_schemas (app_id, schema, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (app_id) DO UPDATE SET schema = excluded.schema, updated_at = excluded.updated_at`,
+		schema.AppID, string(schema.Schema), schema.UpdatedAt.Unix())
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// WriteTuples атомарно удаляет и добавляет кортежи приложения. Существующий кортеж не дублируется,
+// удаление отсутствующего не ошибка
+func (s *Storage) WriteTuples(ctx context.Context, appId int, writes, deletes []models.RelationTuple, now time.Time) error {
+	const op = "storage.sqlite.WriteTuples"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, t := range deletes {
+		_, err := tx.ExecContext(ctx,
+			`DELETE FROM relation_tuples WHERE app_id = ? AND namespace = ? AND object_id = ? AND relation = ?
+			AND subject_namespace = ? AND subject_id = ? AND subject_relation = ?`,
+			appId, t.Namespace, t.ObjectID, t.Relation, t.SubjectNamespace, t.SubjectID, t.SubjectRelation)
+
+		if err != nil {
+			return fmt.Errorf("%s:%w", op, err)
+		}
+	}
+
+	for _, t := range writes {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO relation_tuples (app_id, namespace, object_id, relation, subject_namespace, subject_id,
+			subject_relation, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+			appId, t.Namespace, t.ObjectID, t.Relation, t.SubjectNamespace, t.SubjectID, t.SubjectRelation, now.Unix())
+
+		if err != nil {
+			return fmt.Errorf("%s:%w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// RelationTuples кортежи отношения relation объекта namespace:objectId
+func (s *Storage) RelationTuples(
+	ctx context.Context,
+	appId int,
+	namespace, objectId, relation string,
+) ([]models.RelationTuple, error) {
+	const op = "storage.sqlite.RelationTuples"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT namespace, object_id, relation, subject_namespace, subject_id, subject_relation FROM relation_tuples
+		WHERE app_id = ? AND namespace = ? AND object_id = ? AND relation = ?
+		ORDER BY subject_namespace, subject_id, subject_relation`,
+		appId, namespace, objectId, relation)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	var tuples []models.RelationTuple
+
+	for rows.Next() {
+		var t models.RelationTuple
+
+		err := rows.Scan(&t.Namespace, &t.ObjectID, &t.Relation, &t.SubjectNamespace, &t.SubjectID, &t.SubjectRelation)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+
+		tuples = append(tuples, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return tuples, nil
+}
+
+// TupleObjectIDs до limit id объектов пространства имен, у которых есть хотя бы один кортеж,
+// по возрастанию и строго после after
+func (s *Storage) TupleObjectIDs(ctx context.Context, appId int, namespace, after string, limit int) ([]string, error) {
+	const op = "storage.sqlite.TupleObjectIDs"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT DISTINCT object_id FROM relation_tuples WHERE app_id = ? AND namespace = ? AND object_id > ? ORDER BY object_id LIMIT ?",
+		appId, namespace, after, limit)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	var ids []string
+
+	for rows.Next() {
+		var id string
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,213 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// SaveCode сохраняет одноразовый код. Неиспользованные коды пользователя того же назначения удаляются,
+// чтобы действовал только последний отправленный
+func (s *Storage) SaveCode(ctx context.Context, code models.OneTimeCode) error {
+	const op = "storage.sqlite.SaveCode"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM one_time_codes WHERE user_id = ? AND purpose = ? AND used_at IS NULL",
+		code.UserID, code.Purpose)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO one_time_codes (user_id, purpose, app_id, code_hash, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		code.UserID, code.Purpose, sql.NullInt64{Int64: int64(code.AppID), Valid: code.AppID != 0},
+		code.CodeHash, code.CreatedAt.Unix(), code.ExpiresAt.Unix())
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Code(ctx context.Context, purpose string, codeHash []byte) (models.OneTimeCode, error) {
+	const op = "storage.sqlite.Code"
+
+	stmt, err := s.db.Prepare(`SELECT id, user_id, purpose, app_id, code_hash, created_at, expires_at, used_at
+		FROM one_time_codes WHERE purpose = ? AND code_hash = ?`)
+
+	if err != nil {
+		return models.OneTimeCode{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	var (
+		code                 models.OneTimeCode
+		createdAt, expiresAt int64
+		appID, usedAt        sql.NullInt64
+	)
+
+	err = stmt.QueryRowContext(ctx, purpose, codeHash).Scan(
+		&code.ID, &code.UserID, &code.Purpose, &appID, &code.CodeHash, &createdAt, &expiresAt, &usedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OneTimeCode{}, fmt.Errorf("%s:%w", op, storage.ErrTokenNotFound)
+		}
+		return models.OneTimeCode{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	code.AppID = int(appID.Int64)
+	code.CreatedAt = time.Unix(createdAt, 0)
+	code.ExpiresAt = time.Unix(expiresAt, 0)
+
+	if usedAt.Valid {
+		code.UsedAt = time.Unix(usedAt.Int64, 0)
+	}
+
+	return code, nil
+}
+
+// VerifyEmail погашает код codeId и отмечает email пользователя подтвержденным.
+// Если код уже использован (в том числе параллельным запросом), возвращает storage.ErrTokenUsed
+func (s *Storage) VerifyEmail(ctx context.Context, codeId int64, userId int64, now time.Time) error {
+	const op = "storage.sqlite.VerifyEmail"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := useCode(ctx, tx, codeId, now); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET email_verified = TRUE WHERE id = ?", userId); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// ResetPassword погашает код codeId, меняет хэш пароля и завершает все сессии пользователя:
+// отзывает его refresh токены и делает недействительными выпущенные до now access токены.
+// Письмо со сбросом доказывает владение адресом, поэтому email заодно отмечается подтвержденным
+func (s *Storage) ResetPassword(ctx context.Context, codeId int64, userId int64, passHash []byte, now time.Time) error {
+	const op = "storage.sqlite.ResetPassword"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := useCode(ctx, tx, codeId, now); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE users SET pass_hash = ?, email_verified = TRUE WHERE id = ?",
+		passHash, userId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := revokeUserSessions(ctx, tx, userId, now); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// UseCode погашает код, который не дает доступа сам по себе, например MFA challenge.
+// Если код уже использован, возвращает storage.ErrTokenUsed
+func (s *Storage) UseCode(ctx context.Context, codeId int64, now time.Time) error {
+	const op = "storage.sqlite.UseCode"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := useCode(ctx, tx, codeId, now); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredCodes удаляет истекшие одноразовые коды, использованные и нет
+func (s *Storage) DeleteExpiredCodes(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredCodes"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM one_time_codes WHERE expires_at <= ?", now.Unix())
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return deleted, nil
+}
+
+func useCode(ctx context.Context, tx *sql.Tx, codeId int64, now time.Time) error {
+	res, err := tx.ExecContext(ctx,
+		"UPDATE one_time_codes SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		now.Unix(), codeId)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return storage.ErrTokenUsed
+	}
+
+	return nil
+}
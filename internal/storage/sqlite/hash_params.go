@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// HashParams сохраненные калибровкой параметры алгоритма
+func (s *Storage) HashParams(ctx context.Context, algorithm string) (models.HashParams, error) {
+	const op = "storage.sqlite.HashParams"
+
+	params, err := hashParams(ctx, s.db, algorithm)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.HashParams{}, fmt.Errorf("%s:%w", op, storage.ErrHashParamsNotFound)
+		}
+		return models.HashParams{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return params, nil
+}
+
+// SaveHashParams сохраняет параметры калибровки и возвращает сохраненные. Без replace заменяются только
+// параметры, откалиброванные от других base_params: при одновременном старте реплик побеждает первая
+func (s *Storage) SaveHashParams(ctx context.Context, params models.HashParams, replace bool) (models.HashParams, error) {
+	const op = "storage.sqlite.SaveHashParams"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return models.HashParams{}, fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO password_hash_params (algorithm, params, base_params, target_ms, hash_ms, calibrated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (algorithm) DO UPDATE SET params = excluded.params, base_params = excluded.base_params,
+			target_ms = excluded.target_ms, hash_ms = excluded.hash_ms, calibrated_at = excluded.calibrated_at
+		WHERE ? OR password_hash_params.base_params != excluded.base_params`,
+		params.Algorithm, params.Params, params.BaseParams, params.Target.Milliseconds(),
+		params.HashDuration.Milliseconds(), params.CalibratedAt.Unix(), replace)
+
+	if err != nil {
+		return models.HashParams{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	stored, err := hashParams(ctx, tx, params.Algorithm)
+
+	if err != nil {
+		return models.HashParams{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.HashParams{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return stored, nil
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func hashParams(ctx context.Context, db querier, algorithm string) (models.HashParams, error) {
+	var (
+		params                         = models.HashParams{Algorithm: algorithm}
+		targetMs, hashMs, calibratedAt int64
+	)
+
+	err := db.QueryRowContext(ctx,
+		"SELECT params, base_params, target_ms, hash_ms, calibrated_at FROM password_hash_params WHERE algorithm = ?",
+		algorithm).Scan(&params.Params, &params.BaseParams, &targetMs, &hashMs, &calibratedAt)
+
+	if err != nil {
+		return models.HashParams{}, err
+	}
+
+	params.Target = time.Duration(targetMs) * time.Millisecond
+	params.HashDuration = time.Duration(hashMs) * time.Millisecond
+	params.CalibratedAt = time.Unix(calibratedAt, 0)
+
+	return params, nil
+}
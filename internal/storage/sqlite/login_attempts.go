@@ -0,0 +1,126 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"time"
+)
+
+// LoginAttempt счетчик неудач для kind и subject. Если неудач не было, возвращает пустой счетчик без ошибки
+func (s *Storage) LoginAttempt(ctx context.Context, kind, subject string) (models.LoginAttempt, error) {
+	const op = "storage.sqlite.LoginAttempt"
+
+	row := s.db.QueryRowContext(ctx,
+		"SELECT failures, last_failure_at, locked_until FROM login_attempts WHERE kind = ? AND subject = ?",
+		kind, subject)
+
+	attempt, err := scanLoginAttempt(row, kind, subject)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.LoginAttempt{Kind: kind, Subject: subject}, nil
+		}
+		return models.LoginAttempt{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return attempt, nil
+}
+
+// RecordLoginFailure атомарно увеличивает счетчик неудач. Если последняя неудача была раньше windowStart,
+// счет начинается заново. Возвращает счетчик после увеличения
+func (s *Storage) RecordLoginFailure(ctx context.Context, kind, subject string, now, windowStart time.Time) (models.LoginAttempt, error) {
+	const op = "storage.sqlite.RecordLoginFailure"
+
+	row := s.db.QueryRowContext(ctx,
+		`INSERT INTO login_attempts (kind, subject, failures, last_failure_at)
+		VALUES (?, ?, 1, ?)
+		ON CONFLICT (kind, subject) DO UPDATE SET
+			failures = CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END,
+			last_failure_at = excluded.last_failure_at
+		RETURNING failures, last_failure_at, locked_until`,
+		kind, subject, now.Unix(), windowStart.Unix())
+
+	attempt, err := scanLoginAttempt(row, kind, subject)
+
+	if err != nil {
+		return models.LoginAttempt{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return attempt, nil
+}
+
+// LockLogin блокирует вход для kind и subject до until
+func (s *Storage) LockLogin(ctx context.Context, kind, subject string, until time.Time) error {
+	const op = "storage.sqlite.LockLogin"
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE login_attempts SET locked_until = ? WHERE kind = ? AND subject = ?",
+		until.Unix(), kind, subject)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// ResetLoginAttempts сбрасывает счетчик неудач и блокировку. Возвращает false, если счетчика не было
+func (s *Storage) ResetLoginAttempts(ctx context.Context, kind, subject string) (bool, error) {
+	const op = "storage.sqlite.ResetLoginAttempts"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM login_attempts WHERE kind = ? AND subject = ?", kind, subject)
+
+	if err != nil {
+		return false, fmt.Errorf("%s:%w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+
+	if err != nil {
+		return false, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return deleted > 0, nil
+}
+
+// DeleteStaleLoginAttempts чистит счетчики без неудач с before и без действующей блокировки
+func (s *Storage) DeleteStaleLoginAttempts(ctx context.Context, before, now time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteStaleLoginAttempts"
+
+	res, err := s.db.ExecContext(ctx,
+		"DELETE FROM login_attempts WHERE last_failure_at < ? AND locked_until <= ?",
+		before.Unix(), now.Unix())
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return deleted, nil
+}
+
+func scanLoginAttempt(row scanner, kind, subject string) (models.LoginAttempt, error) {
+	var lastFailureAt, lockedUntil int64
+
+	attempt := models.LoginAttempt{Kind: kind, Subject: subject}
+
+	if err := row.Scan(&attempt.Failures, &lastFailureAt, &lockedUntil); err != nil {
+		return models.LoginAttempt{}, err
+	}
+
+	attempt.LastFailureAt = time.Unix(lastFailureAt, 0)
+
+	if lockedUntil > 0 {
+		attempt.LockedUntil = time.Unix(lockedUntil, 0)
+	}
+
+	return attempt, nil
+}
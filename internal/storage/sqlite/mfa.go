@@ -0,0 +1,168 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// SetTOTPSecret сохраняет секрет неподтвержденного enrollment. Второй фактор включается только EnableTOTP
+func (s *Storage) SetTOTPSecret(ctx context.Context, userId int64, secret []byte) error {
+	const op = "storage.sqlite.SetTOTPSecret"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET totp_secret = ?, totp_enabled = FALSE, totp_last_step = 0 WHERE id = ? AND NOT totp_enabled",
+		secret, userId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s:%w", op, storage.ErrMFAEnabled)
+	}
+
+	return nil
+}
+
+// EnableTOTP включает второй фактор, запоминает шаг кода подтверждения и заменяет коды восстановления.
+// Если второй фактор уже включен или enrollment не начат, возвращает storage.ErrMFAEnabled
+func (s *Storage) EnableTOTP(ctx context.Context, userId int64, step int64, codes []models.RecoveryCode) error {
+	const op = "storage.sqlite.EnableTOTP"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE users SET totp_enabled = TRUE, totp_last_step = ?
+		WHERE id = ? AND totp_secret IS NOT NULL AND NOT totp_enabled`,
+		step, userId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s:%w", op, storage.ErrMFAEnabled)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userId); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	for _, code := range codes {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)",
+			userId, code.CodeHash, code.CreatedAt.Unix())
+
+		if err != nil {
+			return fmt.Errorf("%s:%w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// DisableTOTP выключает второй фактор и удаляет секрет и коды восстановления
+func (s *Storage) DisableTOTP(ctx context.Context, userId int64) error {
+	const op = "storage.sqlite.DisableTOTP"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0 WHERE id = ?",
+		userId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userId); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep запоминает принятый шаг TOTP. Если этот или более поздний шаг уже принят
+// (в том числе параллельным запросом), возвращает storage.ErrTokenUsed
+func (s *Storage) UseTOTPStep(ctx context.Context, userId int64, step int64) error {
+	const op = "storage.sqlite.UseTOTPStep"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?",
+		step, userId, step)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s:%w", op, storage.ErrTokenUsed)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode погашает код восстановления пользователя. Неизвестный
+// или уже использованный код - storage.ErrTokenNotFound
+func (s *Storage) UseRecoveryCode(ctx context.Context, userId int64, codeHash []byte, now time.Time) error {
+	const op = "storage.sqlite.UseRecoveryCode"
+
+	res, err := s.db.ExecContext(ctx,
+		"UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		now.Unix(), userId, codeHash)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s:%w", op, storage.ErrTokenNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+// SavePasskey сохраняет зарегистрированный ключ. Ключ с тем же credential id - storage.ErrPasskeyExists
+func (s *Storage) SavePasskey(ctx context.Context, key models.Passkey) (int64, error) {
+	const op = "storage.sqlite.SavePasskey"
+
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO passkeys (user_id, credential_id, public_key, attestation_type, aaguid, sign_count, transports,
+			backup_eligible, backup_state, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		key.UserID, key.CredentialID, key.PublicKey, key.AttestationType, key.AAGUID, key.SignCount,
+		strings.Join(key.Transports, " "), key.BackupEligible, key.BackupState, key.CreatedAt.Unix())
+
+	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+			return 0, fmt.Errorf("%s:%w", op, storage.ErrPasskeyExists)
+		}
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return id, nil
+}
+
+// Passkeys ключи пользователя в порядке регистрации
+func (s *Storage) Passkeys(ctx context.Context, userId int64) ([]models.Passkey, error) {
+	const op = "storage.sqlite.Passkeys"
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, user_id, credential_id, public_key, attestation_type, aaguid, sign_count, transports,
+			backup_eligible, backup_state, created_at, last_used_at
+		FROM passkeys WHERE user_id = ? ORDER BY id`, userId)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []models.Passkey
+
+	for rows.Next() {
+		var (
+			key        models.Passkey
+			transports string
+			createdAt  int64
+			lastUsedAt sql.NullInt64
+		)
+
+		err := rows.Scan(&key.ID, &key.UserID, &key.CredentialID, &key.PublicKey, &key.AttestationType, &key.AAGUID,
+			&key.SignCount, &transports, &key.BackupEligible, &key.BackupState, &createdAt, &lastUsedAt)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+
+		key.Transports = strings.Fields(transports)
+		key.CreatedAt = time.Unix(createdAt, 0)
+
+		if lastUsedAt.Valid {
+			key.LastUsedAt = time.Unix(lastUsedAt.Int64, 0)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return keys, nil
+}
+
+// UsePasskey запоминает счетчик подписей и флаг резервной копии после входа. Если счетчик уже не меньше
+// signCount (ответ предъявлен повторно, в том числе параллельным запросом), возвращает storage.ErrTokenUsed.
+// Нулевой счетчик означает, что аутентификатор его не ведет, и не проверяется
+func (s *Storage) UsePasskey(ctx context.Context, id int64, signCount uint32, backupState bool, now time.Time) error {
+	const op = "storage.sqlite.UsePasskey"
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE passkeys SET sign_count = ?, backup_state = ?, last_used_at = ?
+		WHERE id = ? AND (sign_count < ? OR ? = 0)`,
+		signCount, backupState, now.Unix(), id, signCount, signCount)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s:%w", op, storage.ErrTokenUsed)
+	}
+
+	return nil
+}
+
+func (s *Storage) SavePasskeyCeremony(ctx context.Context, ceremony models.PasskeyCeremony) error {
+	const op = "storage.sqlite.SavePasskeyCeremony"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO passkey_ceremonies (session_hash, purpose, user_id, app_id, session_data, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		ceremony.SessionHash, ceremony.Purpose,
+		sql.NullInt64{Int64: ceremony.UserID, Valid: ceremony.UserID != 0},
+		sql.NullInt64{Int64: int64(ceremony.AppID), Valid: ceremony.AppID != 0},
+		ceremony.SessionData, ceremony.CreatedAt.Unix(), ceremony.ExpiresAt.Unix())
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// UsePasskeyCeremony достает и сразу удаляет церемонию, чтобы ее challenge нельзя было предъявить дважды.
+// Неизвестная или уже использованная церемония - storage.ErrTokenNotFound; срок проверяет вызывающий
+func (s *Storage) UsePasskeyCeremony(ctx context.Context, purpose string, sessionHash []byte) (models.PasskeyCeremony, error) {
+	const op = "storage.sqlite.UsePasskeyCeremony"
+
+	var (
+		ceremony             models.PasskeyCeremony
+		userID, appID        sql.NullInt64
+		createdAt, expiresAt int64
+	)
+
+	err := s.db.QueryRowContext(ctx,
+		`DELETE FROM passkey_ceremonies WHERE purpose = ? AND session_hash = ?
+		RETURNING id, session_hash, purpose, user_id, app_id, session_data, created_at, expires_at`,
+		purpose, sessionHash).Scan(
+		&ceremony.ID, &ceremony.SessionHash, &ceremony.Purpose, &userID, &appID, &ceremony.SessionData,
+		&createdAt, &expiresAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PasskeyCeremony{}, fmt.Errorf("%s:%w", op, storage.ErrTokenNotFound)
+		}
+		return models.PasskeyCeremony{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	ceremony.UserID = userID.Int64
+	ceremony.AppID = int(appID.Int64)
+	ceremony.CreatedAt = time.Unix(createdAt, 0)
+	ceremony.ExpiresAt = time.Unix(expiresAt, 0)
+
+	return ceremony, nil
+}
+
+// DeleteExpiredPasskeyCeremonies чистит брошенные церемонии с истекшим сроком
+func (s *Storage) DeleteExpiredPasskeyCeremonies(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredPasskeyCeremonies"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM passkey_ceremonies WHERE expires_at <= ?", now.Unix())
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return deleted, nil
+}
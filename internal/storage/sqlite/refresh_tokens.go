@@ -0,0 +1,196 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"strings"
+	"time"
+)
+
+func (s *Storage) SaveRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	const op = "storage.sqlite.SaveRefreshToken"
+
+	if err := insertRefreshToken(ctx, s.db, token); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RefreshToken(ctx context.Context, tokenHash []byte) (models.RefreshToken, error) {
+	const op = "storage.sqlite.RefreshToken"
+
+	stmt, err := s.db.Prepare(`SELECT id, token_hash, family_id, user_id, app_id, amr, created_at, expires_at, used_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = ?`)
+
+	if err != nil {
+		return models.RefreshToken{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	var (
+		token                models.RefreshToken
+		amr                  string
+		createdAt, expiresAt int64
+		usedAt, revokedAt    sql.NullInt64
+	)
+
+	err = stmt.QueryRowContext(ctx, tokenHash).Scan(
+		&token.ID, &token.TokenHash, &token.FamilyID, &token.UserID, &token.AppID, &amr,
+		&createdAt, &expiresAt, &usedAt, &revokedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RefreshToken{}, fmt.Errorf("%s:%w", op, storage.ErrTokenNotFound)
+		}
+		return models.RefreshToken{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	token.AMR = strings.Fields(amr)
+	token.CreatedAt = time.Unix(createdAt, 0)
+	token.ExpiresAt = time.Unix(expiresAt, 0)
+
+	if usedAt.Valid {
+		token.UsedAt = time.Unix(usedAt.Int64, 0)
+	}
+
+	if revokedAt.Valid {
+		token.RevokedAt = time.Unix(revokedAt.Int64, 0)
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken помечает токен usedId использованным и сохраняет следующий токен семейства.
+// Если токен уже был использован (в том числе параллельным запросом), возвращает storage.ErrTokenUsed
+func (s *Storage) RotateRefreshToken(ctx context.Context, usedId int64, next models.RefreshToken, now time.Time) error {
+	const op = "storage.sqlite.RotateRefreshToken"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL",
+		now.Unix(), usedId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s:%w", op, storage.ErrTokenUsed)
+	}
+
+	if err := insertRefreshToken(ctx, tx, next); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeRefreshTokenFamily отзывает все токены семейства и сессию, которой оно принадлежит
+func (s *Storage) RevokeRefreshTokenFamily(ctx context.Context, familyId string, now time.Time) error {
+	const op = "storage.sqlite.RevokeRefreshTokenFamily"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", now.Unix(), familyId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", now.Unix(), familyId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredRefreshTokens удаляет семейства refresh токенов, все токены которых истекли. Использованные
+// токены живого семейства остаются: их повторное предъявление отзывает семейство
+func (s *Storage) DeleteExpiredRefreshTokens(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredRefreshTokens"
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM refresh_tokens WHERE family_id IN
+		(SELECT family_id FROM refresh_tokens GROUP BY family_id HAVING MAX(expires_at) <= ?)`,
+		now.Unix())
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return deleted, nil
+}
+
+func insertRefreshToken(ctx context.Context, db execer, token models.RefreshToken) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (token_hash, family_id, user_id, app_id, amr, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		token.TokenHash, token.FamilyID, token.UserID, token.AppID, strings.Join(token.AMR, " "),
+		token.CreatedAt.Unix(), token.ExpiresAt.Unix())
+
+	return err
+}
+
+// revokeUserSessions завершает все сессии пользователя во всех приложениях: отзывает сессии и refresh токены
+// и делает недействительными access токены, выпущенные раньше now
+func revokeUserSessions(ctx context.Context, db execer, userId int64, now time.Time) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+		now.Unix(), userId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+		now.Unix(), userId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, "UPDATE users SET tokens_valid_after = ? WHERE id = ?", now.Unix(), userId)
+
+	return err
+}
@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RevokeToken вносит jti в denylist до expiresAt: после истечения токен и так невалиден
+func (s *Storage) RevokeToken(ctx context.Context, jti string, expiresAt time.Time, now time.Time) error {
+	const op = "storage.sqlite.RevokeToken"
+
+	stmt, err := s.db.Prepare("INSERT INTO revoked_tokens (jti, revoked_at, expires_at) VALUES (?, ?, ?) ON CONFLICT (jti) DO NOTHING")
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if _, err := stmt.ExecContext(ctx, jti, now.Unix(), expiresAt.Unix()); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	const op = "storage.sqlite.IsTokenRevoked"
+
+	stmt, err := s.db.Prepare("SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = ?)")
+
+	if err != nil {
+		return false, fmt.Errorf("%s:%w", op, err)
+	}
+
+	var revoked bool
+
+	if err := stmt.QueryRowContext(ctx, jti).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return revoked, nil
+}
+
+// DeleteExpiredRevokedTokens чистит denylist от токенов, срок которых уже истек
+func (s *Storage) DeleteExpiredRevokedTokens(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredRevokedTokens"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= ?", now.Unix())
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return deleted, nil
+}
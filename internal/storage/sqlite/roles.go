@@ -0,0 +1,179 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+// CreateRole создает роль вместе с правами. Имя уникально среди глобальных ролей и среди ролей одного приложения
+func (s *Storage) CreateRole(ctx context.Context, role models.Role) (int64, error) {
+	const op = "storage.sqlite.CreateRole"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"INSERT INTO roles (name, app_id, description, created_at) VALUES (?, ?, ?, ?)",
+		role.Name, nullAppID(role.AppID), role.Description, role.CreatedAt.Unix())
+
+	if err != nil {
+		var sqliteErr sqlite3.Error
+
+		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
+			return 0, fmt.Errorf("%s:%w", op, storage.ErrRoleExists)
+		}
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	for _, permission := range role.Permissions {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO role_permissions (role_id, permission) VALUES (?, ?) ON CONFLICT DO NOTHING", id, permission)
+
+		if err != nil {
+			return 0, fmt.Errorf("%s:%w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return id, nil
+}
+
+// GrantRole выдает роль пользователю; повторная выдача не ошибка
+func (s *Storage) GrantRole(ctx context.Context, userId int64, roleId int64, now time.Time) error {
+	const op = "storage.sqlite.GrantRole"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	//внешние ключи в sqlite выключены, поэтому существование проверяется явно
+	for _, check := range []struct {
+		query string
+		id    int64
+		err   error
+	}{
+		{"SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)", userId, storage.ErrUserNotFound},
+		{"SELECT EXISTS (SELECT 1 FROM roles WHERE id = ?)", roleId, storage.ErrRoleNotFound},
+	} {
+		var exists bool
+
+		if err := tx.QueryRowContext(ctx, check.query, check.id).Scan(&exists); err != nil {
+			return fmt.Errorf("%s:%w", op, err)
+		}
+
+		if !exists {
+			return fmt.Errorf("%s:%w", op, check.err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO user_roles (user_id, role_id, granted_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+		userId, roleId, now.Unix())
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeRole(ctx context.Context, userId int64, roleId int64) error {
+	const op = "storage.sqlite.RevokeRole"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = ? AND role_id = ?", userId, roleId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s:%w", op, storage.ErrRoleNotGranted)
+	}
+
+	return nil
+}
+
+// UserRoles роли пользователя с правами: сначала глобальные, затем по приложениям
+func (s *Storage) UserRoles(ctx context.Context, userId int64) ([]models.Role, error) {
+	const op = "storage.sqlite.UserRoles"
+
+	rows, err := s.db.QueryContext(ctx, `SELECT roles.id, roles.name, roles.app_id, roles.description, roles.created_at,
+		(SELECT json_group_array(permission) FROM
+			(SELECT permission FROM role_permissions WHERE role_id = roles.id ORDER BY permission))
+		FROM roles JOIN user_roles ON user_roles.role_id = roles.id
+		WHERE user_roles.user_id = ?
+		ORDER BY COALESCE(roles.app_id, 0), roles.name`, userId)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	var roles []models.Role
+
+	for rows.Next() {
+		var (
+			role        models.Role
+			appID       sql.NullInt64
+			createdAt   int64
+			permissions string
+		)
+
+		if err := rows.Scan(&role.ID, &role.Name, &appID, &role.Description, &createdAt, &permissions); err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+
+		if err := json.Unmarshal([]byte(permissions), &role.Permissions); err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+
+		role.AppID = int(appID.Int64)
+		role.CreatedAt = time.Unix(createdAt, 0)
+
+		roles = append(roles, role)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return roles, nil
+}
+
+// nullAppID глобальная роль хранится с app_id NULL
+func nullAppID(appID int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(appID), Valid: appID != 0}
+}
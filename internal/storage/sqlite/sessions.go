@@ -0,0 +1,228 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sso/internal/domain/models"
+	"sso/internal/storage"
+	"time"
+)
+
+const sessionColumns = "id, user_id, app_id, client_ip, user_agent, created_at, last_used_at, expires_at, revoked_at"
+
+// SaveSession создает сессию при входе. У существующей сессии обновляются только время использования и срок:
+// адрес и клиент остаются от входа, а отозванная сессия не восстанавливается
+func (s *Storage) SaveSession(ctx context.Context, session models.Session) error {
+	const op = "storage.sqlite.SaveSession"
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO sessions (id, user_id, app_id, client_ip, user_agent, created_at, last_used_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET last_used_at = excluded.last_used_at, expires_at = excluded.expires_at`,
+		session.ID, session.UserID, session.AppID, session.ClientIP, session.UserAgent,
+		session.CreatedAt.Unix(), session.LastUsedAt.Unix(), session.ExpiresAt.Unix())
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Session(ctx context.Context, id string) (models.Session, error) {
+	const op = "storage.sqlite.Session"
+
+	row := s.db.QueryRowContext(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id)
+
+	session, err := scanSession(row)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Session{}, fmt.Errorf("%s:%w", op, storage.ErrSessionNotFound)
+		}
+		return models.Session{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return session, nil
+}
+
+// ActiveSessions неотозванные и неистекшие сессии пользователя, последние использованные первыми
+func (s *Storage) ActiveSessions(ctx context.Context, userId int64, now time.Time) ([]models.Session, error) {
+	const op = "storage.sqlite.ActiveSessions"
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+sessionColumns+` FROM sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+		ORDER BY last_used_at DESC, created_at DESC`, userId, now.Unix())
+
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+
+	for rows.Next() {
+		session, err := scanSession(rows)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession отзывает активную сессию пользователя вместе с ее refresh токенами. Чужая, отозванная
+// или несуществующая сессия - storage.ErrSessionNotFound
+func (s *Storage) RevokeSession(ctx context.Context, id string, userId int64, now time.Time) error {
+	const op = "storage.sqlite.RevokeSession"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		now.Unix(), id, userId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s:%w", op, storage.ErrSessionNotFound)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", now.Unix(), id)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions отзывает все сессии пользователя, кроме keepId, вместе с их refresh токенами
+func (s *Storage) RevokeOtherSessions(ctx context.Context, userId int64, keepId string, now time.Time) error {
+	const op = "storage.sqlite.RevokeOtherSessions"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND id != ? AND revoked_at IS NULL",
+		now.Unix(), userId, keepId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND family_id != ? AND revoked_at IS NULL",
+		now.Unix(), userId, keepId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeAllSessions завершает все сессии пользователя так же, как сброс пароля, включая access токены
+// без sid, выпущенные раньше now
+func (s *Storage) RevokeAllSessions(ctx context.Context, userId int64, now time.Time) error {
+	const op = "storage.sqlite.RevokeAllSessions"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := revokeUserSessions(ctx, tx, userId, now); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredSessions удаляет сессии, refresh токены которых истекли: их access токены к этому времени
+// тоже истекли
+func (s *Storage) DeleteExpiredSessions(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredSessions"
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM sessions WHERE expires_at <= ?", now.Unix())
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+
+	if err != nil {
+		return 0, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return deleted, nil
+}
+
+func scanSession(row scanner) (models.Session, error) {
+	var (
+		session                          models.Session
+		createdAt, lastUsedAt, expiresAt int64
+		revokedAt                        sql.NullInt64
+	)
+
+	err := row.Scan(&session.ID, &session.UserID, &session.AppID, &session.ClientIP, &session.UserAgent,
+		&createdAt, &lastUsedAt, &expiresAt, &revokedAt)
+
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	session.CreatedAt = time.Unix(createdAt, 0)
+	session.LastUsedAt = time.Unix(lastUsedAt, 0)
+	session.ExpiresAt = time.Unix(expiresAt, 0)
+
+	if revokedAt.Valid {
+		session.RevokedAt = time.Unix(revokedAt.Int64, 0)
+	}
+
+	return session, nil
+}
@@ -3,11 +3,14 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/mattn/go-sqlite3"
 	"sso/internal/domain/models"
 	"sso/internal/storage"
+	"strings"
+	"time"
 )
 
 type Storage struct {
@@ -17,8 +20,15 @@ type Storage struct {
 func New(storagePath string) (*Storage, error) {
 	const op = "storage.sqlite.New"
 
+	//транзакции сразу берут блокировку записи: отложенная транзакция, начавшая с чтения, при конкурентной
+	//записи получает "database is locked" без ожидания busy timeout
+	sep := "?"
+	if strings.Contains(storagePath, "?") {
+		sep = "&"
+	}
+
 	//указываем путь до файла БД
-	db, err := sql.Open("sqlite3", storagePath)
+	db, err := sql.Open("sqlite3", storagePath+sep+"_txlock=immediate")
 
 	if err != nil {
 		return nil, fmt.Errorf("%s:%w", op, err)
@@ -57,20 +67,143 @@ func (s *Storage) SaveUser(ctx context.Context, email string, hashPass []byte) (
 	return id, nil
 }
 
+// ImportUsers сохраняет пользователей одной транзакцией, уже зарегистрированные email пропускаются.
+// created[i] - пользователь users[i] добавлен
+func (s *Storage) ImportUsers(ctx context.Context, users []models.ImportedUser) ([]bool, error) {
+	const op = "storage.sqlite.ImportUsers"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx,
+		"INSERT INTO users(email, pass_hash, email_verified) VALUES (?, ?, ?) ON CONFLICT(email) DO NOTHING")
+
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+	defer stmt.Close()
+
+	created := make([]bool, len(users))
+
+	for i, user := range users {
+		res, err := stmt.ExecContext(ctx, user.Email, user.PassHash, user.EmailVerified)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+
+		affected, err := res.RowsAffected()
+
+		if err != nil {
+			return nil, fmt.Errorf("%s:%w", op, err)
+		}
+
+		created[i] = affected > 0
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s:%w", op, err)
+	}
+
+	return created, nil
+}
+
+// UpdatePassword меняет хэш пароля пользователя. При revokeSessions все сессии пользователя завершаются
+// так же, как при сбросе пароля
+func (s *Storage) UpdatePassword(ctx context.Context, userId int64, passHash []byte, now time.Time, revokeSessions bool) error {
+	const op = "storage.sqlite.UpdatePassword"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, "UPDATE users SET pass_hash = ? WHERE id = ?", passHash, userId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s:%w", op, storage.ErrUserNotFound)
+	}
+
+	if revokeSessions {
+		if err := revokeUserSessions(ctx, tx, userId, now); err != nil {
+			return fmt.Errorf("%s:%w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+// RehashPassword заменяет хэш пароля, только если он не изменился с момента проверки: смена пароля
+// между входом и пересчетом не должна перезаписываться старым паролем
+func (s *Storage) RehashPassword(ctx context.Context, userId int64, oldHash []byte, newHash []byte) error {
+	const op = "storage.sqlite.RehashPassword"
+
+	_, err := s.db.ExecContext(ctx, "UPDATE users SET pass_hash = ? WHERE id = ? AND pass_hash = ?", newHash, userId, oldHash)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	return nil
+}
+
+const userColumns = "id, email, pass_hash, " + isAdminColumn + ", email_verified, tokens_valid_after, totp_secret, totp_enabled, totp_last_step"
+
+// isAdminColumn флаг администратора из глобальной роли admin, на которую заменена колонка is_admin
+const isAdminColumn = `EXISTS (SELECT 1 FROM user_roles JOIN roles ON roles.id = user_roles.role_id
+	WHERE user_roles.user_id = users.id AND roles.app_id IS NULL AND roles.name = '` + models.RoleAdmin + `')`
+
 func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	const op = "storage.sqlite.User"
 
-	stmt, err := s.db.Prepare("SELECT id, email, pass_hash FROM users WHERE email = ?")
+	stmt, err := s.db.Prepare("SELECT " + userColumns + " FROM users WHERE email = ?")
+
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s:%w", op, err)
+	}
+
+	user, err := scanUser(stmt.QueryRowContext(ctx, email))
 
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s:%w", op, storage.ErrUserNotFound)
+		}
 		return models.User{}, fmt.Errorf("%s:%w", op, err)
 	}
 
-	row := stmt.QueryRowContext(ctx, email)
+	return user, nil
+}
+
+func (s *Storage) UserByID(ctx context.Context, id int64) (models.User, error) {
+	const op = "storage.sqlite.UserByID"
+
+	stmt, err := s.db.Prepare("SELECT " + userColumns + " FROM users WHERE id = ?")
 
-	var user models.User
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s:%w", op, err)
+	}
 
-	err = row.Scan(&user.ID, &user.Email, &user.PassHash)
+	user, err := scanUser(stmt.QueryRowContext(ctx, id))
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -82,10 +215,32 @@ func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	return user, nil
 }
 
+func scanUser(row scanner) (models.User, error) {
+	var (
+		user             models.User
+		tokensValidAfter int64
+	)
+
+	err := row.Scan(
+		&user.ID, &user.Email, &user.PassHash, &user.IsAdmin, &user.EmailVerified, &tokensValidAfter,
+		&user.TOTPSecret, &user.TOTPEnabled, &user.TOTPLastStep,
+	)
+
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if tokensValidAfter > 0 {
+		user.TokensValidAfter = time.Unix(tokensValidAfter, 0)
+	}
+
+	return user, nil
+}
+
 func (s *Storage) IsAdmin(ctx context.Context, id int64) (bool, error) {
 	const op = "storage.sqlite.IsAdmin"
 
-	stmt, err := s.db.Prepare("SELECT is_admin FROM users WHERE id = ?")
+	stmt, err := s.db.Prepare("SELECT " + isAdminColumn + " FROM users WHERE id = ?")
 
 	if err != nil {
 		return false, fmt.Errorf("%s:%w", op, err)
@@ -99,7 +254,7 @@ func (s *Storage) IsAdmin(ctx context.Context, id int64) (bool, error) {
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return false, fmt.Errorf("%s:%w", op, storage.ErrAppNotFound)
+			return false, fmt.Errorf("%s:%w", op, storage.ErrUserNotFound)
 		}
 		return false, fmt.Errorf("%s:%w", op, err)
 	}
@@ -108,19 +263,25 @@ func (s *Storage) IsAdmin(ctx context.Context, id int64) (bool, error) {
 }
 
 func (s *Storage) App(ctx context.Context, appId int) (models.App, error) {
-	const op = "storage.sqlite.New"
+	const op = "storage.sqlite.App"
 
-	stmt, err := s.db.Prepare("SELECT id, name, secret FROM apps WHERE id = ?")
+	stmt, err := s.db.Prepare(`SELECT id, name, secret, sign_alg, access_token_ttl, refresh_token_ttl, claims_template,
+		encryption_key, require_verified_email FROM apps WHERE id = ?`)
 
 	if err != nil {
 		return models.App{}, fmt.Errorf("%s:%w", op, err)
 	}
 
-	var app models.App
+	var (
+		app                             models.App
+		accessTokenTTL, refreshTokenTTL sql.NullInt64
+		claimsTemplate, encryptionKey   sql.NullString
+	)
 
 	row := stmt.QueryRowContext(ctx, appId)
 
-	err = row.Scan(&app.ID, &app.Name, &app.Secret)
+	err = row.Scan(&app.ID, &app.Name, &app.Secret, &app.SignAlg, &accessTokenTTL, &refreshTokenTTL, &claimsTemplate,
+		&encryptionKey, &app.RequireVerifiedEmail)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -129,5 +290,57 @@ func (s *Storage) App(ctx context.Context, appId int) (models.App, error) {
 		return models.App{}, fmt.Errorf("%s:%w", op, err)
 	}
 
+	app.AccessTokenTTL = time.Duration(accessTokenTTL.Int64) * time.Second
+	app.RefreshTokenTTL = time.Duration(refreshTokenTTL.Int64) * time.Second
+
+	if claimsTemplate.Valid {
+		var template models.ClaimsTemplate
+
+		if err := json.Unmarshal([]byte(claimsTemplate.String), &template); err != nil {
+			return models.App{}, fmt.Errorf("%s: claims_template of app %d: %w", op, appId, err)
+		}
+
+		app.ClaimsTemplate = &template
+	}
+
+	if encryptionKey.Valid {
+		app.EncryptionKey = []byte(encryptionKey.String)
+	}
+
 	return app, nil
 }
+
+// SetAppEncryptionKey регистрирует публичный JWK приложения для шифрования токенов; nil отключает шифрование
+func (s *Storage) SetAppEncryptionKey(ctx context.Context, appId int, jwk []byte) error {
+	const op = "storage.sqlite.SetAppEncryptionKey"
+
+	stmt, err := s.db.Prepare("UPDATE apps SET encryption_key = ? WHERE id = ?")
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	var value sql.NullString
+
+	if jwk != nil {
+		value = sql.NullString{String: string(jwk), Valid: true}
+	}
+
+	res, err := stmt.ExecContext(ctx, value, appId)
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return fmt.Errorf("%s:%w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s:%w", op, storage.ErrAppNotFound)
+	}
+
+	return nil
+}
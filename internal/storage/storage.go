@@ -6,4 +6,22 @@ var (
 	ErrUserExists   = errors.New("user already exists")
 	ErrUserNotFound = errors.New("user not found")
 	ErrAppNotFound  = errors.New("app not found")
+	ErrKeyNotFound  = errors.New("key not found")
+
+	ErrTokenNotFound = errors.New("token not found")
+	ErrTokenUsed     = errors.New("token already used")
+
+	ErrMFAEnabled = errors.New("mfa already enabled")
+
+	ErrPasskeyExists = errors.New("passkey already registered")
+
+	ErrHashParamsNotFound = errors.New("password hash params not found")
+
+	ErrSessionNotFound = errors.New("session not found")
+
+	ErrRoleExists     = errors.New("role already exists")
+	ErrRoleNotFound   = errors.New("role not found")
+	ErrRoleNotGranted = errors.New("role is not granted")
+
+	ErrSchemaNotFound = errors.New("authz schema not found")
 )
@@ -0,0 +1,12 @@
+-- счетчики неудачных попыток входа по email и по IP клиента; переживают перезапуск сервера
+CREATE TABLE IF NOT EXISTS login_attempts
+(
+    kind TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at INTEGER NOT NULL,
+    locked_until INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (kind, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_last_failure_at ON login_attempts (last_failure_at);
@@ -0,0 +1,6 @@
+ALTER TABLE refresh_tokens DROP COLUMN amr;
+ALTER TABLE one_time_codes DROP COLUMN app_id;
+DROP TABLE IF EXISTS recovery_codes;
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN totp_enabled;
+ALTER TABLE users DROP COLUMN totp_secret;
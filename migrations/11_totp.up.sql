@@ -0,0 +1,23 @@
+-- TOTP секрет пользователя; до подтверждения enrollment totp_enabled = FALSE.
+-- totp_last_step последний принятый шаг, чтобы код нельзя было предъявить дважды
+ALTER TABLE users ADD COLUMN totp_secret BLOB;
+ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+
+-- одноразовые коды восстановления на случай потери аутентификатора; хранится только sha256 кода
+CREATE TABLE IF NOT EXISTS recovery_codes
+(
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash BLOB NOT NULL UNIQUE,
+    created_at INTEGER NOT NULL,
+    used_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes (user_id);
+
+-- приложение, для которого выдан код; нужно MFA challenge, чтобы выпустить токены после второго фактора
+ALTER TABLE one_time_codes ADD COLUMN app_id INTEGER;
+
+-- способы аутентификации сессии через пробел, переходят в amr токенов после обмена refresh токена
+ALTER TABLE refresh_tokens ADD COLUMN amr TEXT NOT NULL DEFAULT '';
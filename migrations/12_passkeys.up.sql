@@ -0,0 +1,35 @@
+-- ключи WebAuthn (passkeys) пользователя. Хранится только публичный ключ; sign_count растет с каждым входом,
+-- откат счетчика означает клонированный аутентификатор
+CREATE TABLE IF NOT EXISTS passkeys
+(
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    credential_id BLOB NOT NULL UNIQUE,
+    public_key BLOB NOT NULL,
+    attestation_type TEXT NOT NULL DEFAULT '',
+    aaguid BLOB NOT NULL,
+    sign_count INTEGER NOT NULL DEFAULT 0,
+    transports TEXT NOT NULL DEFAULT '',
+    backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+    backup_state BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at INTEGER NOT NULL,
+    last_used_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_passkeys_user ON passkeys (user_id);
+
+-- незавершенные церемонии регистрации и входа: challenge и параметры, которые проверяются на втором шаге.
+-- Хранится только sha256 идентификатора церемонии; у входа без email пользователь неизвестен до ответа
+CREATE TABLE IF NOT EXISTS passkey_ceremonies
+(
+    id INTEGER PRIMARY KEY,
+    session_hash BLOB NOT NULL UNIQUE,
+    purpose TEXT NOT NULL,
+    user_id INTEGER REFERENCES users (id) ON DELETE CASCADE,
+    app_id INTEGER,
+    session_data BLOB NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_passkey_ceremonies_expires_at ON passkey_ceremonies (expires_at);
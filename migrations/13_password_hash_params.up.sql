@@ -0,0 +1,11 @@
+-- параметры хэширования паролей, подобранные калибровкой под железо; общие для всех реплик.
+-- base_params - параметры конфига, от которых шла калибровка: при их смене результат устаревает
+CREATE TABLE IF NOT EXISTS password_hash_params
+(
+    algorithm TEXT PRIMARY KEY,
+    params TEXT NOT NULL,
+    base_params TEXT NOT NULL,
+    target_ms INTEGER NOT NULL,
+    hash_ms INTEGER NOT NULL,
+    calibrated_at INTEGER NOT NULL
+);
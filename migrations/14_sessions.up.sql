@@ -0,0 +1,18 @@
+-- сессии пользователей: одна на вход, id совпадает с family_id ее refresh токенов и попадает в claim sid
+-- access токенов. expires_at - срок последнего refresh токена; отозванная сессия делает недействительными
+-- и refresh, и access токены
+CREATE TABLE IF NOT EXISTS sessions
+(
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    client_ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,
+    last_used_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    revoked_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
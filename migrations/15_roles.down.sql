@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users SET is_admin = TRUE
+WHERE id IN (SELECT user_roles.user_id FROM user_roles JOIN roles ON roles.id = user_roles.role_id
+             WHERE roles.name = 'admin' AND roles.app_id IS NULL);
+
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,40 @@
+-- роли пользователей: глобальные (app_id NULL) или в пределах одного приложения. Права - произвольные строки,
+-- которые приложения проверяют по claim permissions токена
+CREATE TABLE IF NOT EXISTS roles
+(
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    app_id INTEGER REFERENCES apps (id) ON DELETE CASCADE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_app_name ON roles (COALESCE(app_id, 0), name);
+
+CREATE TABLE IF NOT EXISTS role_permissions
+(
+    role_id INTEGER NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles
+(
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    granted_at INTEGER NOT NULL,
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role ON user_roles (role_id);
+
+-- флаг is_admin заменяет глобальная роль admin
+INSERT INTO roles (name, description, created_at)
+VALUES ('admin', 'sso administrator', CAST(strftime('%s', 'now') AS INTEGER));
+
+INSERT INTO user_roles (user_id, role_id, granted_at)
+SELECT users.id, roles.id, CAST(strftime('%s', 'now') AS INTEGER)
+FROM users, roles
+WHERE users.is_admin AND roles.name = 'admin' AND roles.app_id IS NULL;
+
+ALTER TABLE users DROP COLUMN is_admin;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS relation_tuples;
+DROP TABLE IF EXISTS authz_schemas;
@@ -0,0 +1,22 @@
+-- схема отношений приложения: пространства имен объектов и правила вычисления их отношений
+CREATE TABLE IF NOT EXISTS authz_schemas
+(
+    app_id INTEGER PRIMARY KEY REFERENCES apps (id) ON DELETE CASCADE,
+    schema TEXT NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+-- кортежи отношений namespace:object_id#relation@subject. Пустой subject_relation - субъект сам объект
+-- (user:42), иначе множество субъектов его отношения (group:eng#member)
+CREATE TABLE IF NOT EXISTS relation_tuples
+(
+    app_id INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    namespace TEXT NOT NULL,
+    object_id TEXT NOT NULL,
+    relation TEXT NOT NULL,
+    subject_namespace TEXT NOT NULL,
+    subject_id TEXT NOT NULL,
+    subject_relation TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,
+    PRIMARY KEY (app_id, namespace, object_id, relation, subject_namespace, subject_id, subject_relation)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE apps ADD COLUMN sign_alg TEXT NOT NULL DEFAULT 'HS256';
+ALTER TABLE apps ADD COLUMN private_key BLOB;
+ALTER TABLE apps ADD COLUMN public_key BLOB;
@@ -0,0 +1,8 @@
+ALTER TABLE apps ADD COLUMN private_key BLOB;
+ALTER TABLE apps ADD COLUMN public_key BLOB;
+
+UPDATE apps
+SET private_key = (SELECT k.private_key FROM app_keys k WHERE k.app_id = apps.id AND k.status = 'active'),
+    public_key  = (SELECT k.public_key FROM app_keys k WHERE k.app_id = apps.id AND k.status = 'active');
+
+DROP TABLE IF EXISTS app_keys;
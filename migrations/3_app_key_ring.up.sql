@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS app_keys
+(
+    id INTEGER PRIMARY KEY,
+    app_id INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    kid TEXT UNIQUE,
+    alg TEXT NOT NULL,
+    private_key BLOB NOT NULL,
+    public_key BLOB,
+    status TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_keys_app_status ON app_keys (app_id, status);
+
+-- ключи из apps переезжают в связку как активные; kid у них вычисляется как thumbprint
+INSERT INTO app_keys (app_id, alg, private_key, public_key, status, created_at)
+SELECT id, sign_alg, private_key, public_key, 'active', CAST(strftime('%s', 'now') AS INTEGER)
+FROM apps
+WHERE private_key IS NOT NULL;
+
+ALTER TABLE apps DROP COLUMN private_key;
+ALTER TABLE apps DROP COLUMN public_key;
@@ -0,0 +1,5 @@
+-- TTL в секундах; NULL означает значение из конфига
+ALTER TABLE apps ADD COLUMN access_token_ttl INTEGER;
+ALTER TABLE apps ADD COLUMN refresh_token_ttl INTEGER;
+-- JSON шаблон claims: {"user": ["email", "is_admin"], "custom": {"tenant": "acme"}}; NULL - только email
+ALTER TABLE apps ADD COLUMN claims_template TEXT;
@@ -0,0 +1,2 @@
+-- публичный JWK приложения; если задан, access токены выдаются как JWS, вложенный в JWE на этот ключ
+ALTER TABLE apps ADD COLUMN encryption_key TEXT;
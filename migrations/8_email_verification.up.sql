@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+-- приложение не пускает пользователей с неподтвержденным email
+ALTER TABLE apps ADD COLUMN require_verified_email BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- одноразовые коды, отправленные пользователю по почте; хранится только sha256 кода
+CREATE TABLE IF NOT EXISTS one_time_codes
+(
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose TEXT NOT NULL,
+    code_hash BLOB NOT NULL UNIQUE,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    used_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_one_time_codes_user_purpose ON one_time_codes (user_id, purpose);
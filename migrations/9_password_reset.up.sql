@@ -0,0 +1,2 @@
+-- access токены, выпущенные раньше этого момента (unix секунды), недействительны: смена или сброс пароля
+ALTER TABLE users ADD COLUMN tokens_valid_after INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,37 @@
+package ssotoken
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Middleware проверяет токен из заголовка Authorization: Bearer <token> и кладет Claims в контекст запроса.
+// Без валидного токена отвечает 401
+func Middleware(v *Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearer(r.Header.Get("Authorization"))
+			if !ok {
+				unauthorized(w, "missing bearer token")
+				return
+			}
+
+			claims, err := v.Verify(r.Context(), token)
+			if err != nil {
+				if errors.Is(err, ErrInvalidToken) {
+					unauthorized(w, "invalid token")
+				} else {
+					http.Error(w, "token verification unavailable", http.StatusServiceUnavailable)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), claims)))
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	http.Error(w, msg, http.StatusUnauthorized)
+}
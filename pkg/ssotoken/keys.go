@@ -0,0 +1,147 @@
+package ssotoken
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sso/internal/lib/jwks"
+	"sso/internal/lib/paseto"
+	"sync"
+	"time"
+)
+
+var ErrUnknownKey = errors.New("unknown key")
+
+// KeySet источник ключей проверки подписи
+type KeySet interface {
+	VerificationKey(ctx context.Context, kid, alg string) (any, error)
+}
+
+// Secret общий секрет приложения с подписью HS256
+type Secret []byte
+
+func (s Secret) VerificationKey(_ context.Context, _ string, alg string) (any, error) {
+	if alg != "HS256" {
+		return nil, fmt.Errorf("%w: secret can't verify %s", ErrUnknownKey, alg)
+	}
+	return []byte(s), nil
+}
+
+// LocalKey симметричный ключ приложения для токенов PASETO v4.local
+type LocalKey []byte
+
+func (k LocalKey) VerificationKey(_ context.Context, _ string, alg string) (any, error) {
+	if alg != paseto.AlgV4Local {
+		return nil, fmt.Errorf("%w: local key can't verify %s", ErrUnknownKey, alg)
+	}
+	return []byte(k), nil
+}
+
+// StaticKeySet ключи из уже полученного JWK Set, например из ответа Auth.JWKS
+type StaticKeySet struct {
+	set jwks.Set
+}
+
+// ParseJWKS разбирает JSON документ JWK Set
+func ParseJWKS(raw []byte) (*StaticKeySet, error) {
+	var set jwks.Set
+
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("parse jwks: %w", err)
+	}
+
+	return &StaticKeySet{set: set}, nil
+}
+
+func (s *StaticKeySet) VerificationKey(_ context.Context, kid, alg string) (any, error) {
+	return keyFromSet(s.set, kid, alg)
+}
+
+// RemoteKeySet ключи, которые скачиваются с /.well-known/jwks.json и кэшируются.
+// Неизвестный kid приводит к повторной загрузке, но не чаще minRefresh
+type RemoteKeySet struct {
+	url        string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+
+	mu        sync.Mutex
+	set       jwks.Set
+	fetchedAt time.Time
+}
+
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:        url,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		ttl:        5 * time.Minute,
+		minRefresh: 10 * time.Second,
+	}
+}
+
+func (r *RemoteKeySet) VerificationKey(ctx context.Context, kid, alg string) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stale := time.Since(r.fetchedAt) > r.ttl
+
+	if !stale {
+		key, err := keyFromSet(r.set, kid, alg)
+		if err == nil || !errors.Is(err, ErrUnknownKey) || time.Since(r.fetchedAt) < r.minRefresh {
+			return key, err
+		}
+	}
+
+	if err := r.fetch(ctx); err != nil {
+		return nil, err
+	}
+
+	return keyFromSet(r.set, kid, alg)
+}
+
+func (r *RemoteKeySet) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var set jwks.Set
+
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	r.set = set
+	r.fetchedAt = time.Now()
+
+	return nil
+}
+
+func keyFromSet(set jwks.Set, kid, alg string) (any, error) {
+	if kid == "" {
+		return nil, fmt.Errorf("%w: token has no kid", ErrUnknownKey)
+	}
+
+	jwk, ok := set.Key(kid)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+
+	if jwk.Alg != alg {
+		return nil, fmt.Errorf("%w: key %s is %s, token is %s", ErrUnknownKey, kid, jwk.Alg, alg)
+	}
+
+	return jwk.PublicKey()
+}
@@ -0,0 +1,295 @@
+// Package ssotoken проверяет токены, выпущенные sso, и кладет проверенную личность в context.Context.
+// Сервисам не нужно разбирать MapClaims вручную: Verify возвращает типизированные Claims
+// и для JWT, и для PASETO v4
+package ssotoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"slices"
+	"sso/internal/lib/jwe"
+	"sso/internal/lib/paseto"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims проверенное содержимое токена sso. UserID берется из sub,
+// а у токенов старого формата без sub - из uid
+type Claims struct {
+	UserID int64  `json:"uid"`
+	Email  string `json:"email"`
+	AppID  int    `json:"app_id"`
+	// AMR способы аутентификации: pwd, а после второго фактора еще otp и mfa
+	AMR []string `json:"amr,omitempty"`
+	// SessionID сессия sso, в которой выпущен токен
+	SessionID string `json:"sid,omitempty"`
+	// Roles и Permissions роли пользователя в приложении токена и их права
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// HasRole у пользователя есть роль name, глобальная или приложения токена
+func (c *Claims) HasRole(name string) bool {
+	return slices.Contains(c.Roles, name)
+}
+
+// HasPermission одна из ролей пользователя дает право permission
+func (c *Claims) HasPermission(permission string) bool {
+	return slices.Contains(c.Permissions, permission)
+}
+
+// Verifier проверяет подпись и срок действия токенов ключами из KeySet
+type Verifier struct {
+	keys      KeySet
+	appIDs    map[int]struct{}
+	parseOpts []jwt.ParserOption
+	issuer    string
+	audience  string
+	leeway    time.Duration
+	now       func() time.Time
+	isRevoked RevocationFunc
+	isEnded   SessionRevocationFunc
+	decrypt   any
+}
+
+// RevocationFunc проверяет jti по denylist sso, например через Auth.IsRevoked
+type RevocationFunc func(ctx context.Context, jti string) (bool, error)
+
+// SessionRevocationFunc проверяет, что сессия sid не завершена, например через Auth.IsRevoked с sid
+type SessionRevocationFunc func(ctx context.Context, sid string) (bool, error)
+
+type Option func(*Verifier)
+
+// WithAppID принимать только токены, выпущенные для перечисленных приложений
+func WithAppID(appIDs ...int) Option {
+	return func(v *Verifier) {
+		for _, id := range appIDs {
+			v.appIDs[id] = struct{}{}
+		}
+	}
+}
+
+// WithIssuer требовать iss, совпадающий с issuer из конфига sso
+func WithIssuer(issuer string) Option {
+	return func(v *Verifier) {
+		v.parseOpts = append(v.parseOpts, jwt.WithIssuer(issuer))
+		v.issuer = issuer
+	}
+}
+
+// WithAudience требовать aud с именем приложения
+func WithAudience(audience string) Option {
+	return func(v *Verifier) {
+		v.parseOpts = append(v.parseOpts, jwt.WithAudience(audience))
+		v.audience = audience
+	}
+}
+
+// WithLeeway допустимое расхождение часов при проверке exp
+func WithLeeway(leeway time.Duration) Option {
+	return func(v *Verifier) {
+		v.leeway = leeway
+	}
+}
+
+// WithRevocationCheck дополнительно проверять, что токен не отозван. Токены без jti не проверяются
+func WithRevocationCheck(isRevoked RevocationFunc) Option {
+	return func(v *Verifier) {
+		v.isRevoked = isRevoked
+	}
+}
+
+// WithSessionCheck дополнительно проверять, что сессия токена не завершена. Токены без sid не проверяются
+func WithSessionCheck(isEnded SessionRevocationFunc) Option {
+	return func(v *Verifier) {
+		v.isEnded = isEnded
+	}
+}
+
+// WithDecryptionKey приватный ключ приложения (*rsa.PrivateKey или *ecdsa.PrivateKey), которым расшифровываются
+// токены, выданные как JWE. Незашифрованные токены проверяются как обычно
+func WithDecryptionKey(key any) Option {
+	return func(v *Verifier) {
+		v.decrypt = key
+	}
+}
+
+func NewVerifier(keys KeySet, opts ...Option) *Verifier {
+	v := &Verifier{
+		keys:   keys,
+		appIDs: map[int]struct{}{},
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// Verify проверяет токен одноразовым верификатором без дополнительных опций
+func Verify(ctx context.Context, token string, keys KeySet) (*Claims, error) {
+	return NewVerifier(keys).Verify(ctx, token)
+}
+
+// Verify проверяет подпись, exp и приложение токена. Формат (JWT или PASETO) определяется по самому токену,
+// JWE сначала расшифровывается ключом из WithDecryptionKey
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	if jwe.IsToken(token) {
+		if v.decrypt == nil {
+			return nil, fmt.Errorf("%w: encrypted token and no decryption key", ErrInvalidToken)
+		}
+
+		inner, err := jwe.Decrypt(token, v.decrypt)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+		}
+		token = inner
+	}
+
+	parse := v.parseJWT
+	if paseto.IsToken(token) {
+		parse = v.parsePaseto
+	}
+
+	claims, err := parse(ctx, token)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if claims.Subject != "" {
+		userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad sub %q", ErrInvalidToken, claims.Subject)
+		}
+		claims.UserID = userID
+	}
+
+	if len(v.appIDs) > 0 {
+		if _, ok := v.appIDs[claims.AppID]; !ok {
+			return nil, fmt.Errorf("%w: token issued for app %d", ErrInvalidToken, claims.AppID)
+		}
+	}
+
+	if v.isRevoked != nil && claims.ID != "" {
+		revoked, err := v.isRevoked(ctx, claims.ID)
+		if err != nil {
+			return nil, fmt.Errorf("check revocation: %w", err)
+		}
+		if revoked {
+			return nil, fmt.Errorf("%w: token revoked", ErrInvalidToken)
+		}
+	}
+
+	if v.isEnded != nil && claims.SessionID != "" {
+		revoked, err := v.isEnded(ctx, claims.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("check session: %w", err)
+		}
+		if revoked {
+			return nil, fmt.Errorf("%w: session revoked", ErrInvalidToken)
+		}
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) parseJWT(ctx context.Context, token string) (*Claims, error) {
+	claims := &Claims{}
+
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.VerificationKey(ctx, kid, t.Method.Alg())
+	}, append([]jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.leeway),
+		jwt.WithTimeFunc(v.now),
+	}, v.parseOpts...)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// parsePaseto проверяет PASETO v4 ключом по kid из footer. Время в PASETO claims - RFC 3339,
+// поэтому сроки и iss/aud проверяются здесь, а не парсером JWT
+func (v *Verifier) parsePaseto(ctx context.Context, token string) (*Claims, error) {
+	footer, err := paseto.UnverifiedFooter(token)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := v.keys.VerificationKey(ctx, footer.Kid, paseto.Protocol(token))
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := paseto.Decode(token, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decoded.Validate(v.now(), v.leeway); err != nil {
+		return nil, err
+	}
+
+	if v.issuer != "" && decoded.Issuer != v.issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", decoded.Issuer)
+	}
+
+	if v.audience != "" && decoded.Audience != v.audience {
+		return nil, fmt.Errorf("unexpected audience %q", decoded.Audience)
+	}
+
+	claims := &Claims{
+		AppID:       decoded.AppID,
+		AMR:         decoded.AMR,
+		SessionID:   decoded.SessionID,
+		Roles:       decoded.Roles,
+		Permissions: decoded.Permissions,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        decoded.ID,
+			Subject:   decoded.Subject,
+			Issuer:    decoded.Issuer,
+			ExpiresAt: jwt.NewNumericDate(decoded.ExpiresAt),
+		},
+	}
+
+	claims.Email, _ = decoded.Extra["email"].(string)
+
+	if decoded.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{decoded.Audience}
+	}
+
+	if !decoded.IssuedAt.IsZero() {
+		claims.IssuedAt = jwt.NewNumericDate(decoded.IssuedAt)
+	}
+
+	if !decoded.NotBefore.IsZero() {
+		claims.NotBefore = jwt.NewNumericDate(decoded.NotBefore)
+	}
+
+	return claims, nil
+}
+
+type claimsKey struct{}
+
+// NewContext кладет проверенные claims в контекст
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext достает claims, положенные интерсептором или middleware
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
@@ -0,0 +1,391 @@
+package ssotoken
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sso/internal/domain/models"
+	"sso/internal/lib/jwe"
+	"sso/internal/lib/jwks"
+	"sso/internal/lib/jwt"
+	"sso/internal/lib/paseto"
+	"sso/internal/lib/tokens"
+	"testing"
+	"time"
+)
+
+const testIssuer = "https://sso.test"
+
+var (
+	testUser = models.User{ID: 42, Email: "user@test.com"}
+	testApp  = models.App{ID: 7, Name: "TestApp", Secret: "super-secret"}
+	testAMR  = []string{"pwd", "otp", "mfa"}
+	testSID  = "session-1"
+	testRole = "editor"
+)
+
+func newKey(t *testing.T) (models.AppKey, []byte) {
+	t.Helper()
+
+	key, err := jwt.NewKey(testApp.ID, jwt.AlgES256, models.KeyStatusActive)
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+
+	jwk, err := jwt.PublicJWK(key)
+	if err != nil {
+		t.Fatalf("PublicJWK: %v", err)
+	}
+
+	raw, err := json.Marshal(jwks.Set{Keys: []jwks.JWK{jwk}})
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+
+	return key, raw
+}
+
+func newToken(t *testing.T, key models.AppKey, ttl time.Duration) string {
+	t.Helper()
+
+	claims, err := tokens.NewClaims(testUser, testApp, tokens.Params{Issuer: testIssuer, TTL: ttl}, time.Now())
+	if err != nil {
+		t.Fatalf("NewClaims: %v", err)
+	}
+	claims.AMR = testAMR
+	claims.SessionID = testSID
+	claims.Roles = []string{testRole}
+	claims.Permissions = []string{"documents:read", "documents:write"}
+
+	newToken := jwt.NewToken
+	if paseto.Supports(key.Alg) {
+		newToken = paseto.NewToken
+	}
+
+	token, err := newToken(claims, testApp, key)
+	if err != nil {
+		t.Fatalf("NewToken: %v", err)
+	}
+
+	return token
+}
+
+func TestVerify_Secret(t *testing.T) {
+	token := newToken(t, models.AppKey{}, time.Hour)
+
+	claims, err := NewVerifier(Secret(testApp.Secret), WithIssuer(testIssuer), WithAudience(testApp.Name)).
+		Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if claims.UserID != testUser.ID || claims.Email != testUser.Email || claims.AppID != testApp.ID {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	if !slices.Equal(claims.AMR, testAMR) {
+		t.Fatalf("expected amr %v, got %v", testAMR, claims.AMR)
+	}
+
+	if !claims.HasRole(testRole) || !claims.HasPermission("documents:write") || claims.HasPermission("documents:delete") {
+		t.Fatalf("unexpected roles %v and permissions %v", claims.Roles, claims.Permissions)
+	}
+}
+
+func TestVerify_FailCases(t *testing.T) {
+	key, raw := newKey(t)
+
+	keys, err := ParseJWKS(raw)
+	if err != nil {
+		t.Fatalf("ParseJWKS: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		keys  KeySet
+		opts  []Option
+	}{
+		{name: "wrong secret", token: newToken(t, models.AppKey{}, time.Hour), keys: Secret("other")},
+		{name: "expired", token: newToken(t, key, -time.Minute), keys: keys},
+		{name: "asymmetric token with secret", token: newToken(t, key, time.Hour), keys: Secret(testApp.Secret)},
+		{name: "hs256 token with jwks", token: newToken(t, models.AppKey{}, time.Hour), keys: keys},
+		{name: "other app", token: newToken(t, key, time.Hour), keys: keys, opts: []Option{WithAppID(testApp.ID + 1)}},
+		{name: "garbage", token: "not-a-token", keys: keys},
+		{name: "other issuer", token: newToken(t, key, time.Hour), keys: keys, opts: []Option{WithIssuer("other")}},
+		{name: "other audience", token: newToken(t, key, time.Hour), keys: keys, opts: []Option{WithAudience("other")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewVerifier(tt.keys, tt.opts...).Verify(context.Background(), tt.token)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("expected ErrInvalidToken, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoteKeySet_RefetchesUnknownKid(t *testing.T) {
+	first, firstRaw := newKey(t)
+	second, secondRaw := newKey(t)
+
+	served := firstRaw
+	fetches := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		_, _ = w.Write(served)
+	}))
+	defer srv.Close()
+
+	keys := NewRemoteKeySet(srv.URL)
+	keys.minRefresh = 0
+	v := NewVerifier(keys)
+
+	if _, err := v.Verify(context.Background(), newToken(t, first, time.Hour)); err != nil {
+		t.Fatalf("Verify first: %v", err)
+	}
+
+	//ключ ротировали: второй токен подписан ключом, которого нет в кэше
+	served = secondRaw
+
+	if _, err := v.Verify(context.Background(), newToken(t, second, time.Hour)); err != nil {
+		t.Fatalf("Verify second: %v", err)
+	}
+
+	if fetches != 2 {
+		t.Fatalf("expected 2 fetches, got %d", fetches)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	v := NewVerifier(Secret(testApp.Secret))
+	interceptor := UnaryServerInterceptor(v)
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		claims, ok := FromContext(ctx)
+		if !ok {
+			t.Fatal("expected claims in context")
+		}
+		return claims.UserID, nil
+	}
+
+	token := newToken(t, models.AppKey{}, time.Hour)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+
+	resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	if err != nil {
+		t.Fatalf("interceptor: %v", err)
+	}
+	if resp != testUser.ID {
+		t.Fatalf("expected uid %d, got %v", testUser.ID, resp)
+	}
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated without token, got: %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	v := NewVerifier(Secret(testApp.Secret))
+
+	h := Middleware(v)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := FromContext(r.Context())
+		_, _ = w.Write([]byte(claims.Email))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+newToken(t, models.AppKey{}, time.Hour))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != testUser.Email {
+		t.Fatalf("unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rec.Code)
+	}
+}
+
+func TestVerify_RevocationCheck(t *testing.T) {
+	token := newToken(t, models.AppKey{}, time.Hour)
+
+	var checked string
+	v := NewVerifier(Secret(testApp.Secret), WithRevocationCheck(func(_ context.Context, jti string) (bool, error) {
+		checked = jti
+		return true, nil
+	}))
+
+	if _, err := v.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected revoked token to be invalid, got: %v", err)
+	}
+	if checked == "" {
+		t.Fatal("expected jti to be checked")
+	}
+
+	failing := NewVerifier(Secret(testApp.Secret), WithRevocationCheck(func(context.Context, string) (bool, error) {
+		return false, errors.New("sso unavailable")
+	}))
+
+	_, err := failing.Verify(context.Background(), token)
+	if err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected revocation backend error, got: %v", err)
+	}
+}
+
+func TestVerify_SessionCheck(t *testing.T) {
+	token := newToken(t, models.AppKey{}, time.Hour)
+
+	var checked string
+	v := NewVerifier(Secret(testApp.Secret), WithSessionCheck(func(_ context.Context, sid string) (bool, error) {
+		checked = sid
+		return true, nil
+	}))
+
+	if _, err := v.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected token of revoked session to be invalid, got: %v", err)
+	}
+	if checked != testSID {
+		t.Fatalf("expected sid %q to be checked, got %q", testSID, checked)
+	}
+
+	active := NewVerifier(Secret(testApp.Secret), WithSessionCheck(func(context.Context, string) (bool, error) {
+		return false, nil
+	}))
+
+	claims, err := active.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.SessionID != testSID {
+		t.Fatalf("expected sid %q, got %q", testSID, claims.SessionID)
+	}
+}
+
+func TestVerify_Paseto(t *testing.T) {
+	public, err := paseto.NewKey(testApp.ID, paseto.AlgV4Public, models.KeyStatusActive)
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+
+	jwk, err := paseto.PublicJWK(public)
+	if err != nil {
+		t.Fatalf("PublicJWK: %v", err)
+	}
+
+	raw, err := json.Marshal(jwks.Set{Keys: []jwks.JWK{jwk}})
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+
+	publicKeys, err := ParseJWKS(raw)
+	if err != nil {
+		t.Fatalf("ParseJWKS: %v", err)
+	}
+
+	local, err := paseto.NewKey(testApp.ID, paseto.AlgV4Local, models.KeyStatusActive)
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		keys    KeySet
+		token   string
+		opts    []Option
+		wantErr bool
+	}{
+		{name: "v4.public", keys: publicKeys, token: newToken(t, public, time.Hour)},
+		{name: "v4.local", keys: LocalKey(local.PrivateKey), token: newToken(t, local, time.Hour)},
+		{name: "expired", keys: publicKeys, token: newToken(t, public, -time.Minute), wantErr: true},
+		{name: "wrong issuer", keys: publicKeys, token: newToken(t, public, time.Hour), opts: []Option{WithIssuer("other")}, wantErr: true},
+		{name: "wrong audience", keys: publicKeys, token: newToken(t, public, time.Hour), opts: []Option{WithAudience("other")}, wantErr: true},
+		{name: "local key can't verify public", keys: LocalKey(local.PrivateKey), token: newToken(t, public, time.Hour), wantErr: true},
+		{name: "secret can't verify local", keys: Secret(local.PrivateKey), token: newToken(t, local, time.Hour), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithIssuer(testIssuer), WithAudience(testApp.Name)}, tt.opts...)
+
+			claims, err := NewVerifier(tt.keys, opts...).Verify(context.Background(), tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("expected ErrInvalidToken, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+
+			if claims.UserID != testUser.ID || claims.Email != testUser.Email || claims.AppID != testApp.ID {
+				t.Fatalf("unexpected claims %+v", claims)
+			}
+
+			if !slices.Equal(claims.AMR, testAMR) {
+				t.Fatalf("expected amr %v, got %v", testAMR, claims.AMR)
+			}
+
+			if claims.SessionID != testSID {
+				t.Fatalf("expected sid %q, got %q", testSID, claims.SessionID)
+			}
+
+			if !claims.HasRole(testRole) || !claims.HasPermission("documents:read") {
+				t.Fatalf("unexpected roles %v and permissions %v", claims.Roles, claims.Permissions)
+			}
+		})
+	}
+}
+
+func TestVerify_Encrypted(t *testing.T) {
+	appKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+
+	jwk, err := jwks.FromPublicKey(&appKey.PublicKey, "")
+	if err != nil {
+		t.Fatalf("FromPublicKey: %v", err)
+	}
+	jwk.Use = "enc"
+
+	raw, err := json.Marshal(jwk)
+	if err != nil {
+		t.Fatalf("marshal jwk: %v", err)
+	}
+
+	token, err := jwe.Encrypt(newToken(t, models.AppKey{}, time.Hour), raw)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	if _, err := Verify(context.Background(), token, Secret(testApp.Secret)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken without decryption key, got: %v", err)
+	}
+
+	claims, err := NewVerifier(Secret(testApp.Secret), WithDecryptionKey(appKey)).Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if claims.UserID != testUser.ID || claims.Email != testUser.Email {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
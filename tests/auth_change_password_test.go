@@ -0,0 +1,179 @@
+package tests
+
+import (
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestChangePassword_KeepSessions(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+	newPassword := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	resp, err := s.AuthClient.ChangePassword(ctx, &ssov1.ChangePasswordRequest{
+		Token:           respLogin.GetToken(),
+		CurrentPassword: password,
+		NewPassword:     newPassword,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, resp.GetToken())
+
+	_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+
+	_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: newPassword, AppId: appID})
+	require.NoError(t, err)
+
+	//сессия осталась
+	_, err = s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: respLogin.GetRefreshToken()})
+	require.NoError(t, err)
+}
+
+func TestChangePassword_RevokeOtherSessions(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	current, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	other, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	resp, err := s.AuthClient.ChangePassword(ctx, &ssov1.ChangePasswordRequest{
+		Token:               current.GetToken(),
+		CurrentPassword:     password,
+		NewPassword:         randomFakePassword(),
+		RevokeOtherSessions: true,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.GetToken())
+	require.NotEmpty(t, resp.GetRefreshToken())
+
+	_, err = s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: other.GetRefreshToken()})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	access, err := s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+		Token:     other.GetToken(),
+		AppId:     appID,
+		AppSecret: appSecret,
+	})
+	require.NoError(t, err)
+	assert.False(t, access.GetActive())
+
+	//текущая сессия продолжается с новыми токенами
+	access, err = s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+		Token:     resp.GetToken(),
+		AppId:     appID,
+		AppSecret: appSecret,
+	})
+	require.NoError(t, err)
+	assert.True(t, access.GetActive())
+
+	_, err = s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: resp.GetRefreshToken()})
+	require.NoError(t, err)
+}
+
+func TestChangePassword_Fails(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		token           string
+		currentPassword string
+		expectedCode    codes.Code
+	}{
+		{
+			name:            "Wrong current password",
+			token:           respLogin.GetToken(),
+			currentPassword: randomFakePassword(),
+			expectedCode:    codes.InvalidArgument,
+		},
+		{
+			name:            "Invalid token",
+			token:           "not-a-token",
+			currentPassword: password,
+			expectedCode:    codes.Unauthenticated,
+		},
+		{
+			name:            "Empty current password",
+			token:           respLogin.GetToken(),
+			currentPassword: "",
+			expectedCode:    codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.AuthClient.ChangePassword(ctx, &ssov1.ChangePasswordRequest{
+				Token:           tt.token,
+				CurrentPassword: tt.currentPassword,
+				NewPassword:     randomFakePassword(),
+			})
+			require.Error(t, err)
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestChangePassword_Throttled(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	backoffAfter := s.Cfg.LoginThrottle.BackoffAfter
+	if backoffAfter <= 0 {
+		t.Skip("login backoff is disabled in config")
+	}
+
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	//с одним access токеном текущий пароль перебирается не быстрее, чем через Login
+	for range backoffAfter {
+		_, err = s.AuthClient.ChangePassword(ctx, &ssov1.ChangePasswordRequest{
+			Token:           respLogin.GetToken(),
+			CurrentPassword: randomFakePassword(),
+			NewPassword:     randomFakePassword(),
+		})
+		require.Error(t, err)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	_, err = s.AuthClient.ChangePassword(ctx, &ssov1.ChangePasswordRequest{
+		Token:           respLogin.GetToken(),
+		CurrentPassword: password,
+		NewPassword:     randomFakePassword(),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"fmt"
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/tests/suite"
+	"strconv"
+	"testing"
+)
+
+// хэши пароля legacyPassword в форматах других систем
+const (
+	djangoHash = "pbkdf2_sha256$1000$Qx3kZ8yWm2Lp$MLghA0elsHMFMS3PzdTzi6fAGF2lLnAavqLsooCZO2g="
+	pbkdf2Hash = "$pbkdf2-sha256$i=1000$cGhjLXNhbHQtMDAwMDAwMQ$1kn6lFyadRLYaHqxHv4za5IkRxjELRp1AwcD0FZtHLw"
+	bcryptHash = "$2a$04$acAtJGPnKQEBcWJ.ukvKh.B6O5NmS4DbdJpDJAee35s8ljzzt6yFy"
+
+	keycloakSecretData = `{"value":"b0B8gYqQXSxqBpSbMRW6dSNXh9Mj0EtFWVwiMaSmZfob4ffB0nwAEeRe+yhrHcqht6yBJv/AuFhJvyMQ7SgYYQ==",` +
+		`"salt":"a2V5Y2xvYWstc2FsdC0wMQ==","additionalParameters":{}}`
+	keycloakCredentialData = `{"hashIterations":1000,"algorithm":"pbkdf2-sha512","additionalParameters":{}}`
+)
+
+func TestImportUsers_CSV(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	token := adminToken(ctx, t, s)
+
+	emails := []string{gofakeit.Email(), gofakeit.Email(), gofakeit.Email()}
+
+	data := "email,password_hash,email_verified\n" +
+		emails[0] + "," + djangoHash + ",true\n" +
+		emails[1] + "," + pbkdf2Hash + "\n" +
+		emails[2] + "," + bcryptHash + ",false\n" +
+		gofakeit.Email() + ",$1$md5$crypt\n" +
+		adminEmail + "," + bcryptHash + "\n"
+
+	resp, err := s.AuthClient.ImportUsers(ctx, &ssov1.ImportUsersRequest{Token: token, Format: "csv", Data: []byte(data)})
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(3), resp.GetImported())
+	assert.Equal(t, int32(1), resp.GetExisting())
+	require.Len(t, resp.GetFailures(), 1)
+	assert.Equal(t, int32(5), resp.GetFailures()[0].GetLine())
+	assert.Equal(t, "unsupported password hash", resp.GetFailures()[0].GetReason())
+
+	for _, email := range emails {
+		//первый вход проверяет чужой хэш и пересчитывает его, второй проверяет уже новый
+		for range 2 {
+			_, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: legacyPassword, AppId: appID})
+			require.NoError(t, err, email)
+		}
+	}
+
+	//существующий пользователь не перезаписан импортом
+	_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: adminEmail, Password: adminPassword, AppId: appID})
+	require.NoError(t, err)
+}
+
+func TestImportUsers_KeycloakJSONL(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email := gofakeit.Email()
+
+	data := fmt.Sprintf(`{"username":"kc","email":%q,"emailVerified":true,"credentials":[{"type":"password","secretData":%s,"credentialData":%s}]}`,
+		email, strconv.Quote(keycloakSecretData), strconv.Quote(keycloakCredentialData))
+
+	resp, err := s.AuthClient.ImportUsers(ctx, &ssov1.ImportUsersRequest{
+		Token:  adminToken(ctx, t, s),
+		Format: "jsonl",
+		Data:   []byte(data + "\n"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.GetImported())
+	assert.Empty(t, resp.GetFailures())
+
+	//emailVerified из экспорта переносится: приложение с обязательным подтверждением пускает пользователя
+	_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: legacyPassword, AppId: verifiedAppID})
+	require.NoError(t, err)
+}
+
+func TestImportUsers_Fails(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	data := []byte("email,password_hash\n" + gofakeit.Email() + "," + bcryptHash + "\n")
+
+	_, err := s.AuthClient.ImportUsers(ctx, &ssov1.ImportUsersRequest{
+		Token:  registerAndLogin(ctx, t, s).GetToken(),
+		Format: "csv",
+		Data:   data,
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = s.AuthClient.ImportUsers(ctx, &ssov1.ImportUsersRequest{
+		Token:  adminToken(ctx, t, s),
+		Format: "csv",
+		Data:   []byte("login,hash\nuser,x\n"),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = s.AuthClient.ImportUsers(ctx, &ssov1.ImportUsersRequest{Token: adminToken(ctx, t, s), Format: "xml", Data: data})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/url"
+	"sso/tests/suite"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIntrospect_AccessAndRefreshTokens(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	respLogin := registerAndLogin(ctx, t, s)
+
+	access, err := s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+		Token:     respLogin.GetToken(),
+		AppId:     appID,
+		AppSecret: appSecret,
+	})
+	require.NoError(t, err)
+	assert.True(t, access.GetActive())
+	assert.Equal(t, "access_token", access.GetTokenType())
+	assert.NotEmpty(t, access.GetUserId())
+	assert.NotEmpty(t, access.GetEmail())
+	assert.EqualValues(t, appID, access.GetAppId())
+	assert.NotEmpty(t, access.GetExp())
+
+	refresh, err := s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+		Token:         respLogin.GetRefreshToken(),
+		TokenTypeHint: "refresh_token",
+		AppId:         appID,
+		AppSecret:     appSecret,
+	})
+	require.NoError(t, err)
+	assert.True(t, refresh.GetActive())
+	assert.Equal(t, "refresh_token", refresh.GetTokenType())
+	assert.Equal(t, access.GetUserId(), refresh.GetUserId())
+}
+
+func TestIntrospect_InactiveAndUnauthenticated(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	resp, err := s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+		Token:     "not-a-token",
+		AppId:     appID,
+		AppSecret: appSecret,
+	})
+	require.NoError(t, err)
+	assert.False(t, resp.GetActive())
+	assert.Empty(t, resp.GetUserId())
+
+	_, err = s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+		Token:     "not-a-token",
+		AppId:     appID,
+		AppSecret: "wrong-secret",
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestIntrospect_HTTP(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	respLogin := registerAndLogin(ctx, t, s)
+
+	introspect := func(secret, token string) *http.Response {
+		form := url.Values{"token": {token}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+			fmt.Sprintf("http://localhost:%d/introspect", s.Cfg.HTTP.Port), strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(strconv.Itoa(appID), secret)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = resp.Body.Close() })
+
+		return resp
+	}
+
+	resp := introspect(appSecret, respLogin.GetToken())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Active bool   `json:"active"`
+		Sub    string `json:"sub"`
+		AppID  int    `json:"app_id"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.True(t, body.Active)
+	assert.NotEmpty(t, body.Sub)
+	assert.Equal(t, appID, body.AppID)
+
+	resp = introspect("wrong-secret", respLogin.GetToken())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
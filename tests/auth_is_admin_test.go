@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/internal/domain/models"
+	"sso/pkg/ssotoken"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestIsAdmin_HappyPath(t *testing.T) {
+	ctx, s := suite.New(t)
+	admin := adminToken(ctx, t, s)
+	adminID := tokenUserID(ctx, t, admin)
+
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	respReg, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	resp, err := s.AuthClient.IsAdmin(ctx, &ssov1.IsAdminRequest{Token: admin, UserId: adminID})
+	require.NoError(t, err)
+	assert.True(t, resp.GetIsAdmin())
+
+	resp, err = s.AuthClient.IsAdmin(ctx, &ssov1.IsAdminRequest{Token: admin, UserId: respReg.GetUserId()})
+	require.NoError(t, err)
+	assert.False(t, resp.GetIsAdmin())
+
+	//себя может проверить любой пользователь
+	resp, err = s.AuthClient.IsAdmin(ctx, &ssov1.IsAdminRequest{Token: respLogin.GetToken(), UserId: respReg.GetUserId()})
+	require.NoError(t, err)
+	assert.False(t, resp.GetIsAdmin())
+
+	//IsAdmin следует за глобальной ролью admin
+	var adminRoleID int64
+
+	roles, err := s.AuthClient.ListUserRoles(ctx, &ssov1.ListUserRolesRequest{Token: admin})
+	require.NoError(t, err)
+
+	for _, role := range roles.GetRoles() {
+		if role.GetName() == models.RoleAdmin && role.GetAppId() == 0 {
+			adminRoleID = role.GetId()
+		}
+	}
+	require.NotZero(t, adminRoleID)
+
+	_, err = s.AuthClient.GrantRole(ctx, &ssov1.GrantRoleRequest{Token: admin, UserId: respReg.GetUserId(), RoleId: adminRoleID})
+	require.NoError(t, err)
+
+	resp, err = s.AuthClient.IsAdmin(ctx, &ssov1.IsAdminRequest{Token: admin, UserId: respReg.GetUserId()})
+	require.NoError(t, err)
+	assert.True(t, resp.GetIsAdmin())
+
+	_, err = s.AuthClient.RevokeRole(ctx, &ssov1.RevokeRoleRequest{Token: admin, UserId: respReg.GetUserId(), RoleId: adminRoleID})
+	require.NoError(t, err)
+
+	resp, err = s.AuthClient.IsAdmin(ctx, &ssov1.IsAdminRequest{Token: admin, UserId: respReg.GetUserId()})
+	require.NoError(t, err)
+	assert.False(t, resp.GetIsAdmin())
+}
+
+func TestIsAdmin_FailCases(t *testing.T) {
+	ctx, s := suite.New(t)
+	admin := adminToken(ctx, t, s)
+	adminID := tokenUserID(ctx, t, admin)
+
+	respLogin := registerAndLogin(ctx, t, s)
+
+	tests := []struct {
+		name     string
+		token    string
+		userID   int64
+		wantCode codes.Code
+	}{
+		{name: "unknown user", token: admin, userID: 999999999, wantCode: codes.NotFound},
+		//существование чужого id не раскрывается не-администратору
+		{name: "other user", token: respLogin.GetToken(), userID: adminID, wantCode: codes.PermissionDenied},
+		{name: "unknown user as non-admin", token: respLogin.GetToken(), userID: 999999999, wantCode: codes.PermissionDenied},
+		{name: "invalid token", token: "invalid-token", userID: adminID, wantCode: codes.Unauthenticated},
+		{name: "empty token", token: "", userID: adminID, wantCode: codes.InvalidArgument},
+		{name: "empty user id", token: admin, userID: 0, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.AuthClient.IsAdmin(ctx, &ssov1.IsAdminRequest{Token: tt.token, UserId: tt.userID})
+			require.Error(t, err)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func tokenUserID(ctx context.Context, t *testing.T, token string) int64 {
+	t.Helper()
+
+	claims, err := ssotoken.Verify(ctx, token, ssotoken.Secret(appSecret))
+	require.NoError(t, err)
+
+	return claims.UserID
+}
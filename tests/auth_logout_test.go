@@ -0,0 +1,116 @@
+package tests
+
+import (
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/pkg/ssotoken"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestLogout_RevokesAccessAndRefreshTokens(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	respLogin := registerAndLogin(ctx, t, s)
+
+	claims, err := ssotoken.Verify(ctx, respLogin.GetToken(), ssotoken.Secret(appSecret))
+	require.NoError(t, err)
+	require.NotEmpty(t, claims.ID)
+
+	_, err = s.AuthClient.Logout(ctx, &ssov1.LogoutRequest{
+		Token:        respLogin.GetToken(),
+		RefreshToken: respLogin.GetRefreshToken(),
+	})
+	require.NoError(t, err)
+
+	revoked, err := s.AuthClient.IsRevoked(ctx, &ssov1.IsRevokedRequest{Jti: claims.ID})
+	require.NoError(t, err)
+	assert.True(t, revoked.GetRevoked())
+
+	introspection, err := s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+		Token:     respLogin.GetToken(),
+		AppId:     appID,
+		AppSecret: appSecret,
+	})
+	require.NoError(t, err)
+	assert.False(t, introspection.GetActive())
+
+	_, err = s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: respLogin.GetRefreshToken()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	//повторный logout отозванным токеном
+	_, err = s.AuthClient.Logout(ctx, &ssov1.LogoutRequest{Token: respLogin.GetToken()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestRevokeToken_ByApp(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	respLogin := registerAndLogin(ctx, t, s)
+
+	_, err := s.AuthClient.RevokeToken(ctx, &ssov1.RevokeTokenRequest{
+		Token:         respLogin.GetRefreshToken(),
+		TokenTypeHint: "refresh_token",
+		AppId:         appID,
+		AppSecret:     appSecret,
+	})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: respLogin.GetRefreshToken()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	//неизвестный токен отзывается без ошибки (RFC 7009)
+	_, err = s.AuthClient.RevokeToken(ctx, &ssov1.RevokeTokenRequest{
+		Token:     "unknown-token",
+		AppId:     appID,
+		AppSecret: appSecret,
+	})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.RevokeToken(ctx, &ssov1.RevokeTokenRequest{
+		Token:     respLogin.GetToken(),
+		AppId:     appID,
+		AppSecret: "wrong-secret",
+	})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestRevokeToken_OtherAppIgnored(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	respLogin := registerAndLogin(ctx, t, s)
+
+	//чужое приложение получает успех, но токены приложения appID остаются рабочими (RFC 7009 §2.1)
+	for _, req := range []*ssov1.RevokeTokenRequest{
+		{Token: respLogin.GetToken()},
+		{Token: respLogin.GetRefreshToken(), TokenTypeHint: "refresh_token"},
+	} {
+		req.AppId = policyAppID
+		req.AppSecret = policyAppSecret
+
+		_, err := s.AuthClient.RevokeToken(ctx, req)
+		require.NoError(t, err)
+	}
+
+	for _, token := range []string{respLogin.GetToken(), respLogin.GetRefreshToken()} {
+		other, err := s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+			Token:     token,
+			AppId:     policyAppID,
+			AppSecret: policyAppSecret,
+		})
+		require.NoError(t, err)
+		assert.False(t, other.GetActive())
+		assert.Empty(t, other.GetEmail())
+
+		own, err := s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+			Token:     token,
+			AppId:     appID,
+			AppSecret: appSecret,
+		})
+		require.NoError(t, err)
+		assert.True(t, own.GetActive())
+	}
+}
@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"context"
+	"encoding/base32"
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/internal/lib/totp"
+	"sso/tests/suite"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMFA_EnrollLoginDisable(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	require.False(t, respLogin.GetMfaRequired())
+	assert.Equal(t, []any{"pwd"}, tokenClaims(t, respLogin.GetToken())["amr"])
+
+	enroll, err := s.AuthClient.EnrollTOTP(ctx, &ssov1.EnrollTOTPRequest{Token: respLogin.GetToken()})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(enroll.GetOtpauthUri(), "otpauth://totp/"))
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enroll.GetSecret())
+	require.NoError(t, err)
+
+	step := totp.Step(time.Now())
+
+	_, err = s.AuthClient.ConfirmTOTP(ctx, &ssov1.ConfirmTOTPRequest{Token: respLogin.GetToken(), Code: "abcdef"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	confirm, err := s.AuthClient.ConfirmTOTP(ctx, &ssov1.ConfirmTOTPRequest{
+		Token: respLogin.GetToken(),
+		Code:  totp.Code(secret, step),
+	})
+	require.NoError(t, err)
+	require.Len(t, confirm.GetRecoveryCodes(), 10)
+	recoveryCodes := confirm.GetRecoveryCodes()
+
+	//повторное подключение при включенном втором факторе запрещено
+	_, err = s.AuthClient.EnrollTOTP(ctx, &ssov1.EnrollTOTPRequest{Token: respLogin.GetToken()})
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	//пароль дает только challenge
+	challenge := mfaChallenge(ctx, t, s, email, password)
+
+	//шаг кода подтверждения уже использован, поэтому следующий код
+	respMFA, err := s.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{MfaChallenge: challenge, Code: totp.Code(secret, step+1)})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"pwd", "otp", "mfa"}, tokenClaims(t, respMFA.GetToken())["amr"])
+
+	//amr сохраняется при обмене refresh токена
+	respRefresh, err := s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: respMFA.GetRefreshToken()})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"pwd", "otp", "mfa"}, tokenClaims(t, respRefresh.GetToken())["amr"])
+
+	//код восстановления одноразовый
+	respMFA, err = s.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaChallenge: mfaChallenge(ctx, t, s, email, password),
+		Code:         strings.ToUpper(recoveryCodes[0]),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"pwd", "mfa"}, tokenClaims(t, respMFA.GetToken())["amr"])
+
+	_, err = s.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaChallenge: mfaChallenge(ctx, t, s, email, password),
+		Code:         recoveryCodes[0],
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = s.AuthClient.DisableTOTP(ctx, &ssov1.DisableTOTPRequest{Token: respMFA.GetToken(), Code: recoveryCodes[1]})
+	require.NoError(t, err)
+
+	respLogin, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	assert.False(t, respLogin.GetMfaRequired())
+	assert.NotEmpty(t, respLogin.GetToken())
+}
+
+func TestVerifyMFA_ChallengeIsSingleUse(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	enroll, err := s.AuthClient.EnrollTOTP(ctx, &ssov1.EnrollTOTPRequest{Token: respLogin.GetToken()})
+	require.NoError(t, err)
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enroll.GetSecret())
+	require.NoError(t, err)
+
+	step := totp.Step(time.Now())
+
+	_, err = s.AuthClient.ConfirmTOTP(ctx, &ssov1.ConfirmTOTPRequest{Token: respLogin.GetToken(), Code: totp.Code(secret, step)})
+	require.NoError(t, err)
+
+	challenge := mfaChallenge(ctx, t, s, email, password)
+
+	_, err = s.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{MfaChallenge: challenge, Code: "not-a-code"})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	//после неверного кода challenge погашен даже для верного кода
+	_, err = s.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{MfaChallenge: challenge, Code: totp.Code(secret, step+1)})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func mfaChallenge(ctx context.Context, t *testing.T, s *suite.Suite, email, password string) string {
+	t.Helper()
+
+	resp, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	require.True(t, resp.GetMfaRequired())
+	require.Empty(t, resp.GetToken())
+	require.NotEmpty(t, resp.GetMfaChallenge())
+
+	return resp.GetMfaChallenge()
+}
+
+func tokenClaims(t *testing.T, token string) jwt.MapClaims {
+	t.Helper()
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return []byte(appSecret), nil
+	})
+	require.NoError(t, err)
+
+	return claims
+}
+
+func TestVerifyMFA_PasswordDoesNotResetFailures(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	backoffAfter := s.Cfg.LoginThrottle.BackoffAfter
+	if backoffAfter <= 0 {
+		t.Skip("login backoff is disabled in config")
+	}
+
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	enroll, err := s.AuthClient.EnrollTOTP(ctx, &ssov1.EnrollTOTPRequest{Token: respLogin.GetToken()})
+	require.NoError(t, err)
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enroll.GetSecret())
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.ConfirmTOTP(ctx, &ssov1.ConfirmTOTPRequest{Token: respLogin.GetToken(), Code: totp.Code(secret, totp.Step(time.Now()))})
+	require.NoError(t, err)
+
+	//верный пароль перед каждым неверным кодом не прощает неудачи
+	for range backoffAfter {
+		_, err = s.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{MfaChallenge: mfaChallenge(ctx, t, s, email, password), Code: "not-a-code"})
+		require.Error(t, err)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
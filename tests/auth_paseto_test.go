@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"encoding/hex"
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sso/pkg/ssotoken"
+	"sso/tests/suite"
+	"strings"
+	"testing"
+)
+
+const (
+	pasetoAppID     = 3
+	pasetoAppName   = "test-paseto"
+	pasetoAppSecret = "test-paseto-secret"
+	pasetoLocalKey  = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+)
+
+func TestLogin_PasetoLocal(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	respReg, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: pasetoAppID})
+	require.NoError(t, err)
+
+	token := respLogin.GetToken()
+	require.True(t, strings.HasPrefix(token, "v4.local."), "expected paseto token, got %s", token)
+
+	key, err := hex.DecodeString(pasetoLocalKey)
+	require.NoError(t, err)
+
+	claims, err := ssotoken.NewVerifier(ssotoken.LocalKey(key),
+		ssotoken.WithIssuer(s.Cfg.Issuer),
+		ssotoken.WithAudience(pasetoAppName),
+	).Verify(ctx, token)
+	require.NoError(t, err)
+
+	assert.Equal(t, respReg.GetUserId(), claims.UserID)
+	assert.Equal(t, email, claims.Email)
+	assert.Equal(t, pasetoAppID, claims.AppID)
+
+	introspection, err := s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+		Token:     token,
+		AppId:     pasetoAppID,
+		AppSecret: pasetoAppSecret,
+	})
+	require.NoError(t, err)
+	assert.True(t, introspection.GetActive())
+	assert.Equal(t, respReg.GetUserId(), introspection.GetUserId())
+	assert.Equal(t, email, introspection.GetEmail())
+}
@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"context"
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/internal/lib/passkey/passkeytest"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestPasskey_RegisterAndLogin(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	respReg, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	authenticator := passkeytest.New(s.Cfg.WebAuthn.Origins[0])
+	registerPasskey(ctx, t, s, authenticator, respLogin.GetToken())
+
+	//ключ не регистрируется повторно тем же аутентификатором
+	begin, err := s.AuthClient.BeginPasskeyRegistration(ctx, &ssov1.BeginPasskeyRegistrationRequest{Token: respLogin.GetToken()})
+	require.NoError(t, err)
+	_, err = authenticator.Create([]byte(begin.GetOptions()))
+	require.Error(t, err)
+
+	passwordClaims := tokenClaims(t, respLogin.GetToken())
+
+	for _, loginEmail := range []string{email, ""} {
+		respPasskey := passkeyLogin(ctx, t, s, authenticator, loginEmail)
+
+		claims := tokenClaims(t, respPasskey.GetToken())
+		assert.Equal(t, []any{"hwk", "mfa"}, claims["amr"])
+
+		//токен тот же, что после входа по паролю: отличаются только способ входа, jti и время
+		for _, claim := range []string{"sub", "uid", "email", "app_id", "iss", "aud"} {
+			assert.Equal(t, passwordClaims[claim], claims[claim], claim)
+		}
+		assert.Equal(t, float64(respReg.GetUserId()), claims["uid"])
+
+		respRefresh, err := s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: respPasskey.GetRefreshToken()})
+		require.NoError(t, err)
+		assert.Equal(t, []any{"hwk", "mfa"}, tokenClaims(t, respRefresh.GetToken())["amr"])
+	}
+}
+
+func TestPasskey_LoginFails(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	respLogin := registerAndLogin(ctx, t, s)
+	authenticator := passkeytest.New(s.Cfg.WebAuthn.Origins[0])
+	registerPasskey(ctx, t, s, authenticator, respLogin.GetToken())
+
+	clone := authenticator.Clone()
+
+	t.Run("session is single use", func(t *testing.T) {
+		begin, err := s.AuthClient.BeginPasskeyLogin(ctx, &ssov1.BeginPasskeyLoginRequest{AppId: appID})
+		require.NoError(t, err)
+
+		credential, err := authenticator.Get([]byte(begin.GetOptions()))
+		require.NoError(t, err)
+
+		req := &ssov1.FinishPasskeyLoginRequest{SessionId: begin.GetSessionId(), Credential: string(credential)}
+
+		_, err = s.AuthClient.FinishPasskeyLogin(ctx, req)
+		require.NoError(t, err)
+
+		_, err = s.AuthClient.FinishPasskeyLogin(ctx, req)
+		require.Error(t, err)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("cloned authenticator", func(t *testing.T) {
+		begin, err := s.AuthClient.BeginPasskeyLogin(ctx, &ssov1.BeginPasskeyLoginRequest{AppId: appID})
+		require.NoError(t, err)
+
+		//у копии счетчик подписей отстает от уже принятого
+		credential, err := clone.Get([]byte(begin.GetOptions()))
+		require.NoError(t, err)
+
+		_, err = s.AuthClient.FinishPasskeyLogin(ctx, &ssov1.FinishPasskeyLoginRequest{
+			SessionId:  begin.GetSessionId(),
+			Credential: string(credential),
+		})
+		require.Error(t, err)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("unregistered key", func(t *testing.T) {
+		//ключ создан по параметрам регистрации, но регистрация не завершена
+		reg, err := s.AuthClient.BeginPasskeyRegistration(ctx, &ssov1.BeginPasskeyRegistrationRequest{Token: respLogin.GetToken()})
+		require.NoError(t, err)
+
+		stranger := passkeytest.New(s.Cfg.WebAuthn.Origins[0])
+		_, err = stranger.Create([]byte(reg.GetOptions()))
+		require.NoError(t, err)
+
+		begin, err := s.AuthClient.BeginPasskeyLogin(ctx, &ssov1.BeginPasskeyLoginRequest{AppId: appID})
+		require.NoError(t, err)
+
+		credential, err := stranger.Get([]byte(begin.GetOptions()))
+		require.NoError(t, err)
+
+		_, err = s.AuthClient.FinishPasskeyLogin(ctx, &ssov1.FinishPasskeyLoginRequest{
+			SessionId:  begin.GetSessionId(),
+			Credential: string(credential),
+		})
+		require.Error(t, err)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("unknown email", func(t *testing.T) {
+		//для неизвестного адреса ответ такой же, как для входа без email
+		begin, err := s.AuthClient.BeginPasskeyLogin(ctx, &ssov1.BeginPasskeyLoginRequest{Email: gofakeit.Email(), AppId: appID})
+		require.NoError(t, err)
+		assert.NotEmpty(t, begin.GetSessionId())
+		assert.NotContains(t, begin.GetOptions(), "allowCredentials")
+	})
+
+	t.Run("unknown app", func(t *testing.T) {
+		_, err := s.AuthClient.BeginPasskeyLogin(ctx, &ssov1.BeginPasskeyLoginRequest{AppId: 9999})
+		require.Error(t, err)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestPasskey_RegistrationFails(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	respLogin := registerAndLogin(ctx, t, s)
+
+	_, err := s.AuthClient.BeginPasskeyRegistration(ctx, &ssov1.BeginPasskeyRegistrationRequest{Token: "not-a-token"})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	begin, err := s.AuthClient.BeginPasskeyRegistration(ctx, &ssov1.BeginPasskeyRegistrationRequest{Token: respLogin.GetToken()})
+	require.NoError(t, err)
+
+	//ответ со страницы чужого origin
+	credential, err := passkeytest.New("https://phishing.example").Create([]byte(begin.GetOptions()))
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.FinishPasskeyRegistration(ctx, &ssov1.FinishPasskeyRegistrationRequest{
+		Token:      respLogin.GetToken(),
+		SessionId:  begin.GetSessionId(),
+		Credential: string(credential),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	//церемонию нельзя завершить от имени другого пользователя
+	begin, err = s.AuthClient.BeginPasskeyRegistration(ctx, &ssov1.BeginPasskeyRegistrationRequest{Token: respLogin.GetToken()})
+	require.NoError(t, err)
+
+	credential, err = passkeytest.New(s.Cfg.WebAuthn.Origins[0]).Create([]byte(begin.GetOptions()))
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.FinishPasskeyRegistration(ctx, &ssov1.FinishPasskeyRegistrationRequest{
+		Token:      registerAndLogin(ctx, t, s).GetToken(),
+		SessionId:  begin.GetSessionId(),
+		Credential: string(credential),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func registerPasskey(ctx context.Context, t *testing.T, s *suite.Suite, authenticator *passkeytest.Authenticator, token string) {
+	t.Helper()
+
+	begin, err := s.AuthClient.BeginPasskeyRegistration(ctx, &ssov1.BeginPasskeyRegistrationRequest{Token: token})
+	require.NoError(t, err)
+	require.NotEmpty(t, begin.GetSessionId())
+
+	credential, err := authenticator.Create([]byte(begin.GetOptions()))
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.FinishPasskeyRegistration(ctx, &ssov1.FinishPasskeyRegistrationRequest{
+		Token:      token,
+		SessionId:  begin.GetSessionId(),
+		Credential: string(credential),
+	})
+	require.NoError(t, err)
+}
+
+func passkeyLogin(ctx context.Context, t *testing.T, s *suite.Suite, authenticator *passkeytest.Authenticator, email string) *ssov1.FinishPasskeyLoginResponse {
+	t.Helper()
+
+	begin, err := s.AuthClient.BeginPasskeyLogin(ctx, &ssov1.BeginPasskeyLoginRequest{Email: email, AppId: appID})
+	require.NoError(t, err)
+
+	credential, err := authenticator.Get([]byte(begin.GetOptions()))
+	require.NoError(t, err)
+
+	resp, err := s.AuthClient.FinishPasskeyLogin(ctx, &ssov1.FinishPasskeyLoginRequest{
+		SessionId:  begin.GetSessionId(),
+		Credential: string(credential),
+	})
+	require.NoError(t, err)
+
+	return resp
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/tests/suite"
+	"testing"
+)
+
+const legacyPassword = "legacy-password"
+
+func TestLogin_LegacyPasswordHashes(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	for _, email := range []string{"bcrypt@sso.test", "scrypt@sso.test", "argon2id@sso.test"} {
+		t.Run(email, func(t *testing.T) {
+			//первый вход проверяет старый хэш и пересчитывает его, второй проверяет уже новый
+			for range 2 {
+				resp, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: legacyPassword, AppId: appID})
+				require.NoError(t, err)
+				assert.NotEmpty(t, resp.GetToken())
+			}
+
+			_, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: legacyPassword + "!", AppId: appID})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestPasswordHashParams(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	resp, err := s.AuthClient.PasswordHashParams(ctx, &ssov1.PasswordHashParamsRequest{Token: adminToken(ctx, t, s)})
+	require.NoError(t, err)
+
+	assert.Equal(t, s.Cfg.PasswordHash.Algorithm, resp.GetAlgorithm())
+	assert.NotEmpty(t, resp.GetParams())
+	assert.Equal(t, s.Cfg.PasswordHash.Calibration.Target.Milliseconds(), resp.GetTargetMs())
+
+	if resp.GetCalibrated() {
+		assert.NotZero(t, resp.GetCalibratedAt())
+		assert.NotZero(t, resp.GetHashDurationMs())
+	}
+
+	_, err = s.AuthClient.PasswordHashParams(ctx, &ssov1.PasswordHashParamsRequest{Token: registerAndLogin(ctx, t, s).GetToken()})
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
@@ -0,0 +1,111 @@
+package tests
+
+import (
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/tests/suite"
+	"strings"
+	"testing"
+)
+
+func TestRegister_PasswordPolicy(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email := gofakeit.Email()
+	local, _, _ := strings.Cut(email, "@")
+
+	tests := []struct {
+		name     string
+		password string
+		reasons  []string
+	}{
+		{
+			name:     "too short",
+			password: "Ab1",
+			reasons:  []string{"PASSWORD_TOO_SHORT"},
+		},
+		{
+			name:     "single character class",
+			password: "abcdefghijkl",
+			reasons:  []string{"PASSWORD_CHAR_CLASSES"},
+		},
+		{
+			name:     "contains email",
+			password: "X1-" + local + "-2024",
+			reasons:  []string{"PASSWORD_CONTAINS_EMAIL"},
+		},
+		{
+			name:     "all violations are reported together",
+			password: "abc",
+			reasons:  []string{"PASSWORD_TOO_SHORT", "PASSWORD_CHAR_CLASSES"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: tt.password})
+			require.Error(t, err)
+
+			assert.Equal(t, tt.reasons, passwordViolations(t, err, "password"))
+		})
+	}
+
+	//отклоненный пароль не создает пользователя
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: randomFakePassword()})
+	require.NoError(t, err)
+}
+
+func TestChangePassword_PasswordPolicy(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.ChangePassword(ctx, &ssov1.ChangePasswordRequest{
+		Token:           respLogin.GetToken(),
+		CurrentPassword: password,
+		NewPassword:     "short",
+	})
+	require.Error(t, err)
+	assert.Contains(t, passwordViolations(t, err, "new_password"), "PASSWORD_TOO_SHORT")
+
+	//старый пароль остался действующим
+	_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+}
+
+// passwordViolations причины нарушений из деталей BadRequest ответа; все нарушения должны относиться к field
+func passwordViolations(t *testing.T, err error, field string) []string {
+	t.Helper()
+
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+
+	var reasons []string
+
+	for _, detail := range st.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		if !ok {
+			continue
+		}
+
+		for _, v := range badRequest.GetFieldViolations() {
+			assert.Equal(t, field, v.GetField())
+			assert.NotEmpty(t, v.GetDescription())
+			reasons = append(reasons, v.GetReason())
+		}
+	}
+
+	return reasons
+}
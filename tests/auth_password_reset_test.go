@@ -0,0 +1,126 @@
+package tests
+
+import (
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/tests/suite"
+	"testing"
+	"time"
+)
+
+func TestResetPassword_HappyPath(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+	newPassword := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	//в outbox уже есть письмо с кодом подтверждения email
+	verification := lastCode(s, email)
+
+	_, err = s.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: email})
+	require.NoError(t, err)
+
+	code := nextCode(s, email, verification)
+
+	_, err = s.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Code: code, NewPassword: newPassword})
+	require.NoError(t, err)
+
+	//старые сессии завершены
+	_, err = s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: respLogin.GetRefreshToken()})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	access, err := s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+		Token:     respLogin.GetToken(),
+		AppId:     appID,
+		AppSecret: appSecret,
+	})
+	require.NoError(t, err)
+	assert.False(t, access.GetActive())
+
+	_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+
+	respLogin, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: newPassword, AppId: appID})
+	require.NoError(t, err)
+	assert.NotEmpty(t, respLogin.GetToken())
+
+	//код одноразовый
+	_, err = s.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Code: code, NewPassword: randomFakePassword()})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestResetPassword_RevokesLegacyTokenOfSameSecond(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+
+	respReg, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: randomFakePassword()})
+	require.NoError(t, err)
+
+	verification := lastCode(s, email)
+
+	_, err = s.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: email})
+	require.NoError(t, err)
+
+	code := nextCode(s, email, verification)
+
+	//начало новой секунды, чтобы токен и сброс гарантированно попали в одну
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+
+	//токен старого формата без sid: его отзывает только сброс пароля
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"uid":    respReg.GetUserId(),
+		"email":  email,
+		"app_id": appID,
+		"iat":    time.Now().Unix(),
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(appSecret))
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Code: code, NewPassword: randomFakePassword()})
+	require.NoError(t, err)
+
+	access, err := s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+		Token:     legacy,
+		AppId:     appID,
+		AppSecret: appSecret,
+	})
+	require.NoError(t, err)
+	assert.False(t, access.GetActive())
+}
+
+func TestRequestPasswordReset_UnknownEmail(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	//ответ не выдает, зарегистрирован ли адрес
+	_, err := s.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: gofakeit.Email()})
+	require.NoError(t, err)
+}
+
+func TestResetPassword_VerificationCodeRejected(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: randomFakePassword()})
+	require.NoError(t, err)
+
+	//код подтверждения email не годится для сброса пароля
+	_, err = s.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{
+		Code:        lastCode(s, email),
+		NewPassword: randomFakePassword(),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"context"
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestRefresh_HappyPath(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	respLogin := registerAndLogin(ctx, t, s)
+	require.NotEmpty(t, respLogin.GetRefreshToken())
+
+	respRefresh, err := s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: respLogin.GetRefreshToken()})
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, respRefresh.GetToken())
+	assert.NotEmpty(t, respRefresh.GetRefreshToken())
+	assert.NotEqual(t, respLogin.GetRefreshToken(), respRefresh.GetRefreshToken())
+}
+
+func TestRefresh_ReuseRevokesFamily(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	respLogin := registerAndLogin(ctx, t, s)
+
+	rotated, err := s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: respLogin.GetRefreshToken()})
+	require.NoError(t, err)
+
+	//повторное использование уже обмененного токена
+	_, err = s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: respLogin.GetRefreshToken()})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	//после обнаружения повторного использования отозвано все семейство, включая свежий токен
+	_, err = s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: rotated.GetRefreshToken()})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestRefresh_FailCases(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	tests := []struct {
+		name         string
+		refreshToken string
+		expectedErr  string
+	}{
+		{
+			name:         "Refresh with empty token",
+			refreshToken: "",
+			expectedErr:  "refresh_token: value is required",
+		},
+		{
+			name:         "Refresh with unknown token",
+			refreshToken: gofakeit.LetterN(43),
+			expectedErr:  "Invalid refresh token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: tt.refreshToken})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func registerAndLogin(ctx context.Context, t *testing.T, s *suite.Suite) *ssov1.LoginResponse {
+	t.Helper()
+
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	return respLogin
+}
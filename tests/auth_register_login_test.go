@@ -3,10 +3,11 @@ package tests
 import (
 	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
 	"github.com/brianvoe/gofakeit/v6"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"sso/pkg/ssotoken"
 	"sso/tests/suite"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -14,6 +15,7 @@ import (
 const (
 	emptyAppID        = 0
 	appID             = 1
+	appName           = "test"
 	appSecret         = "test-secret"
 	passDefaultLength = 10
 )
@@ -48,19 +50,21 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 	token := respLogin.GetToken()
 	require.NotEmpty(t, token)
 
-	tokenParsed, err := jwt.Parse(token, func(token *jwt.Token) (any, error) {
-		return []byte(appSecret), nil
-	})
+	claims, err := ssotoken.NewVerifier(ssotoken.Secret(appSecret),
+		ssotoken.WithIssuer(s.Cfg.Issuer),
+		ssotoken.WithAudience(appName),
+	).Verify(ctx, token)
 	require.NoError(t, err)
 
-	claims, ok := tokenParsed.Claims.(jwt.MapClaims)
-	assert.True(t, ok)
-	assert.Equal(t, respReg.GetUserId(), int64(claims["uid"].(float64)))
-	assert.Equal(t, email, claims["email"].(string))
-	assert.Equal(t, appID, int(claims["app_id"].(float64)))
+	assert.Equal(t, strconv.FormatInt(respReg.GetUserId(), 10), claims.Subject)
+	assert.NotEmpty(t, claims.ID)
+
+	assert.Equal(t, respReg.GetUserId(), claims.UserID)
+	assert.Equal(t, email, claims.Email)
+	assert.Equal(t, appID, claims.AppID)
 
 	const deltaSeconds = 1
-	assert.InDelta(t, loginTime.Add(s.Cfg.TokenTTL).Unix(), claims["exp"].(float64), deltaSeconds)
+	assert.InDelta(t, loginTime.Add(s.Cfg.TokenTTL).Unix(), claims.ExpiresAt.Unix(), deltaSeconds)
 }
 
 func TestRegisterLogin_Register_DuplicateRegistration(t *testing.T) {
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestRoles_GrantEmbedsIntoTokens(t *testing.T) {
+	ctx, s := suite.New(t)
+	admin := adminToken(ctx, t, s)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	//база тестов общая между запусками, поэтому имена ролей уникальны
+	suffix := gofakeit.UUID()
+
+	global, err := s.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{
+		Token:       admin,
+		Name:        "auditor-" + suffix,
+		Description: "read everything",
+		Permissions: []string{"documents:read"},
+	})
+	require.NoError(t, err)
+
+	app, err := s.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{
+		Token:       admin,
+		Name:        "editor-" + suffix,
+		AppId:       appID,
+		Permissions: []string{"documents:write", "documents:read"},
+	})
+	require.NoError(t, err)
+
+	otherApp, err := s.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{
+		Token:       admin,
+		Name:        "billing-" + suffix,
+		AppId:       policyAppID,
+		Permissions: []string{"invoices:write"},
+	})
+	require.NoError(t, err)
+
+	respReg, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	for _, roleID := range []int64{global.GetRoleId(), app.GetRoleId(), otherApp.GetRoleId()} {
+		_, err = s.AuthClient.GrantRole(ctx, &ssov1.GrantRoleRequest{Token: admin, UserId: respReg.GetUserId(), RoleId: roleID})
+		require.NoError(t, err)
+	}
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	//в токен попадают глобальные роли и роли приложения входа, права объединяются
+	claims := tokenClaims(t, respLogin.GetToken())
+	assert.ElementsMatch(t, []any{"auditor-" + suffix, "editor-" + suffix}, claims["roles"])
+	assert.ElementsMatch(t, []any{"documents:read", "documents:write"}, claims["permissions"])
+
+	list, err := s.AuthClient.ListUserRoles(ctx, &ssov1.ListUserRolesRequest{Token: respLogin.GetToken()})
+	require.NoError(t, err)
+	require.Len(t, list.GetRoles(), 3)
+	assert.Equal(t, int32(0), list.GetRoles()[0].GetAppId())
+	assert.Equal(t, "read everything", list.GetRoles()[0].GetDescription())
+
+	_, err = s.AuthClient.RevokeRole(ctx, &ssov1.RevokeRoleRequest{Token: admin, UserId: respReg.GetUserId(), RoleId: app.GetRoleId()})
+	require.NoError(t, err)
+
+	//отозванная роль пропадает из токенов со следующего refresh
+	refreshed, err := s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: respLogin.GetRefreshToken()})
+	require.NoError(t, err)
+
+	claims = tokenClaims(t, refreshed.GetToken())
+	assert.ElementsMatch(t, []any{"auditor-" + suffix}, claims["roles"])
+	assert.ElementsMatch(t, []any{"documents:read"}, claims["permissions"])
+
+	_, err = s.AuthClient.RevokeRole(ctx, &ssov1.RevokeRoleRequest{Token: admin, UserId: respReg.GetUserId(), RoleId: app.GetRoleId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestRoles_AdminRole(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	//флаг is_admin перенесен в глобальную роль admin
+	claims := tokenClaims(t, adminToken(ctx, t, s))
+	assert.Contains(t, claims["roles"], "admin")
+
+	respLogin := registerAndLogin(ctx, t, s)
+
+	claims = tokenClaims(t, respLogin.GetToken())
+	assert.NotContains(t, claims, "roles")
+}
+
+func TestRoles_Fails(t *testing.T) {
+	ctx, s := suite.New(t)
+	admin := adminToken(ctx, t, s)
+	name := "role-" + gofakeit.UUID()
+
+	role, err := s.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{Token: admin, Name: name, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{Token: admin, Name: name, AppId: appID})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	//одноименная роль другого приложения не конфликтует
+	_, err = s.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{Token: admin, Name: name, AppId: policyAppID})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{Token: admin, Name: name, AppId: 9999})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = s.AuthClient.GrantRole(ctx, &ssov1.GrantRoleRequest{Token: admin, UserId: 999999999, RoleId: role.GetRoleId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	user := registerAndLogin(ctx, t, s)
+
+	_, err = s.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{Token: user.GetToken(), Name: "role-" + gofakeit.UUID()})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = s.AuthClient.GrantRole(ctx, &ssov1.GrantRoleRequest{Token: "invalid-token", UserId: 1, RoleId: role.GetRoleId()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	//чужие роли видит только администратор
+	other, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: gofakeit.Email(), Password: randomFakePassword()})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.ListUserRoles(ctx, &ssov1.ListUserRolesRequest{Token: user.GetToken(), UserId: other.GetUserId()})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = s.AuthClient.ListUserRoles(ctx, &ssov1.ListUserRolesRequest{Token: admin, UserId: other.GetUserId()})
+	require.NoError(t, err)
+}
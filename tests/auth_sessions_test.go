@@ -0,0 +1,135 @@
+package tests
+
+import (
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/tests/suite"
+	"testing"
+)
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	current, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	other, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	currentSID, _ := tokenClaims(t, current.GetToken())["sid"].(string)
+	otherSID, _ := tokenClaims(t, other.GetToken())["sid"].(string)
+	require.NotEmpty(t, currentSID)
+	require.NotEmpty(t, otherSID)
+	require.NotEqual(t, currentSID, otherSID)
+
+	list, err := s.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{Token: current.GetToken()})
+	require.NoError(t, err)
+	require.Len(t, list.GetSessions(), 2)
+
+	for _, session := range list.GetSessions() {
+		assert.Equal(t, session.GetId() == currentSID, session.GetCurrent())
+		assert.Equal(t, int32(appID), session.GetAppId())
+		assert.NotEmpty(t, session.GetClientIp())
+		assert.Contains(t, session.GetUserAgent(), "grpc-go")
+		assert.NotZero(t, session.GetCreatedAt())
+		assert.Greater(t, session.GetExpiresAt(), session.GetLastUsedAt())
+	}
+
+	_, err = s.AuthClient.RevokeSession(ctx, &ssov1.RevokeSessionRequest{Token: current.GetToken(), SessionId: otherSID})
+	require.NoError(t, err)
+
+	//access токен отозванной сессии перестает проходить проверку сразу, не дожидаясь exp
+	_, err = s.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{Token: other.GetToken()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	introspection, err := s.AuthClient.Introspect(ctx, &ssov1.IntrospectRequest{
+		Token:     other.GetToken(),
+		AppId:     appID,
+		AppSecret: appSecret,
+	})
+	require.NoError(t, err)
+	assert.False(t, introspection.GetActive())
+
+	revoked, err := s.AuthClient.IsRevoked(ctx, &ssov1.IsRevokedRequest{Sid: otherSID})
+	require.NoError(t, err)
+	assert.True(t, revoked.GetRevoked())
+
+	_, err = s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: other.GetRefreshToken()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	//повторный отзыв и чужая сессия неотличимы от несуществующей
+	_, err = s.AuthClient.RevokeSession(ctx, &ssov1.RevokeSessionRequest{Token: current.GetToken(), SessionId: otherSID})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	stranger := registerAndLogin(ctx, t, s)
+
+	_, err = s.AuthClient.RevokeSession(ctx, &ssov1.RevokeSessionRequest{Token: stranger.GetToken(), SessionId: currentSID})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	//refresh продолжает сессию: sid тот же
+	refreshed, err := s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: current.GetRefreshToken()})
+	require.NoError(t, err)
+	assert.Equal(t, currentSID, tokenClaims(t, refreshed.GetToken())["sid"])
+
+	list, err = s.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{Token: refreshed.GetToken()})
+	require.NoError(t, err)
+	require.Len(t, list.GetSessions(), 1)
+	assert.Equal(t, currentSID, list.GetSessions()[0].GetId())
+	assert.True(t, list.GetSessions()[0].GetCurrent())
+}
+
+func TestSessions_RevokeAll(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	current, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	other, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.RevokeAllSessions(ctx, &ssov1.RevokeAllSessionsRequest{Token: current.GetToken(), KeepCurrent: true})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: other.GetRefreshToken()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	list, err := s.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{Token: current.GetToken()})
+	require.NoError(t, err)
+	require.Len(t, list.GetSessions(), 1)
+	assert.True(t, list.GetSessions()[0].GetCurrent())
+
+	_, err = s.AuthClient.RevokeAllSessions(ctx, &ssov1.RevokeAllSessionsRequest{Token: current.GetToken()})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{Token: current.GetToken()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = s.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: current.GetRefreshToken()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestIsRevoked_RequiresJtiOrSid(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, err := s.AuthClient.IsRevoked(ctx, &ssov1.IsRevokedRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	//неизвестная сессия удалена после истечения или не существовала
+	revoked, err := s.AuthClient.IsRevoked(ctx, &ssov1.IsRevokedRequest{Sid: "unknown-session"})
+	require.NoError(t, err)
+	assert.True(t, revoked.GetRevoked())
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"context"
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/tests/suite"
+	"testing"
+)
+
+const (
+	adminEmail    = "admin@sso.test"
+	adminPassword = "test-admin-password"
+)
+
+func TestLogin_BackoffAndUnlock(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	backoffAfter := s.Cfg.LoginThrottle.BackoffAfter
+	if backoffAfter <= 0 {
+		t.Skip("login backoff is disabled in config")
+	}
+
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	for range backoffAfter {
+		_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: randomFakePassword(), AppId: appID})
+		require.Error(t, err)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	//во время паузы не проходит даже верный пароль
+	_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	_, err = s.AuthClient.UnlockLogin(ctx, &ssov1.UnlockLoginRequest{Token: adminToken(ctx, t, s), Email: email})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+}
+
+func TestUnlockLogin_Fails(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	respLogin := registerAndLogin(ctx, t, s)
+
+	tests := []struct {
+		name         string
+		token        string
+		email        string
+		ip           string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "Not admin",
+			token:        respLogin.GetToken(),
+			email:        gofakeit.Email(),
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			name:         "Invalid token",
+			token:        "not-a-token",
+			email:        gofakeit.Email(),
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "Nothing to unlock",
+			token:        respLogin.GetToken(),
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "Invalid ip",
+			token:        respLogin.GetToken(),
+			ip:           "not-an-ip",
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.AuthClient.UnlockLogin(ctx, &ssov1.UnlockLoginRequest{Token: tt.token, Email: tt.email, Ip: tt.ip})
+			require.Error(t, err)
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func adminToken(ctx context.Context, t *testing.T, s *suite.Suite) string {
+	t.Helper()
+
+	resp, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: adminEmail, Password: adminPassword, AppId: appID})
+	require.NoError(t, err)
+
+	return resp.GetToken()
+}
@@ -0,0 +1,48 @@
+package tests
+
+import (
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sso/tests/suite"
+	"testing"
+	"time"
+)
+
+const (
+	policyAppID     = 2
+	policyAppSecret = "test-policy-secret"
+	policyTokenTTL  = time.Minute
+)
+
+func TestLogin_PerAppTokenPolicy(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	respLogin, err := s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: policyAppID})
+	require.NoError(t, err)
+
+	loginTime := time.Now()
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(respLogin.GetToken(), claims, func(*jwt.Token) (any, error) {
+		return []byte(policyAppSecret), nil
+	})
+	require.NoError(t, err)
+
+	//TTL приложения вместо token_ttl из конфига
+	const deltaSeconds = 1
+	assert.InDelta(t, loginTime.Add(policyTokenTTL).Unix(), claims["exp"].(float64), deltaSeconds)
+
+	//шаблон приложения: is_admin и tenant вместо email
+	assert.NotContains(t, claims, "email")
+	assert.Equal(t, false, claims["is_admin"])
+	assert.Equal(t, "acme", claims["tenant"])
+	assert.EqualValues(t, policyAppID, claims["app_id"])
+}
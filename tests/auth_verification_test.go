@@ -0,0 +1,140 @@
+package tests
+
+import (
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sso/internal/lib/mail"
+	"sso/tests/suite"
+	"testing"
+	"time"
+)
+
+const verifiedAppID = 5
+
+var codeRe = regexp.MustCompile(`code: (\S+)`)
+
+func TestVerifyEmail_HappyPath(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+	password := randomFakePassword()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password})
+	require.NoError(t, err)
+
+	//приложение требует подтвержденный email
+	_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: verifiedAppID})
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	//остальные приложения пускают без подтверждения
+	_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	code := lastCode(s, email)
+
+	_, err = s.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Code: code})
+	require.NoError(t, err)
+
+	_, err = s.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: verifiedAppID})
+	require.NoError(t, err)
+
+	//код одноразовый
+	_, err = s.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Code: code})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSendVerification_ReplacesCode(t *testing.T) {
+	ctx, s := suite.New(t)
+	email := gofakeit.Email()
+
+	_, err := s.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: randomFakePassword()})
+	require.NoError(t, err)
+
+	first := lastCode(s, email)
+
+	_, err = s.AuthClient.SendVerification(ctx, &ssov1.SendVerificationRequest{Email: email})
+	require.NoError(t, err)
+
+	second := nextCode(s, email, first)
+
+	//новый код отменяет старый
+	_, err = s.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Code: first})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = s.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Code: second})
+	require.NoError(t, err)
+}
+
+func TestSendVerification_UnknownEmail(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	//ответ не выдает, зарегистрирован ли адрес
+	_, err := s.AuthClient.SendVerification(ctx, &ssov1.SendVerificationRequest{Email: gofakeit.Email()})
+	require.NoError(t, err)
+}
+
+func TestVerifyEmail_InvalidCode(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, err := s.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Code: gofakeit.UUID()})
+	require.Error(t, err)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// lastCode код из последнего письма пользователю в outbox сервера. Письма отправляются в фоне,
+// поэтому ждет, пока придет хотя бы одно
+func lastCode(s *suite.Suite, email string) string {
+	s.Helper()
+
+	return nextCode(s, email, "")
+}
+
+// nextCode код из письма, пришедшего после письма с кодом prev
+func nextCode(s *suite.Suite, email, prev string) string {
+	s.Helper()
+
+	dir := s.Cfg.Mail.OutboxDir
+	if !filepath.IsAbs(dir) {
+		//сервер запущен из корня репозитория, тесты - из tests
+		dir = filepath.Join("..", dir)
+	}
+
+	var code string
+
+	require.Eventually(s, func() bool {
+		code = mailCode(dir, email)
+		return code != "" && code != prev
+	}, 5*time.Second, 50*time.Millisecond, "no new mail for %s in %s", email, dir)
+
+	return code
+}
+
+// mailCode код из последнего письма пользователю в dir, пустая строка, если писем нет
+func mailCode(dir, email string) string {
+	files, err := filepath.Glob(filepath.Join(dir, mail.FileName(email)+"-*.eml"))
+	if err != nil || len(files) == 0 {
+		return ""
+	}
+
+	//имена с unixnano одной длины, поэтому последний по алфавиту - самый свежий
+	raw, err := os.ReadFile(files[len(files)-1])
+	if err != nil {
+		return ""
+	}
+
+	match := codeRe.FindSubmatch(raw)
+	if match == nil {
+		return ""
+	}
+
+	return string(match[1])
+}
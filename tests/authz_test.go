@@ -0,0 +1,173 @@
+package tests
+
+import (
+	ssov1 "github.com/EvgenyPrf/protos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sso/tests/suite"
+	"testing"
+)
+
+const authzSchema = `{"namespaces": {
+	"user": {},
+	"group": {"relations": {"member": {}}},
+	"folder": {"relations": {
+		"owner": {},
+		"viewer": {"union": [{"this": {}}, {"computed_userset": {"relation": "owner"}}]}
+	}},
+	"document": {"relations": {
+		"parent": {},
+		"owner": {},
+		"viewer": {"union": [
+			{"this": {}},
+			{"computed_userset": {"relation": "owner"}},
+			{"tuple_to_userset": {"tupleset": "parent", "computed_userset": "viewer"}}
+		]}
+	}}
+}}`
+
+func TestAuthz_HappyPath(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, err := s.AuthzClient.WriteSchema(ctx, &ssov1.WriteSchemaRequest{AppId: appID, AppSecret: appSecret, Schema: authzSchema})
+	require.NoError(t, err)
+
+	schema, err := s.AuthzClient.ReadSchema(ctx, &ssov1.ReadSchemaRequest{AppId: appID, AppSecret: appSecret})
+	require.NoError(t, err)
+	assert.JSONEq(t, authzSchema, schema.GetSchema())
+
+	//база тестов общая между запусками, поэтому id объектов уникальны
+	id := gofakeit.UUID()
+	alice, bob, carol := "user:alice-"+id, "user:bob-"+id, "user:carol-"+id
+	group, folder := "group:eng-"+id, "folder:root-"+id
+	readme, spec := "document:readme-"+id, "document:spec-"+id
+
+	_, err = s.AuthzClient.WriteTuples(ctx, &ssov1.WriteTuplesRequest{
+		AppId:     appID,
+		AppSecret: appSecret,
+		Writes: []string{
+			group + "#member@" + alice,
+			folder + "#viewer@" + group + "#member",
+			readme + "#parent@" + folder,
+			spec + "#owner@" + bob,
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		object, subject string
+		want            bool
+	}{
+		{readme, alice, true},
+		{readme, bob, false},
+		{spec, bob, true},
+		{spec, alice, false},
+		{readme, group + "#member", true},
+		{readme, carol, false},
+	}
+
+	for _, tt := range tests {
+		resp, err := s.AuthzClient.Check(ctx, &ssov1.CheckRequest{
+			AppId:     appID,
+			AppSecret: appSecret,
+			Object:    tt.object,
+			Relation:  "viewer",
+			Subject:   tt.subject,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, resp.GetAllowed(), "%s#viewer@%s", tt.object, tt.subject)
+	}
+
+	objects, err := s.AuthzClient.ListObjects(ctx, &ssov1.ListObjectsRequest{
+		AppId:     appID,
+		AppSecret: appSecret,
+		Namespace: "document",
+		Relation:  "viewer",
+		Subject:   alice,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{readme}, objects.GetObjects())
+	assert.Empty(t, objects.GetNextPageToken())
+
+	expand, err := s.AuthzClient.Expand(ctx, &ssov1.ExpandRequest{AppId: appID, AppSecret: appSecret, Object: readme, Relation: "viewer"})
+	require.NoError(t, err)
+
+	tree := expand.GetTree()
+	assert.Equal(t, "union", tree.GetOperation())
+	require.Len(t, tree.GetChildren(), 3)
+
+	//viewer папки: userset группы не раскрывается
+	parent := tree.GetChildren()[2]
+	require.Len(t, parent.GetChildren(), 1)
+	assert.Equal(t, folder, parent.GetChildren()[0].GetObject())
+	assert.Equal(t, []string{group + "#member"}, parent.GetChildren()[0].GetChildren()[0].GetSubjects())
+
+	//удаление членства отзывает доступ к документам папки
+	_, err = s.AuthzClient.WriteTuples(ctx, &ssov1.WriteTuplesRequest{
+		AppId:     appID,
+		AppSecret: appSecret,
+		Deletes:   []string{group + "#member@" + alice},
+	})
+	require.NoError(t, err)
+
+	resp, err := s.AuthzClient.Check(ctx, &ssov1.CheckRequest{AppId: appID, AppSecret: appSecret, Object: readme, Relation: "viewer", Subject: alice})
+	require.NoError(t, err)
+	assert.False(t, resp.GetAllowed())
+}
+
+func TestAuthz_FailCases(t *testing.T) {
+	ctx, s := suite.New(t)
+
+	_, err := s.AuthzClient.WriteSchema(ctx, &ssov1.WriteSchemaRequest{AppId: appID, AppSecret: appSecret, Schema: authzSchema})
+	require.NoError(t, err)
+
+	_, err = s.AuthzClient.WriteSchema(ctx, &ssov1.WriteSchemaRequest{AppId: appID, AppSecret: "wrong-secret", Schema: authzSchema})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = s.AuthzClient.WriteSchema(ctx, &ssov1.WriteSchemaRequest{
+		AppId:     appID,
+		AppSecret: appSecret,
+		Schema:    `{"namespaces": {"doc": {"relations": {"viewer": {"computed_userset": {"relation": "owner"}}}}}}`,
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	//у приложения без схемы нет отношений
+	_, err = s.AuthzClient.Check(ctx, &ssov1.CheckRequest{
+		AppId:     policyAppID,
+		AppSecret: policyAppSecret,
+		Object:    "document:readme",
+		Relation:  "viewer",
+		Subject:   "user:alice",
+	})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = s.AuthzClient.ReadSchema(ctx, &ssov1.ReadSchemaRequest{AppId: policyAppID, AppSecret: policyAppSecret})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	invalidWrites := []string{
+		"document:readme#viewer",
+		"document:readme#editor@user:alice",
+		"document:readme#viewer@robot:alice",
+		"document:readme#viewer@group:eng#admin",
+	}
+
+	for _, tuple := range invalidWrites {
+		_, err = s.AuthzClient.WriteTuples(ctx, &ssov1.WriteTuplesRequest{AppId: appID, AppSecret: appSecret, Writes: []string{tuple}})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), tuple)
+	}
+
+	_, err = s.AuthzClient.Check(ctx, &ssov1.CheckRequest{
+		AppId:     appID,
+		AppSecret: appSecret,
+		Object:    "document:readme",
+		Relation:  "editor",
+		Subject:   "user:alice",
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = s.AuthzClient.Check(ctx, &ssov1.CheckRequest{AppId: appID, AppSecret: appSecret, Object: "document:readme"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
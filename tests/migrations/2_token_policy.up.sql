@@ -0,0 +1,3 @@
+INSERT INTO apps (id, name, secret, access_token_ttl, refresh_token_ttl, claims_template)
+VALUES (2, 'test-policy', 'test-policy-secret', 60, 3600, '{"user": ["is_admin"], "custom": {"tenant": "acme"}}')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,8 @@
+INSERT INTO apps (id, name, secret, sign_alg)
+VALUES (3, 'test-paseto', 'test-paseto-secret', 'v4.local')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO app_keys (app_id, kid, alg, private_key, status, created_at)
+VALUES (3, 'test-paseto-local', 'v4.local', X'000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f',
+        'active', CAST(strftime('%s', 'now') AS INTEGER))
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,4 @@
+INSERT INTO apps (id, name, secret, encryption_key)
+VALUES (4, 'test-encrypted', 'test-encrypted-secret',
+        '{"kty":"EC","kid":"l_Y3ZPevUl3fzd8kw9FBRBFur00PapWuHogVGhXAsoc","use":"enc","crv":"P-256","x":"208gD9tPA6G0hAAy8GZ8mh7U2wQI2hUrAVM77SZ4GGw","y":"xeMa_VLoQV7VPYFp0g85aOPetmCjMGkKP671O-r4-wc"}')
+ON CONFLICT DO NOTHING;
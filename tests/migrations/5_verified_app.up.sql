@@ -0,0 +1,3 @@
+INSERT INTO apps (id, name, secret, require_verified_email)
+VALUES (5, 'test-verified', 'test-verified-secret', TRUE)
+ON CONFLICT DO NOTHING;
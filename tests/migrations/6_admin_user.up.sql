@@ -0,0 +1,10 @@
+-- пароль test-admin-password
+INSERT INTO users (email, pass_hash, email_verified)
+VALUES ('admin@sso.test', '$2a$10$Rs01P04jk2giSKqJRO1obOxHryg57sAZc.0TlKTpgXucA5pkQ9bru', TRUE)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_roles (user_id, role_id, granted_at)
+SELECT users.id, roles.id, 0
+FROM users, roles
+WHERE users.email = 'admin@sso.test' AND roles.name = 'admin' AND roles.app_id IS NULL
+ON CONFLICT DO NOTHING;
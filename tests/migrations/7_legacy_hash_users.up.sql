@@ -0,0 +1,6 @@
+-- пароль legacy-password, хэши разных алгоритмов и стоимостей: пересчитываются при первом входе
+INSERT INTO users (email, pass_hash)
+VALUES ('bcrypt@sso.test', '$2a$04$acAtJGPnKQEBcWJ.ukvKh.B6O5NmS4DbdJpDJAee35s8ljzzt6yFy'),
+       ('scrypt@sso.test', '$scrypt$ln=10,r=8,p=1$bGVnYWN5LXNhbHQtMDAwMQ$Sbwwoe0WTLnnSTMKVhjMpCkfdslnu9cHTJiigy9K1tk'),
+       ('argon2id@sso.test', '$argon2id$v=19$m=4096,t=1,p=1$8Kd7yDkGm2nR14jirTOqzw$1y+ybUnLDhQmc0PZvyvmbAjfXJNMtzTbK/U1ruBcrUo')
+ON CONFLICT DO NOTHING;
@@ -17,8 +17,9 @@ const (
 
 type Suite struct {
 	*testing.T
-	Cfg        *config.Config
-	AuthClient ssov1.AuthClient
+	Cfg         *config.Config
+	AuthClient  ssov1.AuthClient
+	AuthzClient ssov1.AuthzClient
 }
 
 func New(t *testing.T) (context.Context, *Suite) {
@@ -44,9 +45,10 @@ func New(t *testing.T) (context.Context, *Suite) {
 	}
 
 	return ctx, &Suite{
-		T:          t,
-		Cfg:        cfg,
-		AuthClient: ssov1.NewAuthClient(cc),
+		T:           t,
+		Cfg:         cfg,
+		AuthClient:  ssov1.NewAuthClient(cc),
+		AuthzClient: ssov1.NewAuthzClient(cc),
 	}
 }
 